@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"juhojarvi/habits/internal/auth"
 	"juhojarvi/habits/internal/env"
 	"juhojarvi/habits/internal/mailer"
+	"juhojarvi/habits/internal/migrate"
+	"juhojarvi/habits/internal/netguard"
+	"juhojarvi/habits/internal/notify"
 	"juhojarvi/habits/internal/store"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -19,7 +29,16 @@ type api struct {
 	store         store.Storage
 	logger        *zap.SugaredLogger
 	mailer        mailer.Client
+	notifier      *notify.Dispatcher
+	webPush       *notify.WebPushChannel
 	authenticator auth.Authenticator
+	// outbound limits where webhooks and push notifications may be sent
+	outbound *netguard.Guard
+	// db and migrator back the readiness checks
+	db       *sql.DB
+	migrator *migrate.Migrator
+	// draining is set on shutdown so readiness fails before the server stops
+	draining atomic.Bool
 }
 
 type config struct {
@@ -30,6 +49,45 @@ type config struct {
 	apiURL      string
 	mail        mailConfig
 	auth        authConfig
+	completion  completionPolicy
+	jobs        jobsConfig
+	reminders   reminderConfig
+	push        pushConfig
+	outbound    outboundConfig
+	shutdown    shutdownConfig
+}
+
+type outboundConfig struct {
+	// allow is a comma separated list of private addresses or CIDR prefixes that
+	// webhooks and push endpoints may still use, also over plain http, e.g.
+	// "127.0.0.1" for a local receiver during development
+	allow string
+}
+
+type shutdownConfig struct {
+	// drainDelay is how long readiness fails before the server stops accepting
+	// connections, so the load balancer notices first
+	drainDelay time.Duration
+	// timeout is one deadline for both in-flight requests and background jobs
+	timeout time.Duration
+}
+
+type jobsConfig struct {
+	enabled bool
+}
+
+type reminderConfig struct {
+	// channels is a comma separated list of notification channels, e.g. "email,webpush"
+	channels string
+}
+
+type pushConfig struct {
+	vapidPublicKey  string
+	vapidPrivateKey string
+	// vapidSubject is a mailto: or https: contact for push service operators
+	vapidSubject string
+	// streakWarningHour is the local hour after which streak warnings are pushed
+	streakWarningHour int
 }
 
 type authConfig struct {
@@ -43,10 +101,16 @@ type tokenConfig struct {
 }
 
 type mailConfig struct {
+	// provider is one of mailtrap, sendgrid, smtp or file
+	provider  string
 	sendGrid  sendGridConfig
 	mailTrap  mailTrapConfig
+	smtp      smtpConfig
+	file      fileMailConfig
 	fromEmail string
 	exp       time.Duration
+	// unsubscribeSecret signs the one-click unsubscribe links in emails
+	unsubscribeSecret string
 }
 
 type sendGridConfig struct {
@@ -57,11 +121,27 @@ type mailTrapConfig struct {
 	apiKey string
 }
 
+type smtpConfig struct {
+	host     string
+	port     int
+	username string
+	password string
+	// tls is starttls, tls or none
+	tls string
+}
+
+type fileMailConfig struct {
+	// dir is where the .eml files are written
+	dir string
+}
+
 type dbConfig struct {
 	addr         string
 	maxOpenConns int
 	maxIdleConns int
 	maxIdleTime  string
+	// migrateOnStart applies pending migrations before the server starts
+	migrateOnStart bool
 }
 
 func (api *api) mount() http.Handler {
@@ -86,40 +166,126 @@ func (api *api) mount() http.Handler {
 	r.Use(middleware.Timeout(60 * time.Second))
 
 	r.Route("/v1", func(r chi.Router) {
+		r.Get("/health", api.healthHandler)
+		r.Get("/ready", api.readyHandler)
+		r.Get("/version", api.versionHandler)
 
 		r.Route("/habits", func(r chi.Router) {
 			r.Use(api.AuthTokenMiddleware)
 			r.Post("/", api.createHabitHandler)
+			r.Put("/order", api.reorderHabitsHandler)
 
 			r.Route("/{habitID}", func(r chi.Router) {
 				r.Use(api.habitContextMiddleware)
 				r.Get("/", api.getHabitHandler)
 				r.Delete("/", api.deleteHabitHandler)
 				r.Patch("/", api.updateHabitHandler)
+				r.Put("/tags", api.setHabitTagsHandler)
 
 				// Completion endpoints
 				r.Post("/complete", api.markHabitCompleteHandler)
 				r.Delete("/complete/{date}", api.unmarkHabitCompleteHandler)
 				r.Get("/completions", api.getHabitCompletionsHandler)
+				r.Get("/stats", api.getHabitStatsHandler)
+				r.Get("/heatmap", api.getHabitHeatmapHandler)
+				r.Post("/skip", api.skipHabitHandler)
+				r.Delete("/skip/{date}", api.unskipHabitHandler)
+
+				r.Get("/reminders", api.getRemindersHandler)
+				r.Post("/reminders", api.createReminderHandler)
+				r.Patch("/reminders/{reminderID}", api.updateReminderHandler)
+				r.Delete("/reminders/{reminderID}", api.deleteReminderHandler)
+			})
+		})
+
+		r.Route("/habit-groups", func(r chi.Router) {
+			r.Use(api.AuthTokenMiddleware)
+			r.Get("/", api.getHabitGroupsHandler)
+			r.Post("/", api.createHabitGroupHandler)
+			r.Put("/order", api.reorderHabitGroupsHandler)
+
+			r.Route("/{groupID}", func(r chi.Router) {
+				r.Use(api.habitGroupContextMiddleware)
+				r.Patch("/", api.updateHabitGroupHandler)
+				r.Delete("/", api.deleteHabitGroupHandler)
+			})
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(api.AuthTokenMiddleware)
+			r.Get("/", api.getTagsHandler)
+			r.Post("/", api.createTagHandler)
+			r.Get("/stats", api.getTagStatsHandler)
+
+			r.Route("/{tagID}", func(r chi.Router) {
+				r.Use(api.tagContextMiddleware)
+				r.Get("/", api.getTagHandler)
+				r.Patch("/", api.updateTagHandler)
+				r.Delete("/", api.deleteTagHandler)
+				r.Get("/stats", api.getTagStatsHandler)
 			})
 		})
 
+		r.Route("/today", func(r chi.Router) {
+			r.Use(api.AuthTokenMiddleware)
+			r.Get("/", api.getTodayHandler)
+		})
+
 		// User completions
 		r.Route("/completions", func(r chi.Router) {
 			r.Use(api.AuthTokenMiddleware)
 			r.Get("/", api.getUserCompletionsHandler)
+			r.Post("/batch", api.batchMarkCompleteHandler)
+			r.Delete("/batch", api.batchUnmarkCompleteHandler)
 		})
 
 		r.Route("/goals", func(r chi.Router) {
 			r.Use(api.AuthTokenMiddleware)
 			r.Post("/", api.createGoalHandler)
 			r.Get("/year/{year}", api.getGoalsByYearHandler)
+			r.Post("/year/{year}/review", api.reviewGoalsHandler)
+			r.Post("/year/{year}/rollover", api.rolloverGoalsHandler)
 
 			r.Route("/{goalID}", func(r chi.Router) {
 				r.Use(api.goalContextMiddleware)
 				r.Get("/", api.getGoalHandler)
 				r.Patch("/", api.updateGoalHandler)
 				r.Delete("/", api.deleteGoalHandler)
+
+				r.Get("/milestones", api.getGoalMilestonesHandler)
+				r.Post("/milestones", api.createGoalMilestoneHandler)
+				r.Patch("/milestones/{milestoneID}", api.updateGoalMilestoneHandler)
+				r.Delete("/milestones/{milestoneID}", api.deleteGoalMilestoneHandler)
+
+				r.Get("/checkins", api.getGoalCheckinsHandler)
+				r.Post("/checkins", api.createGoalCheckinHandler)
+			})
+		})
+
+		r.Route("/goal-categories", func(r chi.Router) {
+			r.Use(api.AuthTokenMiddleware)
+			r.Get("/", api.getGoalCategoriesHandler)
+			r.Post("/", api.createGoalCategoryHandler)
+
+			r.Route("/{categoryID}", func(r chi.Router) {
+				r.Use(api.goalCategoryContextMiddleware)
+				r.Patch("/", api.updateGoalCategoryHandler)
+				r.Delete("/", api.deleteGoalCategoryHandler)
+			})
+		})
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(api.AuthTokenMiddleware)
+			r.Get("/", api.getWebhooksHandler)
+			r.Post("/", api.createWebhookHandler)
+
+			r.Route("/{webhookID}", func(r chi.Router) {
+				r.Use(api.webhookContextMiddleware)
+				r.Get("/", api.getWebhookHandler)
+				r.Patch("/", api.updateWebhookHandler)
+				r.Delete("/", api.deleteWebhookHandler)
+				r.Get("/deliveries", api.getWebhookDeliveriesHandler)
+				r.Post("/deliveries/{deliveryID}/redeliver", api.redeliverWebhookHandler)
 			})
 		})
 
@@ -131,6 +297,14 @@ func (api *api) mount() http.Handler {
 				r.Get("/me", api.getMeHandler)
 				r.Patch("/me/email", api.updateMyEmailHandler)
 				r.Patch("/me/password", api.updateMyPasswordHandler)
+				r.Patch("/me/timezone", api.updateMyTimezoneHandler)
+				r.Get("/me/preferences", api.getMyPreferencesHandler)
+				r.Get("/me/push-subscriptions", api.getPushSubscriptionsHandler)
+				r.Post("/me/push-subscriptions", api.createPushSubscriptionHandler)
+				r.Delete("/me/push-subscriptions/{subscriptionID}", api.deletePushSubscriptionHandler)
+				r.Patch("/me/preferences", api.updateMyPreferencesHandler)
+				r.Get("/me/heatmap", api.getMyHeatmapHandler)
+				r.Get("/me/review/{year}", api.getYearReviewHandler)
 				r.Get("/feed", api.getUserFeedHandler)
 			})
 		})
@@ -142,22 +316,85 @@ func (api *api) mount() http.Handler {
 			r.Post("/forgot-password", api.forgotPasswordHandler)
 			r.Post("/reset-password", api.resetPasswordHandler)
 		})
+
+		r.Get("/unsubscribe/{token}", api.unsubscribePageHandler)
+		r.Get("/push/vapid-public-key", api.getVAPIDPublicKeyHandler)
+		r.Post("/unsubscribe/{token}", api.unsubscribeHandler)
+
+		// API docs, generated from apiDocs
+		r.Get("/openapi.json", api.getOpenAPIHandler)
+		r.Get("/docs", api.getAPIDocsHandler)
+
+		// Development helpers, never mounted in production
+		if api.config.env != "production" {
+			r.Route("/dev", func(r chi.Router) {
+				r.Get("/mail", api.getDevMailTemplatesHandler)
+				r.Get("/mail/{template}", api.previewMailHandler)
+			})
+		}
 	})
 
 	return r
 }
 
-func (api *api) run(mux http.Handler) error {
+// run serves until ctx is cancelled and then shuts down gracefully
+func (api *api) run(ctx context.Context, mux http.Handler, onShutdown func(context.Context)) error {
+	ln, err := net.Listen("tcp", api.config.addr)
+	if err != nil {
+		return err
+	}
 
+	return api.serve(ctx, ln, mux, onShutdown)
+}
+
+// serve answers on ln until ctx is cancelled. Shutdown first fails readiness
+// and waits drainDelay so the load balancer stops sending new requests, then
+// lets in-flight requests finish within timeout. onShutdown, if set, runs
+// alongside with the same deadline, so one timeout covers the whole shutdown.
+func (api *api) serve(ctx context.Context, ln net.Listener, mux http.Handler, onShutdown func(context.Context)) error {
 	server := &http.Server{
-		Addr:         api.config.addr,
 		Handler:      mux,
 		WriteTimeout: time.Second * 30,
 		ReadTimeout:  time.Second * 10,
 		IdleTimeout:  time.Minute,
 	}
 
-	api.logger.Infow("server has started ", "addr", api.config.addr, "env", api.config.env)
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+
+		api.logger.Infow("shutting down", "drain_delay", api.config.shutdown.drainDelay, "timeout", api.config.shutdown.timeout)
+		api.draining.Store(true)
+		server.SetKeepAlivesEnabled(false)
+		time.Sleep(api.config.shutdown.drainDelay)
+
+		ctx, cancel := context.WithTimeout(context.Background(), api.config.shutdown.timeout)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		if onShutdown != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				onShutdown(ctx)
+			}()
+		}
+
+		err := server.Shutdown(ctx)
+		wg.Wait()
+		shutdownErr <- err
+	}()
+
+	api.logger.Infow("server has started ", "addr", ln.Addr().String(), "env", api.config.env)
+
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-shutdownErr; err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
 
-	return server.ListenAndServe()
+	api.logger.Info("server stopped")
+	return nil
 }
@@ -18,6 +18,8 @@ type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
+	// Locale of emails; defaults to the default mail locale
+	Locale string `json:"locale" validate:"omitempty,oneof=en fi"`
 }
 
 type UserWithToken struct {
@@ -40,6 +42,7 @@ func (api *api) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	user := &store.User{
 		Username: payload.Username,
 		Email:    payload.Email,
+		Locale:   userLocale(payload.Locale),
 	}
 
 	// hash the user password
@@ -56,8 +59,23 @@ func (api *api) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	hash := sha256.Sum256([]byte(plainToken))
 	hashToken := hex.EncodeToString(hash[:])
 
+	activationURL := fmt.Sprintf("%s/confirm/%s", api.config.frontendURL, plainToken)
+
+	// the welcome mail is queued in the same transaction and sent by the outbox
+	// dispatcher, so a mail provider outage can't fail the registration
+	welcome := &store.OutboxEmail{
+		Template: mailer.UserWelcomeTemplate,
+		Locale:   user.Locale,
+		Username: user.Username,
+		Email:    user.Email,
+		Data: map[string]string{
+			"Username":      user.Username,
+			"ActivationURL": activationURL,
+		},
+	}
+
 	// store the user
-	err := api.store.Users.CreateAndInvite(ctx, user, hashToken, api.config.mail.exp)
+	err := api.store.Users.CreateAndInvite(ctx, user, hashToken, api.config.mail.exp, welcome)
 	if err != nil {
 		switch err {
 		case store.ErrDuplicateEmail:
@@ -75,30 +93,6 @@ func (api *api) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 		Token: plainToken,
 	}
 
-	activationURL := fmt.Sprintf("%s/confirm/%s", api.config.frontendURL, plainToken)
-
-	isProdEnv := api.config.env == "production"
-	vars := struct {
-		Username      string
-		ActivationURL string
-	}{
-		Username:      user.Username,
-		ActivationURL: activationURL,
-	}
-
-	// send mail
-	_, err = api.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
-	if err != nil {
-		api.logger.Errorw("error sending welcome email", "error", err)
-
-		// rollback user if email fails (SAGA pattern)
-		if err := api.store.Users.Delete(ctx, user.ID); err != nil {
-			api.logger.Errorw("error deleting user", "error", err)
-		}
-
-		api.internalServerError(w, r, err)
-	}
-
 	if err := api.jsonResponse(w, http.StatusCreated, userWithToken); err != nil {
 		api.internalServerError(w, r, err)
 	}
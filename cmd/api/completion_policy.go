@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	errFutureCompletion = errors.New("date is in the future")
+	errOutsideBackfill  = errors.New("date is outside the backfill window")
+	errLockedCompletion = errors.New("date is locked and can no longer be changed")
+)
+
+// completionPolicy decides which dates a user may write completions for.
+// Dates are compared as calendar days in the user's timezone.
+type completionPolicy struct {
+	// backfillDays is how many days back a completion may be added.
+	// 0 allows only today, a negative value disables the limit.
+	backfillDays int
+	// lockAfterDays makes history older than this immutable: nothing can be
+	// added or removed. 0 disables locking.
+	lockAfterDays int
+}
+
+// canMark checks that a completion may be added or updated for date.
+func (p completionPolicy) canMark(date, now time.Time, loc *time.Location) error {
+	age := daysAgo(date, now, loc)
+
+	switch {
+	case age < 0:
+		return errFutureCompletion
+	case p.lockAfterDays > 0 && age > p.lockAfterDays:
+		return errLockedCompletion
+	case p.backfillDays >= 0 && age > p.backfillDays:
+		return errOutsideBackfill
+	}
+
+	return nil
+}
+
+// canUnmark checks that a completion may be removed for date. Removing is
+// allowed outside the backfill window so mistakes can be cleaned up.
+func (p completionPolicy) canUnmark(date, now time.Time, loc *time.Location) error {
+	age := daysAgo(date, now, loc)
+
+	switch {
+	case age < 0:
+		return errFutureCompletion
+	case p.lockAfterDays > 0 && age > p.lockAfterDays:
+		return errLockedCompletion
+	}
+
+	return nil
+}
+
+// localDate returns the calendar day of t in loc as midnight UTC, which is how
+// dates parsed from 2006-01-02 strings are represented.
+func localDate(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// daysAgo returns how many days before today (in loc) date is; negative for future dates.
+func daysAgo(date, now time.Time, loc *time.Location) int {
+	y, m, d := date.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	return int(localDate(now, loc).Sub(day).Hours() / 24)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCompletionPolicy(t *testing.T) {
+	helsinki, err := time.LoadLocation("Europe/Helsinki")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+
+	// 23:30 UTC is already the next day in Helsinki
+	now := time.Date(2025, 3, 10, 23, 30, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		return d
+	}
+
+	policy := completionPolicy{backfillDays: 7, lockAfterDays: 30}
+
+	tests := []struct {
+		name       string
+		date       string
+		loc        *time.Location
+		wantMark   error
+		wantUnmark error
+	}{
+		{"today utc", "2025-03-10", time.UTC, nil, nil},
+		{"tomorrow utc", "2025-03-11", time.UTC, errFutureCompletion, errFutureCompletion},
+		{"today in helsinki", "2025-03-11", helsinki, nil, nil},
+		{"edge of backfill", "2025-03-03", time.UTC, nil, nil},
+		{"outside backfill", "2025-03-02", time.UTC, errOutsideBackfill, nil},
+		{"locked", "2025-02-01", time.UTC, errLockedCompletion, errLockedCompletion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.canMark(day(tt.date), now, tt.loc); !errors.Is(err, tt.wantMark) {
+				t.Fatalf("canMark: want %v got %v", tt.wantMark, err)
+			}
+			if err := policy.canUnmark(day(tt.date), now, tt.loc); !errors.Is(err, tt.wantUnmark) {
+				t.Fatalf("canUnmark: want %v got %v", tt.wantUnmark, err)
+			}
+		})
+	}
+
+	unlimited := completionPolicy{backfillDays: -1}
+	if err := unlimited.canMark(day("2020-01-01"), now, time.UTC); err != nil {
+		t.Fatalf("unlimited backfill: want nil got %v", err)
+	}
+}
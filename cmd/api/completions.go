@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"juhojarvi/habits/internal/store"
 	"net/http"
 	"time"
@@ -10,7 +11,8 @@ import (
 )
 
 type MarkCompletePayload struct {
-	Date string `json:"date" validate:"required"` // Format: 2006-01-02
+	Date   string `json:"date" validate:"required"` // Format: 2006-01-02
+	Amount *int   `json:"amount" validate:"omitempty,min=1,max=100000"`
 }
 
 // Mark habit complete for a specific date
@@ -36,11 +38,29 @@ func (api *api) markHabitCompleteHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := api.config.completion.canMark(date, time.Now(), user.Location()); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	// Without an amount the habit's daily target is reached
+	amount := habit.Target
+	if payload.Amount != nil {
+		amount = *payload.Amount
+	}
+
 	ctx := r.Context()
 
-	completion, err := api.store.HabitCompletions.MarkComplete(ctx, habit.ID, user.ID, date)
-	if err != nil {
-		api.internalServerError(w, r, err)
+	completion := &store.HabitCompletion{HabitID: habit.ID, UserID: user.ID, CompletedDate: date, Amount: amount}
+	event := store.NewEvent("habit.completed", habitCompletionEvent{Habit: habit, Date: payload.Date, Completion: completion})
+
+	if err := api.store.HabitCompletions.MarkComplete(ctx, completion, event); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
 		return
 	}
 
@@ -53,6 +73,7 @@ func (api *api) markHabitCompleteHandler(w http.ResponseWriter, r *http.Request)
 // Unmark habit complete for a specific date
 func (api *api) unmarkHabitCompleteHandler(w http.ResponseWriter, r *http.Request) {
 	habit := getHabitFromCtx(r)
+	user := getUserFromContext(r)
 
 	dateStr := chi.URLParam(r, "date")
 	date, err := time.Parse("2006-01-02", dateStr)
@@ -61,9 +82,15 @@ func (api *api) unmarkHabitCompleteHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if err := api.config.completion.canUnmark(date, time.Now(), user.Location()); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
 	ctx := r.Context()
 
-	if err := api.store.HabitCompletions.UnmarkComplete(ctx, habit.ID, date); err != nil {
+	event := store.NewEvent("habit.uncompleted", habitCompletionEvent{Habit: habit, Date: dateStr})
+	if err := api.store.HabitCompletions.UnmarkComplete(ctx, habit.ID, user.ID, date, event); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			api.notFoundError(w, r, err)
@@ -79,92 +106,329 @@ func (api *api) unmarkHabitCompleteHandler(w http.ResponseWriter, r *http.Reques
 // Get habit completions for a date range
 func (api *api) getHabitCompletionsHandler(w http.ResponseWriter, r *http.Request) {
 	habit := getHabitFromCtx(r)
+	user := getUserFromContext(r)
+
+	// Default to the last 30 days
+	startDate, endDate, err := parseDateRange(r, 30)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	completions, err := api.store.HabitCompletions.GetCompletionsByHabit(ctx, habit.ID, user.ID, startDate, endDate)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, completions); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Get user's all completions for a date range
+func (api *api) getUserCompletionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
 
-	// Parse query parameters
+	// Default to the last 7 days
+	startDate, endDate, err := parseDateRange(r, 7)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	tagIDs, err := store.ParseIDList(r.URL.Query().Get("tags"))
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	completions, err := api.store.HabitCompletions.GetCompletionsByUser(ctx, user.ID, startDate, endDate, tagIDs)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, completions); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// parseDateRange reads the optional start and end query parameters (2006-01-02).
+// Missing start defaults to defaultDays before today, missing end to today.
+func parseDateRange(r *http.Request, defaultDays int) (time.Time, time.Time, error) {
 	startStr := r.URL.Query().Get("start")
 	endStr := r.URL.Query().Get("end")
 
-	var startDate, endDate time.Time
+	startDate := time.Now().AddDate(0, 0, -defaultDays)
+	endDate := time.Now()
+
 	var err error
 
 	if startStr != "" {
 		startDate, err = time.Parse("2006-01-02", startStr)
 		if err != nil {
-			api.badRequestError(w, r, err)
-			return
+			return time.Time{}, time.Time{}, err
 		}
-	} else {
-		// Default to 30 days ago
-		startDate = time.Now().AddDate(0, 0, -30)
 	}
 
 	if endStr != "" {
 		endDate, err = time.Parse("2006-01-02", endStr)
 		if err != nil {
-			api.badRequestError(w, r, err)
-			return
+			return time.Time{}, time.Time{}, err
 		}
-	} else {
-		// Default to today
-		endDate = time.Now()
 	}
 
-	ctx := r.Context()
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, errors.New("end must not be before start")
+	}
+
+	return startDate, endDate, nil
+}
+
+type BatchCompletionItem struct {
+	HabitID int64  `json:"habit_id" validate:"required"`
+	Date    string `json:"date" validate:"required"` // Format: 2006-01-02
+	Amount  *int   `json:"amount" validate:"omitempty,min=1,max=100000"`
+}
+
+type BatchCompletionPayload struct {
+	Items []BatchCompletionItem `json:"items" validate:"required,min=1,max=400,dive"`
+}
+
+type BatchUnmarkItem struct {
+	HabitID int64  `json:"habit_id" validate:"required"`
+	Date    string `json:"date" validate:"required"` // Format: 2006-01-02
+}
+
+type BatchUnmarkPayload struct {
+	Items []BatchUnmarkItem `json:"items" validate:"required,min=1,max=400,dive"`
+}
+
+// batchItemResult is the outcome of one item, in the same order as the request.
+// Status is one of created, updated, deleted, not_found or invalid.
+type batchItemResult struct {
+	Index      int                    `json:"index"`
+	HabitID    int64                  `json:"habit_id"`
+	Date       string                 `json:"date"`
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	Completion *store.HabitCompletion `json:"completion,omitempty"`
+}
+
+type batchValidation struct {
+	habits  map[int64]*store.Habit
+	dates   []time.Time
+	results []batchItemResult
+	ok      bool
+}
+
+// validateBatch parses the dates, applies the completion policy check to each
+// and verifies in a single query that every habit belongs to the user.
+// Rejected items already carry their status in results.
+func (api *api) validateBatch(r *http.Request, user *store.User, habitIDs []int64, dates []string, check func(date, now time.Time, loc *time.Location) error) (*batchValidation, error) {
+	habits, err := api.store.Habits.GetByIDs(r.Context(), user.ID, habitIDs)
+	if err != nil {
+		return nil, err
+	}
 
-	completions, err := api.store.HabitCompletions.GetCompletionsByHabit(ctx, habit.ID, startDate, endDate)
+	now := time.Now()
+	loc := user.Location()
+
+	parsed := make([]time.Time, len(dates))
+	results := make([]batchItemResult, len(dates))
+	seen := map[string]bool{}
+	ok := true
+
+	for i, dateStr := range dates {
+		results[i] = batchItemResult{Index: i, HabitID: habitIDs[i], Date: dateStr}
+
+		date, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			results[i].Status = "invalid"
+			results[i].Error = "invalid date"
+			ok = false
+			continue
+		}
+		parsed[i] = date
+
+		if err := check(date, now, loc); err != nil {
+			results[i].Status = "invalid"
+			results[i].Error = err.Error()
+			ok = false
+			continue
+		}
+
+		key := fmt.Sprintf("%d/%s", habitIDs[i], dateStr)
+		if seen[key] {
+			results[i].Status = "invalid"
+			results[i].Error = "duplicate habit and date"
+			ok = false
+			continue
+		}
+		seen[key] = true
+
+		if _, found := habits[habitIDs[i]]; !found {
+			results[i].Status = "not_found"
+			results[i].Error = "habit not found"
+			ok = false
+		}
+	}
+
+	return &batchValidation{habits: habits, dates: parsed, results: results, ok: ok}, nil
+}
+
+// Mark many habit/date pairs complete at once. Either every item is applied or,
+// if any item is rejected, none is and the per-item results explain why.
+func (api *api) batchMarkCompleteHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload BatchCompletionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	habitIDs := make([]int64, len(payload.Items))
+	dates := make([]string, len(payload.Items))
+	for i, item := range payload.Items {
+		habitIDs[i] = item.HabitID
+		dates[i] = item.Date
+	}
+
+	v, err := api.validateBatch(r, user, habitIDs, dates, api.config.completion.canMark)
 	if err != nil {
 		api.internalServerError(w, r, err)
 		return
 	}
 
-	if err := api.jsonResponse(w, http.StatusOK, completions); err != nil {
+	results := v.results
+	if !v.ok {
+		if err := api.jsonResponse(w, http.StatusUnprocessableEntity, results); err != nil {
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	marks := make([]store.BatchMark, len(payload.Items))
+	for i, item := range payload.Items {
+		amount := v.habits[item.HabitID].Target
+		if item.Amount != nil {
+			amount = *item.Amount
+		}
+
+		marks[i].Completion = store.HabitCompletion{
+			HabitID:       item.HabitID,
+			CompletedDate: v.dates[i],
+			Amount:        amount,
+		}
+		marks[i].Event = store.NewEvent("habit.completed", habitCompletionEvent{
+			Habit:      v.habits[item.HabitID],
+			Date:       item.Date,
+			Completion: &marks[i].Completion,
+		})
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.HabitCompletions.MarkCompleteBatch(ctx, user.ID, marks); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// A habit was deleted between validation and the write
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for i := range marks {
+		results[i].Completion = &marks[i].Completion
+		results[i].Status = "updated"
+		if marks[i].Created {
+			results[i].Status = "created"
+		}
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, results); err != nil {
 		api.internalServerError(w, r, err)
 		return
 	}
 }
 
-// Get user's all completions for a date range
-func (api *api) getUserCompletionsHandler(w http.ResponseWriter, r *http.Request) {
+// Remove many completions at once in one transaction. Items without a
+// completion are reported as not_found but don't fail the batch.
+func (api *api) batchUnmarkCompleteHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromContext(r)
 
-	// Parse query parameters
-	startStr := r.URL.Query().Get("start")
-	endStr := r.URL.Query().Get("end")
+	var payload BatchUnmarkPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
 
-	var startDate, endDate time.Time
-	var err error
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
 
-	if startStr != "" {
-		startDate, err = time.Parse("2006-01-02", startStr)
-		if err != nil {
-			api.badRequestError(w, r, err)
-			return
+	habitIDs := make([]int64, len(payload.Items))
+	dates := make([]string, len(payload.Items))
+	for i, item := range payload.Items {
+		habitIDs[i] = item.HabitID
+		dates[i] = item.Date
+	}
+
+	v, err := api.validateBatch(r, user, habitIDs, dates, api.config.completion.canUnmark)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	results := v.results
+	if !v.ok {
+		if err := api.jsonResponse(w, http.StatusUnprocessableEntity, results); err != nil {
+			api.internalServerError(w, r, err)
 		}
-	} else {
-		// Default to 7 days ago
-		startDate = time.Now().AddDate(0, 0, -7)
+		return
 	}
 
-	if endStr != "" {
-		endDate, err = time.Parse("2006-01-02", endStr)
-		if err != nil {
-			api.badRequestError(w, r, err)
-			return
+	entries := make([]store.CompletionEntry, len(payload.Items))
+	for i, item := range payload.Items {
+		entries[i] = store.CompletionEntry{
+			HabitID: item.HabitID,
+			Date:    v.dates[i],
+			Event:   store.NewEvent("habit.uncompleted", habitCompletionEvent{Habit: v.habits[item.HabitID], Date: item.Date}),
 		}
-	} else {
-		// Default to today
-		endDate = time.Now()
 	}
 
 	ctx := r.Context()
 
-	completions, err := api.store.HabitCompletions.GetCompletionsByUser(ctx, user.ID, startDate, endDate)
+	deleted, err := api.store.HabitCompletions.UnmarkCompleteBatch(ctx, user.ID, entries)
 	if err != nil {
 		api.internalServerError(w, r, err)
 		return
 	}
 
-	if err := api.jsonResponse(w, http.StatusOK, completions); err != nil {
+	for i := range deleted {
+		results[i].Status = "not_found"
+		if deleted[i] {
+			results[i].Status = "deleted"
+		}
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, results); err != nil {
 		api.internalServerError(w, r, err)
 		return
 	}
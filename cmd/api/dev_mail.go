@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"juhojarvi/habits/internal/mailer"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errUnknownTemplate = errors.New("unknown mail template")
+
+// getDevMailTemplatesHandler lists the templates that can be previewed.
+// Dev routes are only mounted outside production.
+func (api *api) getDevMailTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	templates, err := mailer.Templates()
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	names := make([]string, 0, len(templates))
+	for _, t := range templates {
+		names = append(names, strings.TrimSuffix(t, ".tmpl"))
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, map[string]any{
+		"templates": names,
+		"locales":   mailer.Locales,
+	}); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+// previewMailHandler renders a template with sample data. ?locale= picks the
+// translation and ?format=html|text|json the output; html is the default so the
+// page can be opened in a browser.
+func (api *api) previewMailHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "template")
+	if !strings.HasSuffix(name, ".tmpl") {
+		name += ".tmpl"
+	}
+
+	data, ok := mailer.SampleData(name)
+	if !ok {
+		api.notFoundError(w, r, errUnknownTemplate)
+		return
+	}
+
+	locale := r.URL.Query().Get("locale")
+	if locale == "" {
+		locale = mailer.DefaultLocale
+	}
+	if !mailer.IsSupportedLocale(locale) {
+		api.badRequestResponse(w, r, errors.New("unsupported locale"))
+		return
+	}
+
+	rendered, err := mailer.Render(name, locale, data)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(rendered.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Subject: " + rendered.Subject + "\n\n" + rendered.Text))
+	case "json":
+		if err := api.jsonResponse(w, http.StatusOK, rendered); err != nil {
+			api.internalServerError(w, r, err)
+		}
+	default:
+		api.badRequestResponse(w, r, errors.New("format must be html, text or json"))
+	}
+}
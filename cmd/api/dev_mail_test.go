@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestPreviewMail(t *testing.T) {
+	newHandler := func(env string) http.Handler {
+		api := &api{config: config{env: env}, logger: zap.NewNop().Sugar()}
+		return api.mount()
+	}
+
+	get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	dev := newHandler("development")
+
+	rr := get(dev, "/v1/dev/mail/user_invitation")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("html preview: got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	rr = get(dev, "/v1/dev/mail/password_reset.tmpl?locale=fi&format=text")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Subject: Salasanan vaihto") {
+		t.Fatalf("text preview: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := get(dev, "/v1/dev/mail/nope"); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown template: want %d got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := get(dev, "/v1/dev/mail/user_invitation?locale=sv"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown locale: want %d got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	if rr := get(newHandler("production"), "/v1/dev/mail/user_invitation"); rr.Code != http.StatusNotFound {
+		t.Fatalf("production: want %d got %d", http.StatusNotFound, rr.Code)
+	}
+}
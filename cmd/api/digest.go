@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"juhojarvi/habits/internal/mailer"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	weeklyDigestEmailKind = "weekly_digest"
+	weeklyDigestBatchSize = 100
+	// weeklyDigestTopN limits the streak and missed habit lists in the email
+	weeklyDigestTopN = 5
+)
+
+var errInvalidUnsubscribeToken = errors.New("invalid unsubscribe link")
+
+// weeklyDigestPeriod returns the Monday of the week before the local date today
+// and its ISO week as the delivery period, e.g. 2026-W42.
+func weeklyDigestPeriod(today time.Time) (time.Time, string) {
+	daysSinceMonday := (int(today.Weekday()) + 6) % 7
+	start := today.AddDate(0, 0, -daysSinceMonday-7)
+
+	year, week := start.ISOWeek()
+	return start, fmt.Sprintf("%d-W%02d", year, week)
+}
+
+type weeklyDigestEmail struct {
+	Username          string
+	WeekStart         time.Time
+	WeekEnd           time.Time
+	TotalCompletions  int
+	CompletionPercent int
+	Streaks           []struct {
+		Name string
+		Days int
+	}
+	Missed []struct {
+		Name string
+		Days int
+	}
+	Goals []struct {
+		Description string
+		Percent     int
+	}
+	AppURL         string
+	UnsubscribeURL string
+}
+
+// MailHeaders adds one-click unsubscribe (RFC 8058) for mail clients
+func (e weeklyDigestEmail) MailHeaders() map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + e.UnsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+func newWeeklyDigestEmail(user *store.User, digest *store.WeeklyDigest, frontendURL, unsubscribeURL string) weeklyDigestEmail {
+	vars := weeklyDigestEmail{
+		Username:          user.Username,
+		WeekStart:         digest.Start,
+		WeekEnd:           digest.End,
+		TotalCompletions:  digest.TotalCompletions,
+		CompletionPercent: int(digest.CompletionRate*100 + 0.5),
+		AppURL:            frontendURL,
+		UnsubscribeURL:    unsubscribeURL,
+	}
+
+	// digest.Habits is sorted by streak, longest first
+	for _, h := range digest.Habits {
+		if h.Streak > 0 && len(vars.Streaks) < weeklyDigestTopN {
+			vars.Streaks = append(vars.Streaks, struct {
+				Name string
+				Days int
+			}{h.Name, h.Streak})
+		}
+		if h.MissedDays > 0 && len(vars.Missed) < weeklyDigestTopN {
+			vars.Missed = append(vars.Missed, struct {
+				Name string
+				Days int
+			}{h.Name, h.MissedDays})
+		}
+	}
+
+	for _, g := range digest.Goals {
+		if g.Completed != nil && *g.Completed {
+			continue
+		}
+
+		percent := 0
+		if g.Progress != nil {
+			percent = int(g.Progress.CompletionRate*100 + 0.5)
+		}
+		vars.Goals = append(vars.Goals, struct {
+			Description string
+			Percent     int
+		}{g.Description, percent})
+	}
+
+	return vars
+}
+
+// sendWeeklyDigests mails last week's digest to users who opted in, on the
+// weekday they picked in their own timezone. Sends are claimed per ISO week so
+// instances never send the same digest twice; a failed send releases the claim,
+// and a claim whose sender died is taken over once its lease expires.
+func (api *api) sendWeeklyDigests(ctx context.Context) error {
+	now := time.Now()
+	isProdEnv := api.config.env == "production"
+
+	for {
+		users, err := api.store.WeeklyDigests.PendingRecipients(ctx, weeklyDigestEmailKind, weeklyDigestBatchSize)
+		if err != nil {
+			return err
+		}
+
+		sent := 0
+		for i := range users {
+			user := &users[i]
+
+			start, period := weeklyDigestPeriod(localDate(now, user.Location()))
+
+			claimed, err := api.store.EmailDeliveries.Claim(ctx, user.ID, weeklyDigestEmailKind, period, deliveryClaimLease)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+
+			digest, err := api.store.WeeklyDigests.Get(ctx, user.ID, start)
+			if err == nil {
+				unsubscribeURL := fmt.Sprintf("%s/v1/unsubscribe/%s", api.config.apiURL,
+					signUnsubscribeToken(api.config.mail.unsubscribeSecret, user.PublicID, weeklyDigestEmailKind))
+				vars := newWeeklyDigestEmail(user, digest, api.config.frontendURL, unsubscribeURL)
+				_, err = api.mailer.Send(mailer.WeeklyDigestTemplate, user.Locale, user.Username, user.Email, vars, !isProdEnv)
+			}
+			if err != nil {
+				api.logger.Errorw("error sending weekly digest email", "user_id", user.ID, "error", err)
+				if err := api.store.EmailDeliveries.Release(ctx, user.ID, weeklyDigestEmailKind, period); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := api.store.EmailDeliveries.MarkSent(ctx, user.ID, weeklyDigestEmailKind, period); err != nil {
+				return err
+			}
+
+			sent++
+		}
+
+		// Stop on the last page, or when nothing could be sent so failures aren't retried in a loop
+		if len(users) < weeklyDigestBatchSize || sent == 0 {
+			return nil
+		}
+	}
+}
+
+// deriveUnsubscribeSecret returns the key unsubscribe links are signed with:
+// UNSUBSCRIBE_SECRET, or else a key derived from AUTH_TOKEN_SECRET so the JWT
+// signing key itself never signs the long-lived links.
+func deriveUnsubscribeSecret(unsubscribeSecret, authSecret string) string {
+	if unsubscribeSecret != "" || authSecret == "" {
+		return unsubscribeSecret
+	}
+
+	mac := hmac.New(sha256.New, []byte(authSecret))
+	mac.Write([]byte("unsubscribe"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signUnsubscribeToken returns a token identifying the user and the email list.
+// It has no expiry so links in old emails keep working.
+func signUnsubscribeToken(secret, publicID, list string) string {
+	payload := publicID + ":" + list
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyUnsubscribeToken checks the signature and returns the user's public ID and the list.
+func verifyUnsubscribeToken(secret, token string) (publicID, list string, err error) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", errInvalidUnsubscribeToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", errInvalidUnsubscribeToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return "", "", errInvalidUnsubscribeToken
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", "", errInvalidUnsubscribeToken
+	}
+
+	publicID, list, ok = strings.Cut(string(payload), ":")
+	if !ok {
+		return "", "", errInvalidUnsubscribeToken
+	}
+
+	return publicID, list, nil
+}
+
+// unsubscribePage is shown when an unsubscribe link is opened in a browser
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="{{.Locale}}">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}</title>
+</head>
+<body>
+  <h1>{{.Title}}</h1>
+  <p>{{.Message}}</p>
+  {{if .Button}}
+  <form method="post">
+    <input type="hidden" name="List-Unsubscribe" value="One-Click">
+    <input type="hidden" name="confirm" value="1">
+    <button type="submit">{{.Button}}</button>
+  </form>
+  {{end}}
+</body>
+</html>
+`))
+
+// unsubscribePageTexts has the page's title, the button and a message for each
+// step: "confirm", "done" and "invalid"
+var unsubscribePageTexts = map[string]map[string]string{
+	"en": {
+		"title":   "Weekly digest",
+		"button":  "Unsubscribe",
+		"confirm": "Stop sending me the weekly digest email?",
+		"done":    "You won't get the weekly digest anymore. You can turn it back on in your profile.",
+		"invalid": "This unsubscribe link is invalid.",
+	},
+	"fi": {
+		"title":   "Viikkokooste",
+		"button":  "Peru tilaus",
+		"confirm": "Lopetetaanko viikkokoosteen lähettäminen?",
+		"done":    "Et saa enää viikkokoostetta. Voit ottaa sen takaisin käyttöön profiilissasi.",
+		"invalid": "Peruutuslinkki on virheellinen.",
+	},
+}
+
+// renderUnsubscribePage shows the message of step; only "confirm" has a button
+func (api *api) renderUnsubscribePage(w http.ResponseWriter, r *http.Request, status int, locale, step string) {
+	texts, ok := unsubscribePageTexts[locale]
+	if !ok {
+		locale = mailer.DefaultLocale
+		texts = unsubscribePageTexts[locale]
+	}
+
+	data := struct {
+		Locale, Title, Message, Button string
+	}{Locale: locale, Title: texts["title"], Message: texts[step]}
+	if step == "confirm" {
+		data.Button = texts["button"]
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := unsubscribePage.Execute(w, data); err != nil {
+		api.logger.Errorw("error rendering unsubscribe page", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+}
+
+// unsubscribeTarget resolves an unsubscribe token to the user and the list
+func (api *api) unsubscribeTarget(ctx context.Context, token string) (*store.User, string, error) {
+	if api.config.mail.unsubscribeSecret == "" {
+		return nil, "", errInvalidUnsubscribeToken
+	}
+
+	publicID, list, err := verifyUnsubscribeToken(api.config.mail.unsubscribeSecret, token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if list != weeklyDigestEmailKind {
+		return nil, "", errInvalidUnsubscribeToken
+	}
+
+	user, err := api.store.Users.GetByPublicID(ctx, publicID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, "", errInvalidUnsubscribeToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, list, nil
+}
+
+// Confirmation page for an unsubscribe link. Mail clients and link scanners
+// fetch links on their own, so opening one doesn't change anything; the page
+// posts back to the same URL.
+func (api *api) unsubscribePageHandler(w http.ResponseWriter, r *http.Request) {
+	user, _, err := api.unsubscribeTarget(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidUnsubscribeToken):
+			api.renderUnsubscribePage(w, r, http.StatusBadRequest, mailer.DefaultLocale, "invalid")
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	api.renderUnsubscribePage(w, r, http.StatusOK, user.Locale, "confirm")
+}
+
+// One-click unsubscribe from an email list (RFC 8058), also used by the
+// confirmation page, which gets a page back instead of an empty response.
+func (api *api) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	fromPage := r.PostFormValue("confirm") != ""
+
+	user, list, err := api.unsubscribeTarget(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidUnsubscribeToken) && fromPage:
+			api.renderUnsubscribePage(w, r, http.StatusBadRequest, mailer.DefaultLocale, "invalid")
+		case errors.Is(err, errInvalidUnsubscribeToken):
+			api.badRequestError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	ctx := r.Context()
+
+	switch list {
+	case weeklyDigestEmailKind:
+		prefs, err := api.store.Users.GetPreferences(ctx, user.ID)
+		if err != nil {
+			api.internalServerError(w, r, err)
+			return
+		}
+
+		prefs.WeeklyDigest = false
+		if err := api.store.Users.UpdatePreferences(ctx, user.ID, prefs); err != nil {
+			api.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if fromPage {
+		api.renderUnsubscribePage(w, r, http.StatusOK, user.Locale, "done")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"juhojarvi/habits/internal/mailer"
+	"juhojarvi/habits/internal/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeeklyDigestPeriod(t *testing.T) {
+	tests := []struct {
+		today      time.Time
+		wantStart  string
+		wantPeriod string
+	}{
+		{time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC), "2026-10-12", "2026-W42"}, // Monday
+		{time.Date(2026, time.October, 25, 0, 0, 0, 0, time.UTC), "2026-10-12", "2026-W42"}, // Sunday
+		{time.Date(2026, time.January, 7, 0, 0, 0, 0, time.UTC), "2025-12-29", "2026-W01"},
+	}
+
+	for _, tt := range tests {
+		start, period := weeklyDigestPeriod(tt.today)
+		if start.Format(time.DateOnly) != tt.wantStart || period != tt.wantPeriod {
+			t.Errorf("weeklyDigestPeriod(%s) = %s, %s; want %s, %s", tt.today.Format(time.DateOnly),
+				start.Format(time.DateOnly), period, tt.wantStart, tt.wantPeriod)
+		}
+	}
+}
+
+func TestUnsubscribeToken(t *testing.T) {
+	token := signUnsubscribeToken("secret", "3f1c7a52-0000-4000-8000-000000000000", weeklyDigestEmailKind)
+
+	publicID, list, err := verifyUnsubscribeToken("secret", token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if publicID != "3f1c7a52-0000-4000-8000-000000000000" || list != weeklyDigestEmailKind {
+		t.Fatalf("verify: got %q, %q", publicID, list)
+	}
+
+	if _, _, err := verifyUnsubscribeToken("other-secret", token); err == nil {
+		t.Fatal("verify with wrong secret: want error")
+	}
+
+	forged := signUnsubscribeToken("secret", "someone-else", weeklyDigestEmailKind)
+	payload, _, _ := strings.Cut(forged, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	if _, _, err := verifyUnsubscribeToken("secret", payload+"."+sig); err == nil {
+		t.Fatal("verify with swapped payload: want error")
+	}
+
+	if _, _, err := verifyUnsubscribeToken("secret", "not-a-token"); err == nil {
+		t.Fatal("verify malformed: want error")
+	}
+}
+
+func TestDeriveUnsubscribeSecret(t *testing.T) {
+	if got := deriveUnsubscribeSecret("explicit", "jwt-secret"); got != "explicit" {
+		t.Fatalf("explicit secret: got %q", got)
+	}
+	if got := deriveUnsubscribeSecret("", ""); got != "" {
+		t.Fatalf("no secrets: got %q", got)
+	}
+
+	derived := deriveUnsubscribeSecret("", "jwt-secret")
+	if derived == "" || derived == "jwt-secret" {
+		t.Fatalf("derived: want a key other than the JWT secret got %q", derived)
+	}
+	if deriveUnsubscribeSecret("", "jwt-secret") != derived {
+		t.Fatal("derived: want the same key on every start")
+	}
+}
+
+func TestWeeklyDigestEmail_UnsubscribeHeaders(t *testing.T) {
+	user := &store.User{Username: "maija"}
+	digest := &store.WeeklyDigest{Start: time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC), End: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)}
+	vars := newWeeklyDigestEmail(user, digest, "http://example.test", "http://api.example.test/v1/unsubscribe/abc")
+
+	r, err := mailer.Render(mailer.WeeklyDigestTemplate, "en", vars)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	if got := r.Headers["List-Unsubscribe"]; got != "<http://api.example.test/v1/unsubscribe/abc>" {
+		t.Errorf("List-Unsubscribe: got %q", got)
+	}
+	if got := r.Headers["List-Unsubscribe-Post"]; got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post: got %q", got)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type goalCategoryKey string
+
+const goalCategoryCtxKey goalCategoryKey = "goalCategory"
+
+type CreateGoalCategoryPayload struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Color string `json:"color" validate:"omitempty,hexcolor"`
+}
+
+type UpdateGoalCategoryPayload struct {
+	Name     *string `json:"name" validate:"omitempty,min=1,max=50"`
+	Color    *string `json:"color" validate:"omitempty,hexcolor"`
+	Position *int    `json:"position" validate:"omitempty,min=0"`
+}
+
+func (api *api) createGoalCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateGoalCategoryPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	category := &store.GoalCategory{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Color:  payload.Color,
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.GoalCategories.Create(ctx, category); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateGoalCategory):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, category); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) getGoalCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	categories, err := api.store.GoalCategories.GetByUser(ctx, user.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, categories); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) updateGoalCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	category := getGoalCategoryFromCtx(r)
+
+	var payload UpdateGoalCategoryPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.Name != nil {
+		category.Name = *payload.Name
+	}
+	if payload.Color != nil {
+		category.Color = *payload.Color
+	}
+	if payload.Position != nil {
+		category.Position = *payload.Position
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.GoalCategories.Update(ctx, category); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrDuplicateGoalCategory):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, category); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) deleteGoalCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	category := getGoalCategoryFromCtx(r)
+	ctx := r.Context()
+
+	if err := api.store.GoalCategories.Delete(ctx, category.ID, category.UserID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrGoalCategoryInUse):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *api) goalCategoryContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idParam := chi.URLParam(r, "categoryID")
+		id, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+
+		user := getUserFromContext(r)
+		ctx := r.Context()
+
+		category, err := api.store.GoalCategories.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				api.notFoundError(w, r, err)
+			default:
+				api.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, goalCategoryCtxKey, category)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getGoalCategoryFromCtx(r *http.Request) *store.GoalCategory {
+	category, _ := r.Context().Value(goalCategoryCtxKey).(*store.GoalCategory)
+	return category
+}
+
+// resolveGoalCategory finds the user's category by id or, for older clients, by name.
+func (api *api) resolveGoalCategory(ctx context.Context, userID int64, id *int64, name string) (*store.GoalCategory, error) {
+	if id != nil {
+		return api.store.GoalCategories.GetByID(ctx, *id, userID)
+	}
+	return api.store.GoalCategories.GetByName(ctx, name, userID)
+}
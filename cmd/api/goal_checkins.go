@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"time"
+)
+
+type CreateCheckinPayload struct {
+	Value *float64 `json:"value" validate:"required"`
+	Note  string   `json:"note" validate:"max=500"`
+	Date  string   `json:"date"` // Format: 2006-01-02, defaults to today
+}
+
+// Record the current value of a measurable goal (e.g. money saved so far)
+func (api *api) createGoalCheckinHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+	user := getUserFromContext(r)
+
+	var payload CreateCheckinPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	today := localDate(time.Now(), user.Location())
+
+	date := today
+	if payload.Date != "" {
+		d, err := time.Parse("2006-01-02", payload.Date)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+		date = d
+	}
+
+	if date.After(today) {
+		api.badRequestError(w, r, errors.New("check-in date is in the future"))
+		return
+	}
+
+	checkin := &store.GoalCheckin{
+		GoalID:    goal.ID,
+		Value:     *payload.Value,
+		Note:      payload.Note,
+		CheckedOn: date,
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.GoalCheckins.Create(ctx, checkin); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, checkin); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Get check-in history, newest first
+func (api *api) getGoalCheckinsHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+	ctx := r.Context()
+
+	checkins, err := api.store.GoalCheckins.GetByGoal(ctx, goal.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, checkins); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
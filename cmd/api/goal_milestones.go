@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateMilestonePayload struct {
+	Title     string  `json:"title" validate:"required,max=200"`
+	DueDate   *string `json:"due_date"` // Format: 2006-01-02
+	Completed bool    `json:"completed"`
+}
+
+type UpdateMilestonePayload struct {
+	Title     *string `json:"title" validate:"omitempty,min=1,max=200"`
+	DueDate   *string `json:"due_date"` // Empty string clears the date
+	Completed *bool   `json:"completed"`
+}
+
+func (api *api) getGoalMilestonesHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+	ctx := r.Context()
+
+	milestones, err := api.store.GoalMilestones.GetByGoal(ctx, goal.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, milestones); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) createGoalMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+
+	var payload CreateMilestonePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	dueDate, err := parseOptionalDate(payload.DueDate)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	milestone := &store.GoalMilestone{
+		GoalID:    goal.ID,
+		Title:     payload.Title,
+		DueDate:   dueDate,
+		Completed: payload.Completed,
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.GoalMilestones.Create(ctx, milestone); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, milestone); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) updateGoalMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "milestoneID"), 10, 64)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	var payload UpdateMilestonePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	milestone, err := api.store.GoalMilestones.GetByID(ctx, id, goal.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if payload.Title != nil {
+		milestone.Title = *payload.Title
+	}
+	if payload.DueDate != nil {
+		milestone.DueDate, err = parseOptionalDate(payload.DueDate)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+	}
+	if payload.Completed != nil {
+		milestone.Completed = *payload.Completed
+	}
+
+	if err := api.store.GoalMilestones.Update(ctx, milestone); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, milestone); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) deleteGoalMilestoneHandler(w http.ResponseWriter, r *http.Request) {
+	goal := getGoalFromContext(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "milestoneID"), 10, 64)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.GoalMilestones.Delete(ctx, id, goal.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
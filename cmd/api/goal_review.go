@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type GoalReviewItem struct {
+	GoalID     int64  `json:"goal_id" validate:"required"`
+	Outcome    string `json:"outcome" validate:"required,oneof=achieved partial missed dropped"`
+	Reflection string `json:"reflection" validate:"max=2000"`
+}
+
+type GoalReviewPayload struct {
+	Goals []GoalReviewItem `json:"goals" validate:"required,min=1,max=100,dive"`
+}
+
+type GoalRolloverPayload struct {
+	GoalIDs []int64 `json:"goal_ids" validate:"required,min=1,max=100,unique"`
+}
+
+type goalRolloverResponse struct {
+	Year      int              `json:"year"`
+	Rollovers []store.Rollover `json:"rollovers"`
+	Goals     []store.Goal     `json:"goals"`
+}
+
+// Record the outcome and a reflection for goals of a finished year
+func (api *api) reviewGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	var payload GoalReviewPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	reviews := make([]store.GoalReview, len(payload.Goals))
+	for i, item := range payload.Goals {
+		reviews[i] = store.GoalReview{
+			GoalID:     item.GoalID,
+			Outcome:    item.Outcome,
+			Reflection: item.Reflection,
+		}
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.Goals.SaveReview(ctx, user.ID, year, reviews); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.badRequestError(w, r, errors.New("invalid goal"))
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	goals, err := api.store.Goals.GetByUserAndYear(ctx, user.ID, year)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, goals); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Copy unfinished goals into the next year and move their habits along
+func (api *api) rolloverGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	var payload GoalRolloverPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	rollovers, err := api.store.Goals.Rollover(ctx, user.ID, year, payload.GoalIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.badRequestError(w, r, errors.New("goals must be unfinished goals of this year"))
+		case errors.Is(err, store.ErrGoalAlreadyRolledOver):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	nextYear, err := api.store.Goals.GetByUserAndYear(ctx, user.ID, year+1)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	newIDs := make([]int64, len(rollovers))
+	for i, ro := range rollovers {
+		newIDs[i] = ro.GoalID
+	}
+
+	goals := slices.DeleteFunc(nextYear, func(g store.Goal) bool {
+		return !slices.Contains(newIDs, g.ID)
+	})
+
+	resp := goalRolloverResponse{
+		Year:      year + 1,
+		Rollovers: rollovers,
+		Goals:     goals,
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, resp); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"juhojarvi/habits/internal/store"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -16,14 +16,28 @@ type goalKey string
 const goalCtxKey goalKey = "goal"
 
 type CreateGoalPayload struct {
-	Year        int    `json:"year" validate:"required,min=2020,max=2100"`
-	Category    string `json:"category" validate:"required,oneof=career financial health learning"`
-	Description string `json:"description" validate:"required,max=500"`
+	Year           int      `json:"year" validate:"required,min=2020,max=2100"`
+	CategoryID     *int64   `json:"category_id"`
+	Category       string   `json:"category" validate:"required_without=CategoryID,max=50"` // category name, accepted for older clients
+	Description    string   `json:"description" validate:"required,max=500"`
+	TargetQuantity *int     `json:"target_quantity" validate:"omitempty,min=1"`
+	StartValue     *float64 `json:"start_value"`
+	TargetValue    *float64 `json:"target_value"`
+	Unit           string   `json:"unit" validate:"max=20"`
+	Deadline       *string  `json:"deadline"` // Format: 2006-01-02
 }
 
+// UpdateGoalPayload leaves missing fields as they are; null in start_value,
+// target_value, unit or deadline clears the field.
 type UpdateGoalPayload struct {
-	Description string `json:"description" validate:"required,max=500"`
-	Completed   *bool  `json:"completed"`
+	CategoryID     *int64            `json:"category_id"`
+	Description    string            `json:"description" validate:"required,max=500"`
+	Completed      *bool             `json:"completed"`
+	TargetQuantity *int              `json:"target_quantity" validate:"omitempty,min=1"`
+	StartValue     nullable[float64] `json:"start_value"`
+	TargetValue    nullable[float64] `json:"target_value"`
+	Unit           nullable[string]  `json:"unit" validate:"omitempty,max=20"`
+	Deadline       nullable[string]  `json:"deadline"` // Format: 2006-01-02
 }
 
 func (api *api) createGoalHandler(w http.ResponseWriter, r *http.Request) {
@@ -38,24 +52,40 @@ func (api *api) createGoalHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := getUserFromContext(r)
-
-	goal := &store.Goal{
-		UserID:      user.ID,
-		Year:        payload.Year,
-		Category:    payload.Category,
-		Description: payload.Description,
+	deadline, err := parseOptionalDate(payload.Deadline)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
 	}
 
+	user := getUserFromContext(r)
 	ctx := r.Context()
 
-	if err := api.store.Goals.Create(ctx, goal); err != nil {
-		// Check for duplicate key constraint violation
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") ||
-			strings.Contains(err.Error(), "goals_user_id_year_category_key") {
-			api.conflictError(w, r, errors.New("you already have a goal for this category in this year"))
-			return
+	category, err := api.resolveGoalCategory(ctx, user.ID, payload.CategoryID, payload.Category)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.badRequestError(w, r, errors.New("invalid category"))
+		default:
+			api.internalServerError(w, r, err)
 		}
+		return
+	}
+
+	goal := &store.Goal{
+		UserID:         user.ID,
+		Year:           payload.Year,
+		CategoryID:     category.ID,
+		Category:       category.Name,
+		Description:    payload.Description,
+		TargetQuantity: payload.TargetQuantity,
+		StartValue:     payload.StartValue,
+		TargetValue:    payload.TargetValue,
+		Unit:           payload.Unit,
+		Deadline:       deadline,
+	}
+
+	if err := api.store.Goals.Create(ctx, goal, store.NewEvent("goal.created", goal)); err != nil {
 		api.internalServerError(w, r, err)
 		return
 	}
@@ -68,6 +98,22 @@ func (api *api) createGoalHandler(w http.ResponseWriter, r *http.Request) {
 
 func (api *api) getGoalHandler(w http.ResponseWriter, r *http.Request) {
 	goal := getGoalFromContext(r)
+	user := getUserFromContext(r)
+
+	ctx := r.Context()
+
+	progress, err := api.store.Goals.GetProgress(ctx, user.ID, []int64{goal.ID}, localDate(time.Now(), user.Location()))
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+	goal.Progress = progress[goal.ID]
+
+	goal.Milestones, err = api.store.GoalMilestones.GetByGoal(ctx, goal.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
 
 	if err := api.jsonResponse(w, http.StatusOK, goal); err != nil {
 		api.internalServerError(w, r, err)
@@ -92,6 +138,21 @@ func (api *api) getGoalsByYearHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	goalIDs := make([]int64, len(goals))
+	for i := range goals {
+		goalIDs[i] = goals[i].ID
+	}
+
+	progress, err := api.store.Goals.GetProgress(ctx, user.ID, goalIDs, localDate(time.Now(), user.Location()))
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range goals {
+		goals[i].Progress = progress[goals[i].ID]
+	}
+
 	if err := api.jsonResponse(w, http.StatusOK, goals); err != nil {
 		api.internalServerError(w, r, err)
 		return
@@ -112,12 +173,57 @@ func (api *api) updateGoalHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	wasCompleted := goal.Completed != nil && *goal.Completed
+
 	goal.Description = payload.Description
 	goal.Completed = payload.Completed
+	if payload.TargetQuantity != nil {
+		goal.TargetQuantity = payload.TargetQuantity
+	}
+	if payload.StartValue.set {
+		goal.StartValue = payload.StartValue.value
+	}
+	if payload.TargetValue.set {
+		goal.TargetValue = payload.TargetValue.value
+	}
+	if payload.Unit.set {
+		goal.Unit = ""
+		if payload.Unit.value != nil {
+			goal.Unit = *payload.Unit.value
+		}
+	}
+	if payload.Deadline.set {
+		deadline, err := parseOptionalDate(payload.Deadline.value)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+		goal.Deadline = deadline
+	}
 
 	ctx := r.Context()
 
-	if err := api.store.Goals.Update(ctx, goal); err != nil {
+	if payload.CategoryID != nil {
+		category, err := api.store.GoalCategories.GetByID(ctx, *payload.CategoryID, goal.UserID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				api.badRequestError(w, r, errors.New("invalid category"))
+			default:
+				api.internalServerError(w, r, err)
+			}
+			return
+		}
+		goal.CategoryID = category.ID
+		goal.Category = category.Name
+	}
+
+	var event *store.Event
+	if !wasCompleted && goal.Completed != nil && *goal.Completed {
+		event = store.NewEvent("goal.completed", goal)
+	}
+
+	if err := api.store.Goals.Update(ctx, goal, event); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			api.notFoundError(w, r, err)
@@ -144,7 +250,8 @@ func (api *api) deleteGoalHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromContext(r)
 	ctx := r.Context()
 
-	if err := api.store.Goals.Delete(ctx, id, user.ID); err != nil {
+	event := store.NewEvent("goal.deleted", map[string]int64{"id": id})
+	if err := api.store.Goals.Delete(ctx, id, user.ID, event); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			api.notFoundError(w, r, err)
@@ -199,3 +306,17 @@ func getGoalFromContext(r *http.Request) *store.Goal {
 	goal, _ := r.Context().Value(goalCtxKey).(*store.Goal)
 	return goal
 }
+
+// parseOptionalDate parses a 2006-01-02 date; nil or empty means no date.
+func parseOptionalDate(s *string) (*time.Time, error) {
+	if s == nil || *s == "" {
+		return nil, nil
+	}
+
+	date, err := time.Parse("2006-01-02", *s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &date, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type habitGroupKey string
+
+const habitGroupCtxKey habitGroupKey = "habitGroup"
+
+type CreateHabitGroupPayload struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type UpdateHabitGroupPayload struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type ReorderHabitGroupsPayload struct {
+	GroupIDs []int64 `json:"group_ids" validate:"required,min=1,unique"`
+}
+
+func (api *api) createHabitGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateHabitGroupPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	group := &store.HabitGroup{
+		UserID: user.ID,
+		Name:   payload.Name,
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.HabitGroups.Create(ctx, group); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, group); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) getHabitGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	groups, err := api.store.HabitGroups.GetByUser(ctx, user.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, groups); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) updateHabitGroupHandler(w http.ResponseWriter, r *http.Request) {
+	group := getHabitGroupFromCtx(r)
+
+	var payload UpdateHabitGroupPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	group.Name = payload.Name
+
+	ctx := r.Context()
+
+	if err := api.store.HabitGroups.Update(ctx, group); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, group); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) deleteHabitGroupHandler(w http.ResponseWriter, r *http.Request) {
+	group := getHabitGroupFromCtx(r)
+	ctx := r.Context()
+
+	if err := api.store.HabitGroups.Delete(ctx, group.ID, group.UserID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *api) reorderHabitGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload ReorderHabitGroupsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.HabitGroups.Reorder(ctx, user.ID, payload.GroupIDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.badRequestError(w, r, errors.New("invalid group"))
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *api) habitGroupContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idParam := chi.URLParam(r, "groupID")
+		id, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+
+		user := getUserFromContext(r)
+		ctx := r.Context()
+
+		group, err := api.store.HabitGroups.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				api.notFoundError(w, r, err)
+			default:
+				api.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, habitGroupCtxKey, group)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getHabitGroupFromCtx(r *http.Request) *store.HabitGroup {
+	group, _ := r.Context().Value(habitGroupCtxKey).(*store.HabitGroup)
+	return group
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SkipHabitPayload struct {
+	Date string `json:"date" validate:"required"` // Format: 2006-01-02
+}
+
+// Skip a habit for a day (e.g. sick or travelling). Skipped days don't count
+// against the completion rate or break the streak.
+func (api *api) skipHabitHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+	user := getUserFromContext(r)
+
+	var payload SkipHabitPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", payload.Date)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	// Days can be skipped ahead of time, but locked history stays as it is
+	if err := api.config.completion.canUnmark(date, time.Now(), user.Location()); err != nil && !errors.Is(err, errFutureCompletion) {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	skip, err := api.store.HabitSkips.Create(ctx, habit.ID, user.ID, date)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, skip); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Remove a skipped day
+func (api *api) unskipHabitHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+	user := getUserFromContext(r)
+
+	date, err := time.Parse("2006-01-02", chi.URLParam(r, "date"))
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := api.config.completion.canUnmark(date, time.Now(), user.Location()); err != nil && !errors.Is(err, errFutureCompletion) {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.HabitSkips.Delete(ctx, habit.ID, user.ID, date); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
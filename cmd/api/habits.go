@@ -15,9 +15,12 @@ type habitKey string
 const habitCtxKey habitKey = "habit"
 
 type CreateHabitPayload struct {
-	Name   string `json:"name" validate:"required,max=50"`
-	Impact string `json:"impact" validate:"required,max=25"`
-	GoalID *int64 `json:"goal_id"`
+	Name         string  `json:"name" validate:"required,max=50"`
+	Impact       string  `json:"impact" validate:"required,max=25"`
+	GoalID       *int64  `json:"goal_id"`
+	GroupID      *int64  `json:"group_id"`
+	ScheduleDays []int64 `json:"schedule_days" validate:"omitempty,unique,dive,min=1,max=7"` // ISO weekdays, empty = every day
+	Target       int     `json:"target" validate:"omitempty,min=1,max=1000"`
 }
 
 func (api *api) createHabitHandler(w http.ResponseWriter, r *http.Request) {
@@ -55,16 +58,41 @@ func (api *api) createHabitHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if payload.GroupID != nil {
+		if _, err := api.store.HabitGroups.GetByID(r.Context(), *payload.GroupID, user.ID); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				api.badRequestError(w, r, errors.New("invalid group"))
+			default:
+				api.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
 	habit := &store.Habit{
-		Name:   payload.Name,
-		Impact: payload.Impact,
-		UserID: user.ID,
-		GoalID: payload.GoalID,
+		Name:    payload.Name,
+		Impact:  payload.Impact,
+		UserID:  user.ID,
+		GoalID:  payload.GoalID,
+		GroupID: payload.GroupID,
+		TagIDs:  []int64{},
+		// Defaults: due every day, done once
+		ScheduleDays: []int64{},
+		Target:       1,
+	}
+
+	if payload.ScheduleDays != nil {
+		habit.ScheduleDays = payload.ScheduleDays
+	}
+
+	if payload.Target != 0 {
+		habit.Target = payload.Target
 	}
 
 	ctx := r.Context()
 
-	if err := api.store.Habits.Create(ctx, habit); err != nil {
+	if err := api.store.Habits.Create(ctx, habit, store.NewEvent("habit.created", habit)); err != nil {
 		api.internalServerError(w, r, err)
 		return
 	}
@@ -127,7 +155,7 @@ func (api *api) deleteHabitHandler(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	err := api.store.Habits.Delete(ctx, habit.ID, user.ID)
+	err := api.store.Habits.Delete(ctx, habit.ID, user.ID, store.NewEvent("habit.deleted", habit))
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
@@ -145,6 +173,10 @@ type UpdateHabitPayload struct {
 	Name   *string `json:"name" validate:"omitempty,max=50"`
 	Impact *string `json:"impact" validate:"omitempty,max=25"`
 	GoalID *int64  `json:"goal_id"`
+	// GroupID null or 0 takes the habit out of its group
+	GroupID      nullable[int64] `json:"group_id"`
+	ScheduleDays []int64         `json:"schedule_days" validate:"omitempty,unique,dive,min=1,max=7"` // ISO weekdays, empty = every day
+	Target       *int            `json:"target" validate:"omitempty,min=1,max=1000"`
 }
 
 func (api *api) updateHabitHandler(w http.ResponseWriter, r *http.Request) {
@@ -174,6 +206,14 @@ func (api *api) updateHabitHandler(w http.ResponseWriter, r *http.Request) {
 		habit.Impact = *payload.Impact
 	}
 
+	if payload.ScheduleDays != nil {
+		habit.ScheduleDays = payload.ScheduleDays
+	}
+
+	if payload.Target != nil {
+		habit.Target = *payload.Target
+	}
+
 	if payload.GoalID != nil {
 		habit.GoalID = payload.GoalID
 		if payload.GoalID != nil {
@@ -194,9 +234,29 @@ func (api *api) updateHabitHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if payload.GroupID.set {
+		groupID := payload.GroupID.value
+		if groupID != nil && *groupID == 0 {
+			groupID = nil
+		}
+
+		if groupID != nil {
+			if _, err := api.store.HabitGroups.GetByID(r.Context(), *groupID, user.ID); err != nil {
+				switch {
+				case errors.Is(err, store.ErrNotFound):
+					api.badRequestError(w, r, errors.New("invalid group"))
+				default:
+					api.internalServerError(w, r, err)
+				}
+				return
+			}
+		}
+		habit.GroupID = groupID
+	}
+
 	api.logger.Info("Updating habit", "id", habit.ID, "version", habit.Version, "impact", habit.Impact)
 
-	if err := api.store.Habits.Update(r.Context(), habit, user.ID); err != nil {
+	if err := api.store.Habits.Update(r.Context(), habit, user.ID, store.NewEvent("habit.updated", habit)); err != nil {
 		switch err {
 		case store.ErrNotFound:
 			api.notFoundResponseError(w, r, err)
@@ -211,6 +271,69 @@ func (api *api) updateHabitHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type HabitOrderItem struct {
+	ID      int64  `json:"id" validate:"required"`
+	GroupID *int64 `json:"group_id"`
+}
+
+type ReorderHabitsPayload struct {
+	Habits []HabitOrderItem `json:"habits" validate:"required,min=1,dive"`
+}
+
+// Järjestää käyttäjän tavat uudelleen. Tavan paikka on sen indeksi oman ryhmänsä sisällä;
+// listasta puuttuvat tavat jäävät ryhmässään listattujen perään.
+func (api *api) reorderHabitsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload ReorderHabitsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	positions := make([]store.HabitPosition, 0, len(payload.Habits))
+	next := map[int64]int{}
+	seen := map[int64]bool{}
+	for _, item := range payload.Habits {
+		if seen[item.ID] {
+			api.badRequestError(w, r, errors.New("duplicate habit in order"))
+			return
+		}
+		seen[item.ID] = true
+
+		var groupKey int64
+		if item.GroupID != nil {
+			groupKey = *item.GroupID
+		}
+
+		positions = append(positions, store.HabitPosition{
+			ID:       item.ID,
+			GroupID:  item.GroupID,
+			Position: next[groupKey],
+		})
+		next[groupKey]++
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.Habits.Reorder(ctx, user.ID, positions); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.badRequestError(w, r, errors.New("invalid habit or group"))
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func getHabitFromCtx(r *http.Request) *store.Habit {
 	habit, _ := r.Context().Value(habitCtxKey).(*store.Habit)
 	return habit
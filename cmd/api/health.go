@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Build info, injected at link time:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    = ""
+	buildTime = ""
+)
+
+// readyTimeout bounds all readiness checks together, below the Fly check timeout
+const readyTimeout = 3 * time.Second
+
+type healthStatus struct {
+	Status string `json:"status"`
+}
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// readiness lists each check with "ok" or the reason it failed
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Liveness: the process is up and serving requests
+func (api *api) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if err := api.jsonResponse(w, http.StatusOK, healthStatus{Status: "ok"}); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+// Readiness: the instance can take traffic. Answers 503 with the failed checks
+// so the load balancer stops routing to it, also while shutting down.
+func (api *api) readyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	checks := map[string]error{
+		"shutdown":   api.checkShutdown(),
+		"database":   api.checkDatabase(ctx),
+		"migrations": api.checkMigrations(ctx),
+		"mailer":     api.checkMailer(),
+	}
+
+	resp := readiness{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+	for name, err := range checks {
+		if err != nil {
+			resp.Checks[name] = err.Error()
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[name] = "ok"
+	}
+
+	if status != http.StatusOK {
+		api.logger.Warnw("not ready", "checks", resp.Checks)
+	}
+
+	if err := api.jsonResponse(w, status, resp); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+func (api *api) checkShutdown() error {
+	if api.draining.Load() {
+		return errors.New("shutting down")
+	}
+	return nil
+}
+
+func (api *api) checkDatabase(ctx context.Context) error {
+	if api.db == nil {
+		return errors.New("not configured")
+	}
+	return api.db.PingContext(ctx)
+}
+
+// checkMigrations fails while the schema is behind the migrations built into
+// this binary, or a migration failed half way
+func (api *api) checkMigrations(ctx context.Context) error {
+	if api.migrator == nil {
+		return errors.New("not configured")
+	}
+
+	current, dirty, err := api.migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("version %d is dirty", current)
+	}
+	if latest := api.migrator.Latest(); current < latest {
+		return fmt.Errorf("at version %d, want %d", current, latest)
+	}
+
+	return nil
+}
+
+func (api *api) checkMailer() error {
+	if api.mailer == nil {
+		return errors.New("not configured")
+	}
+	if api.config.mail.fromEmail == "" {
+		return errors.New("FROM_EMAIL is not set")
+	}
+	return nil
+}
+
+// Get the version and commit of the running build
+func (api *api) versionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := api.jsonResponse(w, http.StatusOK, currentBuild()); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+// currentBuild falls back to the VCS revision Go stamps into binaries built
+// from a checkout when the commit wasn't injected
+func currentBuild() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, BuildTime: buildTime, GoVersion: runtime.Version()}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+
+	return info
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestHealthAndVersion(t *testing.T) {
+	api := &api{config: config{env: "test"}, logger: zap.NewNop().Sugar()}
+	h := api.mount()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("health: want %d got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/version", nil))
+	var body struct {
+		Data buildInfo `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || body.Data.Version != version || body.Data.Commit == "" || body.Data.GoVersion == "" {
+		t.Fatalf("version: got %d %+v", rr.Code, body.Data)
+	}
+}
+
+func TestReadyFailsWithoutDependencies(t *testing.T) {
+	api := &api{config: config{env: "test"}, logger: zap.NewNop().Sugar()}
+
+	rr := httptest.NewRecorder()
+	api.mount().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/ready", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("want %d got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	var body struct {
+		Data readiness `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range []string{"database", "migrations", "mailer"} {
+		if got := body.Data.Checks[check]; got == "" || got == "ok" {
+			t.Errorf("%s: want a failure, got %q", check, got)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type heatmapResponse struct {
+	Year   int                 `json:"year"`
+	Levels int                 `json:"levels"`
+	Days   []store.HeatmapCell `json:"days"`
+}
+
+// Get a yearly heatmap of all the user's habits
+func (api *api) getMyHeatmapHandler(w http.ResponseWriter, r *http.Request) {
+	api.heatmapResponse(w, r, nil)
+}
+
+// Get a yearly heatmap of a single habit
+func (api *api) getHabitHeatmapHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+	api.heatmapResponse(w, r, &habit.ID)
+}
+
+func (api *api) heatmapResponse(w http.ResponseWriter, r *http.Request, habitID *int64) {
+	user := getUserFromContext(r)
+
+	// Default to the current year in the user's timezone
+	year := localDate(time.Now(), user.Location()).Year()
+	if yearStr := r.URL.Query().Get("year"); yearStr != "" {
+		y, err := strconv.Atoi(yearStr)
+		if err != nil || y < 2000 || y > 2100 {
+			api.badRequestError(w, r, errors.New("invalid year"))
+			return
+		}
+		year = y
+	}
+
+	startDate := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	ctx := r.Context()
+
+	cells, err := api.store.HabitCompletions.GetHeatmap(ctx, user.ID, habitID, startDate, endDate)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	resp := heatmapResponse{
+		Year:   year,
+		Levels: store.HeatmapLevels,
+		Days:   cells,
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, resp); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
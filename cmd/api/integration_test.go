@@ -2,18 +2,28 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"juhojarvi/habits/cmd/migrate/migrations"
 	"juhojarvi/habits/internal/auth"
 	"juhojarvi/habits/internal/db"
+	"juhojarvi/habits/internal/mailer"
+	"juhojarvi/habits/internal/migrate"
+	"juhojarvi/habits/internal/netguard"
+	"juhojarvi/habits/internal/notify"
 	"juhojarvi/habits/internal/store"
+	"juhojarvi/habits/internal/webhook"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -21,13 +31,42 @@ import (
 
 type stubMailer struct{}
 
-func (m stubMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+func (m stubMailer) Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error) {
+	return http.StatusOK, nil
+}
+
+// recordingMailer records every send and fails while err is set.
+type recordingMailer struct {
+	sent []recordedMail
+	err  error
+}
+
+type recordedMail struct {
+	template string
+	locale   string
+	email    string
+	data     any
+}
+
+func (m *recordingMailer) Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error) {
+	m.sent = append(m.sent, recordedMail{template: templateFile, locale: locale, email: email, data: data})
+	if m.err != nil {
+		return -1, m.err
+	}
 	return http.StatusOK, nil
 }
 
 func newTestHandler(t *testing.T) (http.Handler, func()) {
 	t.Helper()
 
+	api, cleanup := newTestAPI(t)
+	return api.mount(), cleanup
+}
+
+// newTestAPI is newTestHandler for tests that also run background jobs.
+func newTestAPI(t *testing.T) (*api, func()) {
+	t.Helper()
+
 	dbAddr := os.Getenv("TEST_DB_ADDR")
 	if dbAddr == "" {
 		dbAddr = os.Getenv("DB_ADDR")
@@ -41,6 +80,16 @@ func newTestHandler(t *testing.T) (http.Handler, func()) {
 		t.Fatalf("connect db: %v", err)
 	}
 
+	migrator, err := migrate.New(sqlDB, migrations.FS)
+	if err != nil {
+		sqlDB.Close()
+		t.Fatalf("migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		sqlDB.Close()
+		t.Fatalf("migrate: %v", err)
+	}
+
 	if err := resetDB(sqlDB); err != nil {
 		sqlDB.Close()
 		t.Fatalf("reset db: %v", err)
@@ -48,24 +97,50 @@ func newTestHandler(t *testing.T) (http.Handler, func()) {
 
 	jwtAuthenticator := auth.NewJWTAuthenticator("test-secret", "habits", "habits")
 
+	vapidPublicKey, vapidPrivateKey, err := notify.GenerateVAPIDKeys()
+	if err != nil {
+		sqlDB.Close()
+		t.Fatalf("vapid keys: %v", err)
+	}
+	vapid, err := notify.NewVAPID(vapidPublicKey, vapidPrivateKey, "mailto:no-reply@example.test")
+	if err != nil {
+		sqlDB.Close()
+		t.Fatalf("vapid: %v", err)
+	}
+	storage := store.NewStorage(sqlDB)
+
+	// Test receivers listen on loopback
+	outbound, err := netguard.New("127.0.0.1")
+	if err != nil {
+		sqlDB.Close()
+		t.Fatalf("outbound: %v", err)
+	}
+
 	api := &api{
 		config: config{
 			env:         "test",
 			frontendURL: "http://example.test",
 			apiURL:      "http://example.test",
 			mail: mailConfig{
-				fromEmail: "no-reply@example.test",
-				exp:       time.Hour,
+				fromEmail:         "no-reply@example.test",
+				exp:               time.Hour,
+				unsubscribeSecret: "test-secret",
 			},
-			auth: authConfig{token: tokenConfig{secret: "test-secret", exp: time.Hour, iss: "habits"}},
+			auth:       authConfig{token: tokenConfig{secret: "test-secret", exp: time.Hour, iss: "habits"}},
+			completion: completionPolicy{backfillDays: 7},
+			push:       pushConfig{vapidPublicKey: vapidPublicKey},
 		},
-		store:         store.NewStorage(sqlDB),
+		store:         storage,
 		logger:        zap.NewNop().Sugar(),
 		mailer:        stubMailer{},
+		webPush:       notify.NewWebPushChannel(storage.PushSubscriptions, vapid, outbound),
 		authenticator: jwtAuthenticator,
+		outbound:      outbound,
+		db:            sqlDB,
+		migrator:      migrator,
 	}
 
-	return api.mount(), func() {
+	return api, func() {
 		_ = sqlDB.Close()
 	}
 }
@@ -74,9 +149,23 @@ func resetDB(db *sql.DB) error {
 	// Keep this list aligned with migrations in cmd/migrate/migrations.
 	_, err := db.Exec(`
 		TRUNCATE TABLE
+			outbox,
+			webhook_deliveries,
+			webhooks,
+			push_subscriptions,
+			reminder_deliveries,
+			habit_reminders,
 			habit_completions,
+			habit_skips,
+			habit_tags,
+			tags,
 			habits,
+			habit_groups,
+			goal_checkins,
+			goal_milestones,
 			goals,
+			goal_categories,
+			email_deliveries,
 			password_reset_tokens,
 			user_invitations,
 			users
@@ -196,6 +285,113 @@ func TestAuth_RegisterActivateLoginAndMe(t *testing.T) {
 	}
 }
 
+func TestUsers_TimezoneMustBeKnownToPostgres(t *testing.T) {
+	api, cleanup := newTestAPI(t)
+	defer cleanup()
+	handler := api.mount()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	for _, tz := range []string{"Local", "Not/AZone"} {
+		status, body := doJSON(t, handler, http.MethodPatch, "/v1/users/me/timezone", map[string]any{"timezone": tz}, token)
+		if status != http.StatusBadRequest {
+			t.Fatalf("timezone %q: want %d got %d body=%s", tz, http.StatusBadRequest, status, string(body))
+		}
+	}
+
+	status, body := doJSON(t, handler, http.MethodPatch, "/v1/users/me/timezone", map[string]any{"timezone": "Europe/Helsinki"}, token)
+	if status != http.StatusOK {
+		t.Fatalf("timezone: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	// the trigger keeps out values that skip the handler
+	if _, err := api.db.Exec(`UPDATE users SET timezone = 'Local'`); err == nil {
+		t.Fatal("update users.timezone to Local: want the trigger to refuse it")
+	}
+}
+
+func TestReady(t *testing.T) {
+	api, cleanup := newTestAPI(t)
+	defer cleanup()
+
+	status, body := doJSON(t, api.mount(), http.MethodGet, "/v1/ready", nil, "")
+	if status != http.StatusOK {
+		t.Fatalf("ready: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+}
+
+func TestAuth_RegisterQueuesWelcomeMailInOutbox(t *testing.T) {
+	api, cleanup := newTestAPI(t)
+	defer cleanup()
+	handler := api.mount()
+
+	mail := &recordingMailer{err: errors.New("mail provider is down")}
+	api.mailer = mail
+
+	email := fmt.Sprintf("u_%s@example.test", uuid.NewString())
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/authentication/user", map[string]any{
+		"username": fmt.Sprintf("u_%s", uuid.NewString()),
+		"email":    email,
+		"password": "pass123",
+		"locale":   "fi",
+	}, "")
+	if status != http.StatusCreated {
+		t.Fatalf("register: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var reg struct {
+		Token string `json:"token"`
+	}
+	decodeData(t, body, &reg)
+
+	if len(mail.sent) != 0 {
+		t.Fatalf("register: want no synchronous send got %d", len(mail.sent))
+	}
+
+	// The provider is down: the send fails and is retried later, the user stays
+	if err := api.dispatchOutbox(context.Background()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if len(mail.sent) != 1 || mail.sent[0].template != mailer.UserWelcomeTemplate || mail.sent[0].email != email || mail.sent[0].locale != "fi" {
+		t.Fatalf("dispatch: got %+v", mail.sent)
+	}
+
+	data, _ := mail.sent[0].data.(map[string]any)
+	if data["ActivationURL"] != "http://example.test/confirm/"+reg.Token {
+		t.Fatalf("dispatch: want activation url for the token got %v", data)
+	}
+
+	// The retry is backed off, so an immediate second run sends nothing
+	if err := api.dispatchOutbox(context.Background()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if len(mail.sent) != 1 {
+		t.Fatalf("dispatch: want retry to wait for backoff got %d sends", len(mail.sent))
+	}
+
+	// On the last attempt the message is given up on and the activation link dropped
+	if _, err := api.db.Exec(`UPDATE outbox SET attempts = $1, next_attempt_at = NOW()`, mailer.MaxRetries-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.dispatchOutbox(context.Background()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+
+	var outboxStatus string
+	var hasData bool
+	if err := api.db.QueryRow(`SELECT status, payload ? 'data' FROM outbox`).Scan(&outboxStatus, &hasData); err != nil {
+		t.Fatal(err)
+	}
+	if outboxStatus != "failed" || hasData {
+		t.Fatalf("after the last attempt: want failed without data got %s, data %v", outboxStatus, hasData)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPut, "/v1/users/activate/"+reg.Token, nil, "")
+	if status != http.StatusNoContent {
+		t.Fatalf("activate: want %d got %d body=%s", http.StatusNoContent, status, string(body))
+	}
+}
+
 func TestHabits_AreScopedToAuthenticatedUser(t *testing.T) {
 	handler, cleanup := newTestHandler(t)
 	defer cleanup()
@@ -258,3 +454,1496 @@ func TestHabits_CannotLinkGoalOwnedByAnotherUser(t *testing.T) {
 		t.Fatalf("link other user's goal: want %d got %d", http.StatusForbidden, status)
 	}
 }
+
+func TestGoals_ProgressFromLinkedHabits(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	now := time.Now().UTC()
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/goals", map[string]any{
+		"year":            now.Year(),
+		"category":        "health",
+		"description":     "Run 500 km",
+		"target_quantity": 500,
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create goal: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var goal struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &goal)
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":    "Run",
+		"impact":  "positive",
+		"goal_id": goal.ID,
+		"target":  5,
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/habits/%d/complete", habit.ID), map[string]any{
+		"date":   now.Format("2006-01-02"),
+		"amount": 8,
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("complete: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/goals/%d", goal.ID), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("get goal: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var got struct {
+		Progress struct {
+			HabitIDs      []int64 `json:"habit_ids"`
+			PossibleDays  int     `json:"possible_days"`
+			CompletedDays int     `json:"completed_days"`
+			Quantity      int     `json:"quantity"`
+			OnTrack       *bool   `json:"on_track"`
+		} `json:"progress"`
+	}
+	decodeData(t, body, &got)
+
+	p := got.Progress
+	if len(p.HabitIDs) != 1 || p.HabitIDs[0] != habit.ID {
+		t.Fatalf("progress: want linked habit %d got %v", habit.ID, p.HabitIDs)
+	}
+	if p.PossibleDays != 1 || p.CompletedDays != 1 || p.Quantity != 8 {
+		t.Fatalf("progress: unexpected values %+v", p)
+	}
+	if p.OnTrack == nil {
+		t.Fatalf("progress: want on_track for a goal with a target")
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/goals/year/%d", now.Year()), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("get goals by year: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var goals []struct {
+		Progress *struct {
+			Quantity int `json:"quantity"`
+		} `json:"progress"`
+	}
+	decodeData(t, body, &goals)
+
+	if len(goals) != 1 || goals[0].Progress == nil || goals[0].Progress.Quantity != 8 {
+		t.Fatalf("get goals by year: unexpected progress %+v", goals)
+	}
+}
+
+func TestGoals_MeasurableTargetsMilestonesAndCheckins(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	year := time.Now().Year()
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/goals", map[string]any{
+		"year":         year,
+		"category":     "financial",
+		"description":  "Save for a trip",
+		"start_value":  0,
+		"target_value": 5000,
+		"unit":         "EUR",
+		"deadline":     fmt.Sprintf("%d-10-31", year),
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create goal: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var goal struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &goal)
+
+	for _, value := range []float64{1200, 1850.5} {
+		status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/goals/%d/checkins", goal.ID), map[string]any{
+			"value": value,
+		}, token)
+		if status != http.StatusCreated {
+			t.Fatalf("checkin: want %d got %d body=%s", http.StatusCreated, status, string(body))
+		}
+	}
+
+	status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/goals/%d/milestones", goal.ID), map[string]any{
+		"title":    "First 2500",
+		"due_date": fmt.Sprintf("%d-06-30", year),
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create milestone: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var milestone struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &milestone)
+
+	status, body = doJSON(t, handler, http.MethodPatch, fmt.Sprintf("/v1/goals/%d/milestones/%d", goal.ID, milestone.ID), map[string]any{
+		"completed": true,
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("complete milestone: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/goals/%d", goal.ID), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("get goal: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var got struct {
+		TargetValue  *float64 `json:"target_value"`
+		CurrentValue *float64 `json:"current_value"`
+		Unit         string   `json:"unit"`
+		Milestones   []struct {
+			Completed   bool    `json:"completed"`
+			CompletedAt *string `json:"completed_at"`
+		} `json:"milestones"`
+	}
+	decodeData(t, body, &got)
+
+	if got.TargetValue == nil || *got.TargetValue != 5000 || got.Unit != "EUR" {
+		t.Fatalf("get goal: unexpected target %+v", got)
+	}
+	if got.CurrentValue == nil || *got.CurrentValue != 1850.5 {
+		t.Fatalf("get goal: want current_value 1850.5 got %v", got.CurrentValue)
+	}
+	if len(got.Milestones) != 1 || !got.Milestones[0].Completed || got.Milestones[0].CompletedAt == nil {
+		t.Fatalf("get goal: unexpected milestones %+v", got.Milestones)
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/goals/%d/checkins", goal.ID), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("get checkins: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var checkins []struct {
+		Value float64 `json:"value"`
+	}
+	decodeData(t, body, &checkins)
+
+	if len(checkins) != 2 {
+		t.Fatalf("get checkins: want 2 got %d", len(checkins))
+	}
+
+	// a missing field is left as is, null clears it
+	status, body = doJSON(t, handler, http.MethodPatch, fmt.Sprintf("/v1/goals/%d", goal.ID), map[string]any{
+		"description": "Save for a trip",
+		"start_value": nil,
+		"unit":        nil,
+		"deadline":    nil,
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("clear goal fields: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var cleared struct {
+		StartValue  *float64 `json:"start_value"`
+		TargetValue *float64 `json:"target_value"`
+		Unit        string   `json:"unit"`
+		Deadline    *string  `json:"deadline"`
+	}
+	decodeData(t, body, &cleared)
+
+	if cleared.StartValue != nil || cleared.Unit != "" || cleared.Deadline != nil {
+		t.Fatalf("clear goal fields: want start_value, unit and deadline cleared got %+v", cleared)
+	}
+	if cleared.TargetValue == nil || *cleared.TargetValue != 5000 {
+		t.Fatalf("clear goal fields: want target_value kept got %v", cleared.TargetValue)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPatch, fmt.Sprintf("/v1/goals/%d", goal.ID), map[string]any{
+		"description":  "Save for a trip",
+		"target_value": nil,
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("clear target_value: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+	cleared.TargetValue = nil
+	decodeData(t, body, &cleared)
+	if cleared.TargetValue != nil {
+		t.Fatalf("clear target_value: want null got %v", *cleared.TargetValue)
+	}
+}
+
+func TestGoalCategories_DefaultsCustomAndSharedCategory(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodGet, "/v1/goal-categories", nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("list categories: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var defaults []struct {
+		Name string `json:"name"`
+	}
+	decodeData(t, body, &defaults)
+
+	if len(defaults) != len(store.DefaultGoalCategories) {
+		t.Fatalf("list categories: want %d defaults got %d", len(store.DefaultGoalCategories), len(defaults))
+	}
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/goal-categories", map[string]any{
+		"name":  "hobbies",
+		"color": "#123abc",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create category: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var category struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &category)
+
+	status, _ = doJSON(t, handler, http.MethodPost, "/v1/goal-categories", map[string]any{
+		"name": "hobbies",
+	}, token)
+	if status != http.StatusConflict {
+		t.Fatalf("duplicate category: want %d got %d", http.StatusConflict, status)
+	}
+
+	// Several goals may share a category in the same year
+	for _, description := range []string{"Learn to juggle", "Build a birdhouse"} {
+		status, body = doJSON(t, handler, http.MethodPost, "/v1/goals", map[string]any{
+			"year":        time.Now().Year(),
+			"category_id": category.ID,
+			"description": description,
+		}, token)
+		if status != http.StatusCreated {
+			t.Fatalf("create goal: want %d got %d body=%s", http.StatusCreated, status, string(body))
+		}
+	}
+
+	status, _ = doJSON(t, handler, http.MethodDelete, fmt.Sprintf("/v1/goal-categories/%d", category.ID), nil, token)
+	if status != http.StatusConflict {
+		t.Fatalf("delete used category: want %d got %d", http.StatusConflict, status)
+	}
+}
+
+func TestGoals_ReviewAndRollover(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	year := time.Now().Year()
+
+	goalIDs := make([]int64, 2)
+	for i, description := range []string{"Run a marathon", "Read 20 books"} {
+		status, body := doJSON(t, handler, http.MethodPost, "/v1/goals", map[string]any{
+			"year":        year,
+			"category":    "health",
+			"description": description,
+		}, token)
+		if status != http.StatusCreated {
+			t.Fatalf("create goal: want %d got %d body=%s", http.StatusCreated, status, string(body))
+		}
+
+		var goal struct {
+			ID int64 `json:"id"`
+		}
+		decodeData(t, body, &goal)
+		goalIDs[i] = goal.ID
+	}
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":    "Run",
+		"impact":  "positive",
+		"goal_id": goalIDs[0],
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/goals/year/%d/review", year), map[string]any{
+		"goals": []map[string]any{
+			{"goal_id": goalIDs[0], "outcome": "partial", "reflection": "Got to 30 km"},
+			{"goal_id": goalIDs[1], "outcome": "achieved"},
+		},
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("review: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	rollover := func(id int64) (int, []byte) {
+		return doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/goals/year/%d/rollover", year), map[string]any{
+			"goal_ids": []int64{id},
+		}, token)
+	}
+
+	status, body = rollover(goalIDs[0])
+	if status != http.StatusCreated {
+		t.Fatalf("rollover: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var resp struct {
+		Goals []struct {
+			ID             int64  `json:"id"`
+			Year           int    `json:"year"`
+			RolledOverFrom *int64 `json:"rolled_over_from"`
+		} `json:"goals"`
+	}
+	decodeData(t, body, &resp)
+
+	if len(resp.Goals) != 1 || resp.Goals[0].Year != year+1 || resp.Goals[0].RolledOverFrom == nil || *resp.Goals[0].RolledOverFrom != goalIDs[0] {
+		t.Fatalf("rollover: unexpected goals %+v", resp.Goals)
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/habits/%d", habit.ID), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("get habit: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var linked struct {
+		GoalID *int64 `json:"goal_id"`
+	}
+	decodeData(t, body, &linked)
+
+	if linked.GoalID == nil || *linked.GoalID != resp.Goals[0].ID {
+		t.Fatalf("habit should be linked to the new goal %d, got %v", resp.Goals[0].ID, linked.GoalID)
+	}
+
+	if status, _ = rollover(goalIDs[0]); status != http.StatusConflict {
+		t.Fatalf("second rollover: want %d got %d", http.StatusConflict, status)
+	}
+
+	if status, _ = rollover(goalIDs[1]); status != http.StatusBadRequest {
+		t.Fatalf("rollover achieved goal: want %d got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestReview_YearSummary(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	now := time.Now().UTC()
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Meditate",
+		"impact": "positive",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/habits/%d/complete", habit.ID), map[string]any{
+		"date": now.Format("2006-01-02"),
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("complete: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/users/me/review/%d", now.Year()), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("review: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var review struct {
+		TotalCompletions int `json:"total_completions"`
+		BestStreaks      []struct {
+			HabitID       int64 `json:"habit_id"`
+			LongestStreak int   `json:"longest_streak"`
+		} `json:"best_streaks"`
+		Months []struct {
+			Month int `json:"month"`
+		} `json:"months"`
+	}
+	decodeData(t, body, &review)
+
+	if review.TotalCompletions != 1 {
+		t.Fatalf("review: want 1 completion got %d", review.TotalCompletions)
+	}
+	if len(review.BestStreaks) != 1 || review.BestStreaks[0].HabitID != habit.ID || review.BestStreaks[0].LongestStreak != 1 {
+		t.Fatalf("review: unexpected best streaks %+v", review.BestStreaks)
+	}
+	if len(review.Months) != 1 || review.Months[0].Month != int(now.Month()) {
+		t.Fatalf("review: unexpected months %+v", review.Months)
+	}
+}
+
+func TestHabits_ReorderIsReflectedInFeed(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/habit-groups", map[string]any{
+		"name": "Morning routine",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create group: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var group struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &group)
+
+	ids := make([]int64, 0, 3)
+	for _, name := range []string{"First", "Second", "Third"} {
+		status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+			"name":   name,
+			"impact": "positive",
+		}, token)
+		if status != http.StatusCreated {
+			t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+		}
+
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		decodeData(t, body, &created)
+		ids = append(ids, created.ID)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPut, "/v1/habits/order", map[string]any{
+		"habits": []map[string]any{
+			{"id": ids[2]},
+			{"id": ids[1], "group_id": group.ID},
+			{"id": ids[0]},
+		},
+	}, token)
+	if status != http.StatusNoContent {
+		t.Fatalf("reorder: want %d got %d body=%s", http.StatusNoContent, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, "/v1/users/feed", nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("feed: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var feed []struct {
+		ID      int64  `json:"id"`
+		GroupID *int64 `json:"group_id"`
+	}
+	decodeData(t, body, &feed)
+
+	want := []int64{ids[2], ids[0], ids[1]}
+	if len(feed) != len(want) {
+		t.Fatalf("feed length: want %d got %d", len(want), len(feed))
+	}
+	for i, id := range want {
+		if feed[i].ID != id {
+			t.Fatalf("feed[%d]: want habit %d got %d", i, id, feed[i].ID)
+		}
+	}
+
+	// A partial order moves the listed habits first; the rest follow in their old order
+	status, body = doJSON(t, handler, http.MethodPut, "/v1/habits/order", map[string]any{
+		"habits": []map[string]any{{"id": ids[0]}},
+	}, token)
+	if status != http.StatusNoContent {
+		t.Fatalf("partial reorder: want %d got %d body=%s", http.StatusNoContent, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, "/v1/users/feed", nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("feed: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var positioned []struct {
+		ID       int64 `json:"id"`
+		Position int   `json:"position"`
+	}
+	decodeData(t, body, &positioned)
+	if len(positioned) != 3 || positioned[0].ID != ids[0] || positioned[0].Position != 0 ||
+		positioned[1].ID != ids[2] || positioned[1].Position != 1 {
+		t.Fatalf("after partial reorder: want habits %d, %d at 0, 1 got %+v", ids[0], ids[2], positioned)
+	}
+
+	// An explicit null takes a habit out of its group, leaving out the field keeps it
+	status, body = doJSON(t, handler, http.MethodPatch, fmt.Sprintf("/v1/habits/%d", ids[1]), map[string]any{
+		"name": "Second",
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("update habit: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var updated struct {
+		GroupID *int64 `json:"group_id"`
+	}
+	decodeData(t, body, &updated)
+	if updated.GroupID == nil || *updated.GroupID != group.ID {
+		t.Fatalf("update without group_id: want group %d kept got %v", group.ID, updated.GroupID)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPatch, fmt.Sprintf("/v1/habits/%d", ids[1]), map[string]any{
+		"group_id": nil,
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("clear group: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	updated.GroupID = nil
+	decodeData(t, body, &updated)
+	if updated.GroupID != nil {
+		t.Fatalf("clear group: want no group got %d", *updated.GroupID)
+	}
+
+	_, otherToken := createActivatedUserAndToken(t, handler)
+	status, _ = doJSON(t, handler, http.MethodPut, "/v1/habits/order", map[string]any{
+		"habits": []map[string]any{{"id": ids[0]}},
+	}, otherToken)
+	if status != http.StatusBadRequest {
+		t.Fatalf("cross-user reorder: want %d got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestTags_FilterFeedByTag(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/tags", map[string]any{
+		"name":  "Health",
+		"color": "#22aa55",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create tag: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var tag struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &tag)
+
+	status, body = doJSON(t, handler, http.MethodPatch, fmt.Sprintf("/v1/tags/%d", tag.ID), map[string]any{"name": ""}, token)
+	if status != http.StatusBadRequest {
+		t.Fatalf("rename tag to empty: want %d got %d body=%s", http.StatusBadRequest, status, string(body))
+	}
+
+	ids := make([]int64, 0, 2)
+	for _, name := range []string{"Run", "Read"} {
+		status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+			"name":   name,
+			"impact": "positive",
+		}, token)
+		if status != http.StatusCreated {
+			t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+		}
+
+		var created struct {
+			ID int64 `json:"id"`
+		}
+		decodeData(t, body, &created)
+		ids = append(ids, created.ID)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPut, fmt.Sprintf("/v1/habits/%d/tags", ids[0]), map[string]any{
+		"tag_ids": []int64{tag.ID},
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("set tags: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/users/feed?tags=%d", tag.ID), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("feed: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var feed []struct {
+		ID     int64   `json:"id"`
+		TagIDs []int64 `json:"tag_ids"`
+	}
+	decodeData(t, body, &feed)
+	if len(feed) != 1 || feed[0].ID != ids[0] {
+		t.Fatalf("filtered feed: want only habit %d got %+v", ids[0], feed)
+	}
+
+	_, otherToken := createActivatedUserAndToken(t, handler)
+	status, _ = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/tags/%d", tag.ID), nil, otherToken)
+	if status != http.StatusNotFound {
+		t.Fatalf("cross-user get tag: want %d got %d", http.StatusNotFound, status)
+	}
+}
+
+func TestToday_ReturnsDueHabitsWithProgressAndStreak(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	today := time.Now()
+	weekday := int(today.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	otherDay := weekday%7 + 1
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Push-ups",
+		"impact": "positive",
+		"target": 3,
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":          "Not today",
+		"impact":        "positive",
+		"schedule_days": []int{otherDay},
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create scheduled habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/habits/%d/complete", habit.ID), map[string]any{
+		"date":   today.Format("2006-01-02"),
+		"amount": 3,
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("complete: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, "/v1/today", nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("today: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var resp struct {
+		Habits []struct {
+			ID        int64   `json:"id"`
+			Completed bool    `json:"completed"`
+			Progress  float64 `json:"progress"`
+			Streak    int     `json:"streak"`
+		} `json:"habits"`
+	}
+	decodeData(t, body, &resp)
+
+	if len(resp.Habits) != 1 || resp.Habits[0].ID != habit.ID {
+		t.Fatalf("today: want only habit %d got %+v", habit.ID, resp.Habits)
+	}
+	if !resp.Habits[0].Completed || resp.Habits[0].Progress != 1 || resp.Habits[0].Streak != 1 {
+		t.Fatalf("today: unexpected status %+v", resp.Habits[0])
+	}
+}
+
+func TestStats_SkippedDaysAreNotPossible(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Read",
+		"impact": "positive",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	today := time.Now().UTC().Format("2006-01-02")
+
+	getStats := func() (possible, completed int) {
+		t.Helper()
+
+		status, body := doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/habits/%d/stats?range=7d", habit.ID), nil, token)
+		if status != http.StatusOK {
+			t.Fatalf("stats: want %d got %d body=%s", http.StatusOK, status, string(body))
+		}
+
+		var stats struct {
+			PossibleDays  int `json:"possible_days"`
+			CompletedDays int `json:"completed_days"`
+		}
+		decodeData(t, body, &stats)
+		return stats.PossibleDays, stats.CompletedDays
+	}
+
+	status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/habits/%d/complete", habit.ID), map[string]any{
+		"date": today,
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("complete: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	if possible, completed := getStats(); possible != 1 || completed != 1 {
+		t.Fatalf("stats: want 1/1 got %d/%d", completed, possible)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/habits/%d/skip", habit.ID), map[string]any{
+		"date": today,
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("skip: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	if possible, _ := getStats(); possible != 0 {
+		t.Fatalf("stats: skipped day should not be possible, got %d", possible)
+	}
+
+	status, _ = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/habits/%d/stats?range=soon", habit.ID), nil, token)
+	if status != http.StatusBadRequest {
+		t.Fatalf("stats with bad range: want %d got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestHeatmap_CountsDueAndCompletedHabits(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	habitIDs := make([]int64, 2)
+	for i, name := range []string{"Walk", "Stretch"} {
+		status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+			"name":   name,
+			"impact": "positive",
+		}, token)
+		if status != http.StatusCreated {
+			t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+		}
+
+		var habit struct {
+			ID int64 `json:"id"`
+		}
+		decodeData(t, body, &habit)
+		habitIDs[i] = habit.ID
+	}
+
+	now := time.Now().UTC()
+	today := now.Format("2006-01-02")
+
+	status, body := doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/habits/%d/complete", habitIDs[0]), map[string]any{
+		"date": today,
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("complete: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	type heatmap struct {
+		Year int `json:"year"`
+		Days []struct {
+			Date      string `json:"date"`
+			Due       int    `json:"due"`
+			Completed int    `json:"completed"`
+			Level     int    `json:"level"`
+		} `json:"days"`
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/users/me/heatmap?year=%d", now.Year()), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("heatmap: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var all heatmap
+	decodeData(t, body, &all)
+
+	if len(all.Days) < 365 {
+		t.Fatalf("heatmap: want a cell per day, got %d", len(all.Days))
+	}
+
+	cell := all.Days[now.YearDay()-1]
+	if cell.Date != today || cell.Due != 2 || cell.Completed != 1 || cell.Level != 2 {
+		t.Fatalf("heatmap: unexpected cell for today %+v", cell)
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/habits/%d/heatmap", habitIDs[0]), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("habit heatmap: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var single heatmap
+	decodeData(t, body, &single)
+
+	cell = single.Days[now.YearDay()-1]
+	if cell.Due != 1 || cell.Completed != 1 || cell.Level != 4 {
+		t.Fatalf("habit heatmap: unexpected cell for today %+v", cell)
+	}
+}
+
+func TestCompletions_BatchMarkAndUnmark(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+	_, otherToken := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Stretch",
+		"impact": "positive",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Someone else's",
+		"impact": "positive",
+	}, otherToken)
+	if status != http.StatusCreated {
+		t.Fatalf("create other habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var other struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &other)
+
+	day1 := time.Now().AddDate(0, 0, -2).Format("2006-01-02")
+	day2 := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+
+	// One foreign habit rejects the whole batch
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/completions/batch", map[string]any{
+		"items": []map[string]any{
+			{"habit_id": habit.ID, "date": day1},
+			{"habit_id": other.ID, "date": day1},
+		},
+	}, token)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("batch with foreign habit: want %d got %d body=%s", http.StatusUnprocessableEntity, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/completions/batch", map[string]any{
+		"items": []map[string]any{
+			{"habit_id": habit.ID, "date": day1},
+			{"habit_id": habit.ID, "date": day2, "amount": 2},
+		},
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("batch mark: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var marked []struct {
+		Status string `json:"status"`
+	}
+	decodeData(t, body, &marked)
+	if len(marked) != 2 || marked[0].Status != "created" || marked[1].Status != "created" {
+		t.Fatalf("batch mark: unexpected results %+v", marked)
+	}
+
+	status, body = doJSON(t, handler, http.MethodDelete, "/v1/completions/batch", map[string]any{
+		"items": []map[string]any{
+			{"habit_id": habit.ID, "date": day1},
+			{"habit_id": habit.ID, "date": time.Now().Format("2006-01-02")},
+		},
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("batch unmark: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var unmarked []struct {
+		Status string `json:"status"`
+	}
+	decodeData(t, body, &unmarked)
+	if len(unmarked) != 2 || unmarked[0].Status != "deleted" || unmarked[1].Status != "not_found" {
+		t.Fatalf("batch unmark: unexpected results %+v", unmarked)
+	}
+}
+
+func TestCompletions_PolicyAndOwnership(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+	_, otherToken := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Meditate",
+		"impact": "positive",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	path := fmt.Sprintf("/v1/habits/%d/complete", habit.ID)
+
+	for name, date := range map[string]time.Time{
+		"future":          time.Now().AddDate(0, 0, 2),
+		"before backfill": time.Now().AddDate(0, 0, -30),
+	} {
+		status, body = doJSON(t, handler, http.MethodPost, path, map[string]any{
+			"date": date.Format("2006-01-02"),
+		}, token)
+		if status != http.StatusBadRequest {
+			t.Fatalf("%s: want %d got %d body=%s", name, http.StatusBadRequest, status, string(body))
+		}
+	}
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	status, body = doJSON(t, handler, http.MethodPost, path, map[string]any{"date": yesterday}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("mark yesterday: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	status, _ = doJSON(t, handler, http.MethodDelete, path+"/"+yesterday, nil, otherToken)
+	if status != http.StatusNotFound {
+		t.Fatalf("cross-user unmark: want %d got %d", http.StatusNotFound, status)
+	}
+
+	status, body = doJSON(t, handler, http.MethodDelete, path+"/"+yesterday, nil, token)
+	if status != http.StatusNoContent {
+		t.Fatalf("unmark: want %d got %d body=%s", http.StatusNoContent, status, string(body))
+	}
+}
+
+func TestReminders_CRUD(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Stretch",
+		"impact": "positive",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	path := fmt.Sprintf("/v1/habits/%d/reminders", habit.ID)
+
+	status, body = doJSON(t, handler, http.MethodPost, path, map[string]any{"time": "7:30"}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create reminder: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var reminder struct {
+		ID      int64  `json:"id"`
+		Time    string `json:"time"`
+		Enabled bool   `json:"enabled"`
+	}
+	decodeData(t, body, &reminder)
+
+	if reminder.Time != "07:30" || !reminder.Enabled {
+		t.Fatalf("create reminder: want enabled 07:30 got %+v", reminder)
+	}
+
+	status, _ = doJSON(t, handler, http.MethodPost, path, map[string]any{"time": "07:30"}, token)
+	if status != http.StatusConflict {
+		t.Fatalf("duplicate reminder: want %d got %d", http.StatusConflict, status)
+	}
+
+	status, _ = doJSON(t, handler, http.MethodPost, path, map[string]any{"time": "25:00"}, token)
+	if status != http.StatusBadRequest {
+		t.Fatalf("invalid time: want %d got %d", http.StatusBadRequest, status)
+	}
+
+	_, otherToken := createActivatedUserAndToken(t, handler)
+	status, _ = doJSON(t, handler, http.MethodGet, path, nil, otherToken)
+	if status == http.StatusOK {
+		t.Fatalf("cross-user list: want error got %d", status)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPatch, fmt.Sprintf("%s/%d", path, reminder.ID), map[string]any{
+		"time":    "21:00",
+		"enabled": false,
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("update reminder: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+	decodeData(t, body, &reminder)
+
+	if reminder.Time != "21:00" || reminder.Enabled {
+		t.Fatalf("update reminder: want disabled 21:00 got %+v", reminder)
+	}
+
+	status, body = doJSON(t, handler, http.MethodDelete, fmt.Sprintf("%s/%d", path, reminder.ID), nil, token)
+	if status != http.StatusNoContent {
+		t.Fatalf("delete reminder: want %d got %d body=%s", http.StatusNoContent, status, string(body))
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, path, nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("list reminders: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var reminders []struct{}
+	decodeData(t, body, &reminders)
+	if len(reminders) != 0 {
+		t.Fatalf("list reminders: want 0 got %d", len(reminders))
+	}
+}
+
+func TestEmailDeliveries_ExpiredClaimIsTakenOver(t *testing.T) {
+	api, cleanup := newTestAPI(t)
+	defer cleanup()
+
+	publicID, _ := createActivatedUserAndToken(t, api.mount())
+
+	var userID int64
+	if err := api.db.QueryRow(`SELECT id FROM users WHERE public_id = $1`, publicID).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	deliveries := api.store.EmailDeliveries
+
+	claim := func() bool {
+		t.Helper()
+		claimed, err := deliveries.Claim(ctx, userID, weeklyDigestEmailKind, "2026-W01", deliveryClaimLease)
+		if err != nil {
+			t.Fatalf("claim: %v", err)
+		}
+		return claimed
+	}
+
+	if !claim() {
+		t.Fatal("first claim: want claimed")
+	}
+	if claim() {
+		t.Fatal("claim held by another sender: want not claimed")
+	}
+
+	// the sender died before sending
+	if _, err := api.db.Exec(`UPDATE email_deliveries SET claimed_until = NOW() - interval '1 minute'`); err != nil {
+		t.Fatal(err)
+	}
+	if !claim() {
+		t.Fatal("expired claim: want taken over")
+	}
+
+	if err := deliveries.MarkSent(ctx, userID, weeklyDigestEmailKind, "2026-W01"); err != nil {
+		t.Fatalf("mark sent: %v", err)
+	}
+	if claim() {
+		t.Fatal("sent: want not claimed again")
+	}
+}
+
+func TestPreferences_WeeklyDigestAndUnsubscribe(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	publicID, token := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodPatch, "/v1/users/me/preferences", map[string]any{
+		"weekly_digest": true,
+		"digest_day":    5,
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("update preferences: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	status, _ = doJSON(t, handler, http.MethodPatch, "/v1/users/me/preferences", map[string]any{
+		"digest_day": 8,
+	}, token)
+	if status != http.StatusBadRequest {
+		t.Fatalf("invalid digest day: want %d got %d", http.StatusBadRequest, status)
+	}
+
+	getPrefs := func() store.Preferences {
+		t.Helper()
+
+		status, body := doJSON(t, handler, http.MethodGet, "/v1/users/me/preferences", nil, token)
+		if status != http.StatusOK {
+			t.Fatalf("get preferences: want %d got %d body=%s", http.StatusOK, status, string(body))
+		}
+
+		var prefs store.Preferences
+		decodeData(t, body, &prefs)
+		return prefs
+	}
+
+	if prefs := getPrefs(); !prefs.WeeklyDigest || prefs.DigestDay != 5 || prefs.Locale != "en" {
+		t.Fatalf("get preferences: want weekly digest on friday in english got %+v", prefs)
+	}
+
+	status, _ = doJSON(t, handler, http.MethodPatch, "/v1/users/me/preferences", map[string]any{
+		"locale": "sv",
+	}, token)
+	if status != http.StatusBadRequest {
+		t.Fatalf("unsupported locale: want %d got %d", http.StatusBadRequest, status)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPatch, "/v1/users/me/preferences", map[string]any{
+		"locale": "fi",
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("update locale: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	if prefs := getPrefs(); prefs.Locale != "fi" || !prefs.WeeklyDigest {
+		t.Fatalf("get preferences: want finnish got %+v", prefs)
+	}
+
+	forged := signUnsubscribeToken("wrong-secret", publicID, weeklyDigestEmailKind)
+	status, _ = doJSON(t, handler, http.MethodGet, "/v1/unsubscribe/"+forged, nil, "")
+	if status != http.StatusBadRequest {
+		t.Fatalf("forged unsubscribe: want %d got %d", http.StatusBadRequest, status)
+	}
+
+	// Opening the link only shows a confirmation page
+	unsubscribe := signUnsubscribeToken("test-secret", publicID, weeklyDigestEmailKind)
+	status, body = doJSON(t, handler, http.MethodGet, "/v1/unsubscribe/"+unsubscribe, nil, "")
+	if status != http.StatusOK || !strings.Contains(string(body), `<form method="post">`) {
+		t.Fatalf("unsubscribe page: want %d and a form got %d body=%s", http.StatusOK, status, string(body))
+	}
+	if prefs := getPrefs(); !prefs.WeeklyDigest {
+		t.Fatalf("after opening the link: want weekly digest still on got %+v", prefs)
+	}
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/unsubscribe/"+unsubscribe, nil, "")
+	if status != http.StatusNoContent {
+		t.Fatalf("unsubscribe: want %d got %d body=%s", http.StatusNoContent, status, string(body))
+	}
+
+	if prefs := getPrefs(); prefs.WeeklyDigest || prefs.DigestDay != 5 {
+		t.Fatalf("after unsubscribe: want weekly digest off got %+v", prefs)
+	}
+
+	// The confirmation form gets a page back
+	req := httptest.NewRequest(http.MethodPost, "/v1/unsubscribe/"+unsubscribe, strings.NewReader("List-Unsubscribe=One-Click&confirm=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Et saa enää viikkokoostetta") {
+		t.Fatalf("confirm form: want %d and the finnish page got %d body=%s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func TestPushSubscriptions_CRUD(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodGet, "/v1/push/vapid-public-key", nil, "")
+	if status != http.StatusOK {
+		t.Fatalf("vapid key: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	// A browser's subscription keys; any valid P-256 point will do
+	p256dh, _, err := notify.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription := map[string]any{
+		"endpoint":       "https://push.example.test/send/abc",
+		"expirationTime": nil,
+		"keys": map[string]any{
+			"p256dh": p256dh,
+			"auth":   "AAECAwQFBgcICQoLDA0ODw",
+		},
+	}
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/users/me/push-subscriptions", subscription, token)
+	if status != http.StatusCreated {
+		t.Fatalf("subscribe: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var sub struct {
+		ID       int64  `json:"id"`
+		Endpoint string `json:"endpoint"`
+	}
+	decodeData(t, body, &sub)
+
+	// Subscribing the same browser again updates the existing row
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/users/me/push-subscriptions", subscription, token)
+	if status != http.StatusCreated {
+		t.Fatalf("resubscribe: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	status, _ = doJSON(t, handler, http.MethodPost, "/v1/users/me/push-subscriptions", map[string]any{
+		"endpoint": "https://push.example.test/send/def",
+		"keys":     map[string]any{"p256dh": "bm90LWEta2V5", "auth": "AAECAwQFBgcICQoLDA0ODw"},
+	}, token)
+	if status != http.StatusBadRequest {
+		t.Fatalf("invalid keys: want %d got %d", http.StatusBadRequest, status)
+	}
+
+	for _, endpoint := range []string{"http://push.example.test/send/abc", "https://169.254.169.254/latest", "https://fly-api.internal/send"} {
+		status, _ = doJSON(t, handler, http.MethodPost, "/v1/users/me/push-subscriptions", map[string]any{
+			"endpoint": endpoint,
+			"keys":     subscription["keys"],
+		}, token)
+		if status != http.StatusBadRequest {
+			t.Fatalf("endpoint %s: want %d got %d", endpoint, http.StatusBadRequest, status)
+		}
+	}
+
+	// Another user can't take over the endpoint without the browser's auth secret
+	_, otherToken := createActivatedUserAndToken(t, handler)
+	status, _ = doJSON(t, handler, http.MethodPost, "/v1/users/me/push-subscriptions", map[string]any{
+		"endpoint": subscription["endpoint"],
+		"keys":     map[string]any{"p256dh": p256dh, "auth": "EBESExQVFhcYGRobHB0eHw"},
+	}, otherToken)
+	if status != http.StatusConflict {
+		t.Fatalf("takeover: want %d got %d", http.StatusConflict, status)
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, "/v1/users/me/push-subscriptions", nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("list: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var subs []struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &subs)
+	if len(subs) != 1 || subs[0].ID != sub.ID {
+		t.Fatalf("list: want the one subscription got %+v", subs)
+	}
+
+	status, _ = doJSON(t, handler, http.MethodDelete, fmt.Sprintf("/v1/users/me/push-subscriptions/%d", sub.ID), nil, otherToken)
+	if status != http.StatusNotFound {
+		t.Fatalf("cross-user delete: want %d got %d", http.StatusNotFound, status)
+	}
+
+	status, _ = doJSON(t, handler, http.MethodDelete, fmt.Sprintf("/v1/users/me/push-subscriptions/%d", sub.ID), nil, token)
+	if status != http.StatusNoContent {
+		t.Fatalf("delete: want %d got %d", http.StatusNoContent, status)
+	}
+}
+
+func TestWebhooks_EventsAreQueuedWithTheChange(t *testing.T) {
+	handler, cleanup := newTestHandler(t)
+	defer cleanup()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/webhooks", map[string]any{
+		"url":    "https://hooks.example.test/habits",
+		"events": []string{"habit.completed", "habit.uncompleted", "goal.deleted"},
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create webhook: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var hook struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &hook)
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Read",
+		"impact": "positive",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var habit struct {
+		ID int64 `json:"id"`
+	}
+	decodeData(t, body, &habit)
+
+	day1 := time.Now().AddDate(0, 0, -2).Format("2006-01-02")
+	day2 := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/completions/batch", map[string]any{
+		"items": []map[string]any{
+			{"habit_id": habit.ID, "date": day1},
+			{"habit_id": habit.ID, "date": day2},
+		},
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("batch mark: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	// only the completion that existed is reported
+	status, body = doJSON(t, handler, http.MethodDelete, "/v1/completions/batch", map[string]any{
+		"items": []map[string]any{
+			{"habit_id": habit.ID, "date": day1},
+			{"habit_id": habit.ID, "date": time.Now().Format("2006-01-02")},
+		},
+	}, token)
+	if status != http.StatusOK {
+		t.Fatalf("batch unmark: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	// a failed change queues nothing
+	status, _ = doJSON(t, handler, http.MethodDelete, "/v1/goals/999999999", nil, token)
+	if status != http.StatusNotFound {
+		t.Fatalf("delete missing goal: want %d got %d", http.StatusNotFound, status)
+	}
+
+	status, body = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/webhooks/%d/deliveries", hook.ID), nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("deliveries: want %d got %d body=%s", http.StatusOK, status, string(body))
+	}
+
+	var deliveries []store.WebhookDelivery
+	decodeData(t, body, &deliveries)
+
+	events := map[string]int{}
+	for _, d := range deliveries {
+		events[d.Event]++
+
+		var payload struct {
+			Data struct {
+				Completion *struct {
+					ID int64 `json:"id"`
+				} `json:"completion"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(d.Payload, &payload); err != nil {
+			t.Fatalf("payload: %v", err)
+		}
+		// the payload is written after the completion, so it carries the stored id
+		if d.Event == "habit.completed" && (payload.Data.Completion == nil || payload.Data.Completion.ID == 0) {
+			t.Fatalf("habit.completed payload: want the completion id got %s", d.Payload)
+		}
+	}
+
+	if len(deliveries) != 3 || events["habit.completed"] != 2 || events["habit.uncompleted"] != 1 {
+		t.Fatalf("deliveries: want 2 habit.completed and 1 habit.uncompleted got %v", events)
+	}
+}
+
+func TestWebhooks_DeliverSignedEventsAndRedeliver(t *testing.T) {
+	api, cleanup := newTestAPI(t)
+	defer cleanup()
+	handler := api.mount()
+
+	_, token := createActivatedUserAndToken(t, handler)
+
+	var secret string
+	var received []string
+	fail := true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		received = append(received, r.Header.Get(webhook.EventHeader))
+
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	status, _ := doJSON(t, handler, http.MethodPost, "/v1/webhooks", map[string]any{
+		"url":    receiver.URL,
+		"events": []string{"habit.nonsense"},
+	}, token)
+	if status != http.StatusBadRequest {
+		t.Fatalf("unknown event: want %d got %d", http.StatusBadRequest, status)
+	}
+
+	status, body := doJSON(t, handler, http.MethodPost, "/v1/webhooks", map[string]any{
+		"url":    receiver.URL,
+		"events": []string{"habit.created"},
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create webhook: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	var hook struct {
+		ID     int64  `json:"id"`
+		Secret string `json:"secret"`
+	}
+	decodeData(t, body, &hook)
+	secret = hook.Secret
+
+	status, body = doJSON(t, handler, http.MethodPost, "/v1/habits", map[string]any{
+		"name":   "Read",
+		"impact": "positive",
+	}, token)
+	if status != http.StatusCreated {
+		t.Fatalf("create habit: want %d got %d body=%s", http.StatusCreated, status, string(body))
+	}
+
+	deliveries := func() []store.WebhookDelivery {
+		t.Helper()
+
+		status, body := doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/webhooks/%d/deliveries", hook.ID), nil, token)
+		if status != http.StatusOK {
+			t.Fatalf("deliveries: want %d got %d body=%s", http.StatusOK, status, string(body))
+		}
+
+		var out []store.WebhookDelivery
+		decodeData(t, body, &out)
+		return out
+	}
+
+	// The receiver is down: the delivery is retried later
+	if err := api.deliverWebhooks(context.Background()); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	log := deliveries()
+	if len(log) != 1 || log[0].Event != "habit.created" || log[0].Status != "pending" || log[0].Attempts != 1 {
+		t.Fatalf("after failed attempt: got %+v", log)
+	}
+	if !log[0].NextAttemptAt.After(time.Now()) {
+		t.Fatalf("after failed attempt: want next attempt in the future got %s", log[0].NextAttemptAt)
+	}
+
+	fail = false
+
+	status, body = doJSON(t, handler, http.MethodPost, fmt.Sprintf("/v1/webhooks/%d/deliveries/%d/redeliver", hook.ID, log[0].ID), nil, token)
+	if status != http.StatusAccepted {
+		t.Fatalf("redeliver: want %d got %d body=%s", http.StatusAccepted, status, string(body))
+	}
+
+	if err := api.deliverWebhooks(context.Background()); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	log = deliveries()
+	if len(log) != 2 || log[0].Status != "succeeded" || log[0].RedeliveryOf == nil || *log[0].RedeliveryOf != log[1].ID {
+		t.Fatalf("after redelivery: got %+v", log)
+	}
+
+	if len(received) != 2 || received[1] != "habit.created" {
+		t.Fatalf("receiver: want two habit.created requests got %v", received)
+	}
+
+	_, otherToken := createActivatedUserAndToken(t, handler)
+	status, _ = doJSON(t, handler, http.MethodGet, fmt.Sprintf("/v1/webhooks/%d", hook.ID), nil, otherToken)
+	if status != http.StatusNotFound {
+		t.Fatalf("cross-user get: want %d got %d", http.StatusNotFound, status)
+	}
+}
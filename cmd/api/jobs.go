@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// deliveryClaimLease is how long a claimed year review, weekly digest or streak
+// warning stays reserved. A claim left by a crashed instance is taken over after it.
+const deliveryClaimLease = 10 * time.Minute
+
+// job is a background task the API process runs periodically.
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+func (api *api) jobs() []job {
+	return []job{
+		{name: "year_review_email", interval: time.Hour, run: api.sendYearReviews},
+		{name: "habit_reminders", interval: time.Minute, run: api.sendDueReminders},
+		{name: "weekly_digest_email", interval: time.Hour, run: api.sendWeeklyDigests},
+		{name: "streak_warnings", interval: 15 * time.Minute, run: api.sendStreakWarnings},
+		{name: "webhook_deliveries", interval: 10 * time.Second, run: api.deliverWebhooks},
+		{name: "outbox", interval: 10 * time.Second, run: api.dispatchOutbox},
+	}
+}
+
+// startJobs runs every job once right away and then on its interval until ctx
+// is cancelled. The returned WaitGroup is done when all jobs have stopped.
+func (api *api) startJobs(ctx context.Context, jobs []job) *sync.WaitGroup {
+	var wg sync.WaitGroup
+
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+
+			for {
+				if err := j.run(ctx); err != nil && ctx.Err() == nil {
+					api.logger.Errorw("background job failed", "job", j.name, "error", err)
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	return &wg
+}
+
+// waitJobs waits for the jobs to return after their context is cancelled, until
+// ctx is done. A job stuck in a call that ignores its context is abandoned.
+func (api *api) waitJobs(ctx context.Context, wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		api.logger.Info("background jobs stopped")
+	case <-ctx.Done():
+		api.logger.Warn("background jobs did not stop in time")
+	}
+}
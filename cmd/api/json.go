@@ -10,6 +10,7 @@ var Validate *validator.Validate
 
 func init() {
   Validate = validator.New(validator.WithRequiredStructEnabled())
+  Validate.RegisterCustomTypeFunc(nullableValue, nullable[int64]{}, nullable[float64]{}, nullable[string]{})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
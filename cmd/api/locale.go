@@ -0,0 +1,33 @@
+package main
+
+import "juhojarvi/habits/internal/mailer"
+
+// notificationTexts are the fmt formats of notifications per locale. Emails are
+// translated in the mailer templates; these texts are shared with Web Push.
+var notificationTexts = map[string]struct {
+	reminderTitle string // habit name
+	reminderBody  string // habit name
+	streakTitle   string // longest streak in days
+	streakBody    string // comma separated habit names
+}{
+	"en": {
+		reminderTitle: "Reminder: %s",
+		reminderBody:  "Don't forget to %s today.",
+		streakTitle:   "Keep your %d-day streak going",
+		streakBody:    "Still to do today: %s",
+	},
+	"fi": {
+		reminderTitle: "Muistutus: %s",
+		reminderBody:  "Muista tänään: %s.",
+		streakTitle:   "Älä katkaise %d päivän putkea",
+		streakBody:    "Tänään vielä tekemättä: %s",
+	},
+}
+
+// userLocale returns locale if it's supported, otherwise the default locale
+func userLocale(locale string) string {
+	if mailer.IsSupportedLocale(locale) {
+		return locale
+	}
+	return mailer.DefaultLocale
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"juhojarvi/habits/internal/mailer"
+)
+
+// newMailer builds the mail client selected with MAIL_PROVIDER.
+func newMailer(cfg mailConfig) (mailer.Client, error) {
+	switch cfg.provider {
+	case "mailtrap":
+		client, err := mailer.NewMailTrapClient(cfg.mailTrap.apiKey, cfg.fromEmail)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case "sendgrid":
+		return mailer.NewSendgrid(cfg.sendGrid.apiKey, cfg.fromEmail), nil
+	case "smtp":
+		client, err := mailer.NewSMTPClient(mailer.SMTPConfig{
+			Host:     cfg.smtp.host,
+			Port:     cfg.smtp.port,
+			Username: cfg.smtp.username,
+			Password: cfg.smtp.password,
+			TLS:      cfg.smtp.tls,
+		}, cfg.fromEmail)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case "file":
+		client, err := mailer.NewFileClient(cfg.file.dir, cfg.fromEmail)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown mail provider %q", cfg.provider)
+	}
+}
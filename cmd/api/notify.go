@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"juhojarvi/habits/internal/mailer"
+	"juhojarvi/habits/internal/notify"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// newNotifier builds the dispatcher for the configured notification channels.
+func newNotifier(channels string, mail mailer.Client, webPush *notify.WebPushChannel, logger *zap.SugaredLogger, isSandbox bool) (*notify.Dispatcher, error) {
+	var chs []notify.Channel
+
+	for _, name := range strings.Split(channels, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "email":
+			chs = append(chs, notify.NewEmailChannel(mail, isSandbox))
+		case "webpush":
+			if webPush == nil {
+				return nil, errors.New("webpush notification channel requires VAPID keys")
+			}
+			chs = append(chs, webPush)
+		case "log":
+			chs = append(chs, notify.NewLogChannel(logger))
+		default:
+			return nil, fmt.Errorf("unknown notification channel %q", name)
+		}
+	}
+
+	return notify.NewDispatcher(chs...), nil
+}
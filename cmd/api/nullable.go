@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// nullable is a PATCH field that tells a missing field (leave it as is) apart
+// from an explicit null (clear it). A plain pointer can't, as both decode to nil.
+type nullable[T any] struct {
+	set   bool
+	value *T
+}
+
+func (n *nullable[T]) UnmarshalJSON(b []byte) error {
+	n.set = true
+	if string(b) == "null" {
+		n.value = nil
+		return nil
+	}
+
+	var v T
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	n.value = &v
+	return nil
+}
+
+// validationValue is what the validator checks: the value, or nil when missing
+// or null so omitempty skips it
+func (n nullable[T]) validationValue() any {
+	if n.value == nil {
+		return nil
+	}
+	return *n.value
+}
+
+// elemType lets the OpenAPI generator document nullable[T] as a nullable T
+func (nullable[T]) elemType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+type nullableField interface {
+	validationValue() any
+	elemType() reflect.Type
+}
+
+var nullableFieldType = reflect.TypeFor[nullableField]()
+
+func nullableValue(field reflect.Value) any {
+	if n, ok := field.Interface().(nullableField); ok {
+		return n.validationValue()
+	}
+	return nil
+}
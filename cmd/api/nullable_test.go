@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNullable(t *testing.T) {
+	type payload struct {
+		Unit  nullable[string]  `json:"unit" validate:"omitempty,max=3"`
+		Value nullable[float64] `json:"value"`
+	}
+
+	tests := []struct {
+		body      string
+		wantSet   bool
+		wantValue *string
+	}{
+		{`{}`, false, nil},
+		{`{"unit": null}`, true, nil},
+		{`{"unit": "kg"}`, true, ptr("kg")},
+	}
+
+	for _, tt := range tests {
+		var p payload
+		if err := json.Unmarshal([]byte(tt.body), &p); err != nil {
+			t.Fatalf("%s: %v", tt.body, err)
+		}
+		if p.Unit.set != tt.wantSet || (p.Unit.value == nil) != (tt.wantValue == nil) ||
+			(p.Unit.value != nil && *p.Unit.value != *tt.wantValue) {
+			t.Errorf("%s: got set %v value %v", tt.body, p.Unit.set, p.Unit.value)
+		}
+		if err := Validate.Struct(p); err != nil {
+			t.Errorf("%s: validate: %v", tt.body, err)
+		}
+	}
+
+	var p payload
+	if err := json.Unmarshal([]byte(`{"unit": "kilograms"}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate.Struct(p); err == nil {
+		t.Error("validate: want the max tag applied to the value")
+	}
+
+	if err := json.Unmarshal([]byte(`{"value": "ten"}`), &p); err == nil {
+		t.Error("wrong type: want error")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
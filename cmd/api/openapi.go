@@ -0,0 +1,496 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// The OpenAPI document is generated from apiDocs: request and response schemas
+// are reflected from the payload and store types, constraints from their
+// validate tags. TestOpenAPICoversRoutes fails when a route in mount isn't
+// documented, or a documented route no longer exists.
+
+const openAPIVersion = "3.0.3"
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type openAPIOperation struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary"`
+	OperationID string                      `json:"operationId"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Enum                 []any                     `json:"enum,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+	UniqueItems          bool                      `json:"uniqueItems,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// apiDoc documents one route of mount
+type apiDoc struct {
+	method  string
+	path    string // chi pattern, e.g. /v1/habits/{habitID}
+	id      string // operationId
+	summary string
+	public  bool // no bearer token needed
+	query   []openAPIParameter
+	body    any // request payload, nil for none
+	status  int
+	// response is sent in the {"data": ...} envelope; nil means no body
+	response any
+	// contentType marks a response that isn't JSON, e.g. text/html
+	contentType string
+	// bare responses are sent as is, without the envelope
+	bare bool
+	dev  bool // only mounted outside production
+}
+
+func query(name, typ, description string) openAPIParameter {
+	return openAPIParameter{Name: name, In: "query", Description: description, Schema: &openAPISchema{Type: typ}}
+}
+
+var (
+	openAPIDev  = sync.OnceValues(func() ([]byte, error) { return json.Marshal(buildOpenAPI(apiDocs, true)) })
+	openAPIProd = sync.OnceValues(func() ([]byte, error) { return json.Marshal(buildOpenAPI(apiDocs, false)) })
+)
+
+// Get the OpenAPI document of this API
+func (api *api) getOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	spec := openAPIProd
+	if api.config.env != "production" {
+		spec = openAPIDev
+	}
+
+	body, err := spec()
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+const apiDocsPage = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Habits API</title>
+    <meta charset="utf-8"/>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+  </head>
+  <body>
+    <redoc spec-url="openapi.json"></redoc>
+    <script src="https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"></script>
+  </body>
+</html>
+`
+
+// Browsable API reference rendered by Redoc
+func (api *api) getAPIDocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(apiDocsPage))
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+func buildOpenAPI(docs []apiDoc, dev bool) *openAPIDocument {
+	g := &schemaGenerator{schemas: map[string]*openAPISchema{}, names: map[reflect.Type]string{}}
+
+	errorSchema := g.schemaOf(reflect.TypeOf(struct {
+		Error string `json:"error"`
+	}{}))
+
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:       "Habits API",
+			Version:     "1",
+			Description: "Successful JSON responses are wrapped in {\"data\": ...}, errors are {\"error\": \"message\"}.",
+		},
+		Paths: map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for _, d := range docs {
+		if d.dev && !dev {
+			continue
+		}
+
+		op := &openAPIOperation{
+			Tags:        []string{strings.Split(strings.TrimPrefix(d.path, "/v1/"), "/")[0]},
+			Summary:     d.summary,
+			OperationID: d.id,
+			Parameters:  append(pathParameters(d.path), d.query...),
+			Responses: map[string]*openAPIResponse{
+				"default": {
+					Description: "Error",
+					Content:     map[string]openAPIMediaType{"application/json": {Schema: errorSchema}},
+				},
+			},
+		}
+		if !d.public {
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+		}
+
+		if d.body != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  map[string]openAPIMediaType{"application/json": {Schema: g.schemaOf(reflect.TypeOf(d.body))}},
+			}
+		}
+
+		resp := &openAPIResponse{Description: http.StatusText(d.status)}
+		switch {
+		case d.contentType != "":
+			resp.Content = map[string]openAPIMediaType{d.contentType: {Schema: &openAPISchema{Type: "string"}}}
+		case d.response != nil:
+			schema := g.schemaOf(reflect.TypeOf(d.response))
+			if !d.bare {
+				schema = &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{"data": schema}}
+			}
+			resp.Content = map[string]openAPIMediaType{"application/json": {Schema: schema}}
+		}
+		op.Responses[strconv.Itoa(d.status)] = resp
+
+		if doc.Paths[d.path] == nil {
+			doc.Paths[d.path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[d.path][strings.ToLower(d.method)] = op
+	}
+
+	return doc
+}
+
+func pathParameters(pattern string) []openAPIParameter {
+	var params []openAPIParameter
+	for _, m := range pathParam.FindAllStringSubmatch(pattern, -1) {
+		schema := &openAPISchema{Type: "string"}
+		switch name := m[1]; {
+		case name == "year":
+			schema = &openAPISchema{Type: "integer"}
+		case name == "date":
+			schema.Format = "date"
+		case strings.HasSuffix(name, "ID"):
+			schema = &openAPISchema{Type: "integer", Format: "int64"}
+		}
+		params = append(params, openAPIParameter{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGenerator turns Go types into schemas the way encoding/json would
+// marshal them. Named structs become components referenced with $ref.
+type schemaGenerator struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) *openAPISchema {
+	if t.Implements(nullableFieldType) {
+		return g.schemaOf(reflect.PointerTo(reflect.Zero(t).Interface().(nullableField).elemType()))
+	}
+
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	s := g.schemaOfType(t)
+	if nullable {
+		if s.Ref != "" {
+			// siblings of $ref are ignored in 3.0, so wrap it
+			return &openAPISchema{AllOf: []*openAPISchema{s}, Nullable: true}
+		}
+		s.Nullable = true
+	}
+	return s
+}
+
+func (g *schemaGenerator) schemaOfType(t reflect.Type) *openAPISchema {
+	switch t {
+	case timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + g.component(t)}
+	default:
+		// interfaces and anything else can hold any value
+		return &openAPISchema{}
+	}
+}
+
+// component registers a named struct and returns its component name
+func (g *schemaGenerator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	if _, taken := g.schemas[string(name)]; taken {
+		pkg := []rune(path.Base(t.PkgPath()))
+		pkg[0] = unicode.ToUpper(pkg[0])
+		name = append(pkg, name...)
+	}
+
+	// register before descending so recursive types end in a $ref
+	s := &openAPISchema{}
+	g.names[t] = string(name)
+	g.schemas[string(name)] = s
+	*s = *g.structSchema(t)
+
+	return string(name)
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *schemaGenerator) addFields(s *openAPISchema, t reflect.Type) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// embedded structs are flattened like encoding/json does
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+
+		if !f.IsExported() || opaque(f.Type) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schemaOf(f.Type)
+		if applyValidation(fs, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// opaque reports structs without exported fields, like the password of
+// store.User; they always marshal to {} and carry no data
+func opaque(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || t.Implements(nullableFieldType) {
+		return false
+	}
+	for i := range t.NumField() {
+		if t.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
+
+// applyValidation copies the validator rules that have an OpenAPI counterpart
+// to s and reports whether the field is required. Rules after dive apply to
+// the items of a slice.
+func applyValidation(s *openAPISchema, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+
+	target := s
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		if key == "dive" {
+			if target.Items == nil {
+				return required
+			}
+			target = target.Items
+			continue
+		}
+
+		// constraints can't be added next to a $ref
+		if target.Ref != "" || target.AllOf != nil {
+			if key == "required" && target == s {
+				required = true
+			}
+			continue
+		}
+
+		switch key {
+		case "required":
+			required = required || target == s
+		case "min", "gte":
+			setBound(target, value, true)
+		case "max", "lte":
+			setBound(target, value, false)
+		case "oneof":
+			for _, v := range strings.Fields(value) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && target.Type != "string" {
+					target.Enum = append(target.Enum, n)
+				} else {
+					target.Enum = append(target.Enum, v)
+				}
+			}
+		case "unique":
+			target.UniqueItems = true
+		case "email":
+			target.Format = "email"
+		case "url":
+			target.Format = "uri"
+		case "hexcolor":
+			target.Pattern = "^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$"
+		}
+	}
+
+	return required
+}
+
+func setBound(s *openAPISchema, value string, lower bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+
+	switch s.Type {
+	case "string", "array":
+		i := int(n)
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = &i
+		case s.Type == "string":
+			s.MaxLength = &i
+		case lower:
+			s.MinItems = &i
+		default:
+			s.MaxItems = &i
+		}
+	case "integer", "number":
+		if lower {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"juhojarvi/habits/internal/mailer"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+)
+
+var (
+	dateRangeQuery = []openAPIParameter{
+		query("start", "string", "First day, YYYY-MM-DD"),
+		query("end", "string", "Last day, YYYY-MM-DD; defaults to today"),
+	}
+	tagsQuery = query("tags", "string", "Comma separated tag ids, e.g. 1,2")
+)
+
+// apiDocs documents every route of mount, in the same order
+var apiDocs = []apiDoc{
+	// Operations
+	{method: http.MethodGet, path: "/v1/health", id: "health", summary: "Liveness probe",
+		public: true, status: http.StatusOK, response: healthStatus{}},
+	{method: http.MethodGet, path: "/v1/ready", id: "ready", summary: "Readiness probe: database, migrations and mailer; 503 with the failed checks",
+		public: true, status: http.StatusOK, response: readiness{}},
+	{method: http.MethodGet, path: "/v1/version", id: "version", summary: "Build version and commit",
+		public: true, status: http.StatusOK, response: buildInfo{}},
+
+	// Habits
+	{method: http.MethodPost, path: "/v1/habits", id: "createHabit", summary: "Create a habit",
+		body: CreateHabitPayload{}, status: http.StatusCreated, response: store.Habit{}},
+	{method: http.MethodPut, path: "/v1/habits/order", id: "reorderHabits", summary: "Reorder habits and move them between groups",
+		body: ReorderHabitsPayload{}, status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/habits/{habitID}", id: "getHabit", summary: "Get a habit",
+		status: http.StatusOK, response: store.Habit{}},
+	{method: http.MethodDelete, path: "/v1/habits/{habitID}", id: "deleteHabit", summary: "Delete a habit",
+		status: http.StatusNoContent},
+	{method: http.MethodPatch, path: "/v1/habits/{habitID}", id: "updateHabit", summary: "Update a habit",
+		body: UpdateHabitPayload{}, status: http.StatusOK, response: store.Habit{}},
+	{method: http.MethodPut, path: "/v1/habits/{habitID}/tags", id: "setHabitTags", summary: "Replace the tags of a habit",
+		body: SetHabitTagsPayload{}, status: http.StatusOK, response: store.Habit{}},
+	{method: http.MethodPost, path: "/v1/habits/{habitID}/complete", id: "markHabitComplete", summary: "Mark a habit done for a day",
+		body: MarkCompletePayload{}, status: http.StatusCreated, response: store.HabitCompletion{}},
+	{method: http.MethodDelete, path: "/v1/habits/{habitID}/complete/{date}", id: "unmarkHabitComplete", summary: "Remove a completion",
+		status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/habits/{habitID}/completions", id: "getHabitCompletions", summary: "List completions of a habit",
+		query: dateRangeQuery, status: http.StatusOK, response: []store.HabitCompletion{}},
+	{method: http.MethodGet, path: "/v1/habits/{habitID}/stats", id: "getHabitStats", summary: "Completion statistics of a habit",
+		query:  []openAPIParameter{query("range", "string", "Window ending today, e.g. 30d, 12w, 6m or 1y")},
+		status: http.StatusOK, response: store.HabitStats{}},
+	{method: http.MethodGet, path: "/v1/habits/{habitID}/heatmap", id: "getHabitHeatmap", summary: "Yearly heatmap of a habit",
+		query:  []openAPIParameter{query("year", "integer", "Defaults to the current year")},
+		status: http.StatusOK, response: heatmapResponse{}},
+	{method: http.MethodPost, path: "/v1/habits/{habitID}/skip", id: "skipHabit", summary: "Skip a day without breaking the streak",
+		body: SkipHabitPayload{}, status: http.StatusCreated, response: store.HabitSkip{}},
+	{method: http.MethodDelete, path: "/v1/habits/{habitID}/skip/{date}", id: "unskipHabit", summary: "Remove a skip",
+		status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/habits/{habitID}/reminders", id: "getReminders", summary: "List the reminders of a habit",
+		status: http.StatusOK, response: []store.Reminder{}},
+	{method: http.MethodPost, path: "/v1/habits/{habitID}/reminders", id: "createReminder", summary: "Add a reminder",
+		body: CreateReminderPayload{}, status: http.StatusCreated, response: store.Reminder{}},
+	{method: http.MethodPatch, path: "/v1/habits/{habitID}/reminders/{reminderID}", id: "updateReminder", summary: "Update a reminder",
+		body: UpdateReminderPayload{}, status: http.StatusOK, response: store.Reminder{}},
+	{method: http.MethodDelete, path: "/v1/habits/{habitID}/reminders/{reminderID}", id: "deleteReminder", summary: "Delete a reminder",
+		status: http.StatusNoContent},
+
+	// Habit groups
+	{method: http.MethodGet, path: "/v1/habit-groups", id: "getHabitGroups", summary: "List habit groups",
+		status: http.StatusOK, response: []store.HabitGroup{}},
+	{method: http.MethodPost, path: "/v1/habit-groups", id: "createHabitGroup", summary: "Create a habit group",
+		body: CreateHabitGroupPayload{}, status: http.StatusCreated, response: store.HabitGroup{}},
+	{method: http.MethodPut, path: "/v1/habit-groups/order", id: "reorderHabitGroups", summary: "Reorder habit groups",
+		body: ReorderHabitGroupsPayload{}, status: http.StatusNoContent},
+	{method: http.MethodPatch, path: "/v1/habit-groups/{groupID}", id: "updateHabitGroup", summary: "Rename a habit group",
+		body: UpdateHabitGroupPayload{}, status: http.StatusOK, response: store.HabitGroup{}},
+	{method: http.MethodDelete, path: "/v1/habit-groups/{groupID}", id: "deleteHabitGroup", summary: "Delete a habit group; its habits become ungrouped",
+		status: http.StatusNoContent},
+
+	// Tags
+	{method: http.MethodGet, path: "/v1/tags", id: "getTags", summary: "List tags",
+		status: http.StatusOK, response: []store.Tag{}},
+	{method: http.MethodPost, path: "/v1/tags", id: "createTag", summary: "Create a tag",
+		body: CreateTagPayload{}, status: http.StatusCreated, response: store.Tag{}},
+	{method: http.MethodGet, path: "/v1/tags/stats", id: "getTagsStats", summary: "Completion rate per tag",
+		query: dateRangeQuery, status: http.StatusOK, response: []store.TagStats{}},
+	{method: http.MethodGet, path: "/v1/tags/{tagID}", id: "getTag", summary: "Get a tag",
+		status: http.StatusOK, response: store.Tag{}},
+	{method: http.MethodPatch, path: "/v1/tags/{tagID}", id: "updateTag", summary: "Update a tag",
+		body: UpdateTagPayload{}, status: http.StatusOK, response: store.Tag{}},
+	{method: http.MethodDelete, path: "/v1/tags/{tagID}", id: "deleteTag", summary: "Delete a tag",
+		status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/tags/{tagID}/stats", id: "getTagStats", summary: "Completion rate of a tag",
+		query: dateRangeQuery, status: http.StatusOK, response: store.TagStats{}},
+
+	// Today
+	{method: http.MethodGet, path: "/v1/today", id: "getToday", summary: "Habits due on a day with their status, streak and goal",
+		query:  []openAPIParameter{query("date", "string", "YYYY-MM-DD, defaults to today in the user's timezone")},
+		status: http.StatusOK, response: todayResponse{}},
+
+	// Completions
+	{method: http.MethodGet, path: "/v1/completions", id: "getUserCompletions", summary: "List completions of all habits",
+		query:  append(dateRangeQuery[:len(dateRangeQuery):len(dateRangeQuery)], tagsQuery),
+		status: http.StatusOK, response: []store.HabitCompletion{}},
+	{method: http.MethodPost, path: "/v1/completions/batch", id: "batchMarkComplete", summary: "Mark many completions at once; 422 with per item results if any item is rejected",
+		body: BatchCompletionPayload{}, status: http.StatusOK, response: []batchItemResult{}},
+	{method: http.MethodDelete, path: "/v1/completions/batch", id: "batchUnmarkComplete", summary: "Remove many completions at once; 422 with per item results if any item is rejected",
+		body: BatchUnmarkPayload{}, status: http.StatusOK, response: []batchItemResult{}},
+
+	// Goals
+	{method: http.MethodPost, path: "/v1/goals", id: "createGoal", summary: "Create a goal",
+		body: CreateGoalPayload{}, status: http.StatusCreated, response: store.Goal{}},
+	{method: http.MethodGet, path: "/v1/goals/year/{year}", id: "getGoalsByYear", summary: "List the goals of a year with progress",
+		status: http.StatusOK, response: []store.Goal{}},
+	{method: http.MethodPost, path: "/v1/goals/year/{year}/review", id: "reviewGoals", summary: "Record outcomes and reflections for a finished year",
+		body: GoalReviewPayload{}, status: http.StatusOK, response: []store.Goal{}},
+	{method: http.MethodPost, path: "/v1/goals/year/{year}/rollover", id: "rolloverGoals", summary: "Copy unfinished goals to the next year",
+		body: GoalRolloverPayload{}, status: http.StatusCreated, response: goalRolloverResponse{}},
+	{method: http.MethodGet, path: "/v1/goals/{goalID}", id: "getGoal", summary: "Get a goal",
+		status: http.StatusOK, response: store.Goal{}},
+	{method: http.MethodPatch, path: "/v1/goals/{goalID}", id: "updateGoal", summary: "Update a goal",
+		body: UpdateGoalPayload{}, status: http.StatusOK, response: store.Goal{}},
+	{method: http.MethodDelete, path: "/v1/goals/{goalID}", id: "deleteGoal", summary: "Delete a goal",
+		status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/goals/{goalID}/milestones", id: "getGoalMilestones", summary: "List the milestones of a goal",
+		status: http.StatusOK, response: []store.GoalMilestone{}},
+	{method: http.MethodPost, path: "/v1/goals/{goalID}/milestones", id: "createGoalMilestone", summary: "Add a milestone",
+		body: CreateMilestonePayload{}, status: http.StatusCreated, response: store.GoalMilestone{}},
+	{method: http.MethodPatch, path: "/v1/goals/{goalID}/milestones/{milestoneID}", id: "updateGoalMilestone", summary: "Update a milestone",
+		body: UpdateMilestonePayload{}, status: http.StatusOK, response: store.GoalMilestone{}},
+	{method: http.MethodDelete, path: "/v1/goals/{goalID}/milestones/{milestoneID}", id: "deleteGoalMilestone", summary: "Delete a milestone",
+		status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/goals/{goalID}/checkins", id: "getGoalCheckins", summary: "Check-in history of a goal, newest first",
+		status: http.StatusOK, response: []store.GoalCheckin{}},
+	{method: http.MethodPost, path: "/v1/goals/{goalID}/checkins", id: "createGoalCheckin", summary: "Record the current value of a goal",
+		body: CreateCheckinPayload{}, status: http.StatusCreated, response: store.GoalCheckin{}},
+
+	// Goal categories
+	{method: http.MethodGet, path: "/v1/goal-categories", id: "getGoalCategories", summary: "List goal categories",
+		status: http.StatusOK, response: []store.GoalCategory{}},
+	{method: http.MethodPost, path: "/v1/goal-categories", id: "createGoalCategory", summary: "Create a goal category",
+		body: CreateGoalCategoryPayload{}, status: http.StatusCreated, response: store.GoalCategory{}},
+	{method: http.MethodPatch, path: "/v1/goal-categories/{categoryID}", id: "updateGoalCategory", summary: "Update a goal category",
+		body: UpdateGoalCategoryPayload{}, status: http.StatusOK, response: store.GoalCategory{}},
+	{method: http.MethodDelete, path: "/v1/goal-categories/{categoryID}", id: "deleteGoalCategory", summary: "Delete an unused goal category",
+		status: http.StatusNoContent},
+
+	// Webhooks
+	{method: http.MethodGet, path: "/v1/webhooks", id: "getWebhooks", summary: "List webhooks",
+		status: http.StatusOK, response: []store.Webhook{}},
+	{method: http.MethodPost, path: "/v1/webhooks", id: "createWebhook", summary: "Create a webhook",
+		body: CreateWebhookPayload{}, status: http.StatusCreated, response: store.Webhook{}},
+	{method: http.MethodGet, path: "/v1/webhooks/{webhookID}", id: "getWebhook", summary: "Get a webhook",
+		status: http.StatusOK, response: store.Webhook{}},
+	{method: http.MethodPatch, path: "/v1/webhooks/{webhookID}", id: "updateWebhook", summary: "Update a webhook",
+		body: UpdateWebhookPayload{}, status: http.StatusOK, response: store.Webhook{}},
+	{method: http.MethodDelete, path: "/v1/webhooks/{webhookID}", id: "deleteWebhook", summary: "Delete a webhook",
+		status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/webhooks/{webhookID}/deliveries", id: "getWebhookDeliveries", summary: "Delivery log of a webhook, newest first",
+		query:  []openAPIParameter{query("limit", "integer", "1-200")},
+		status: http.StatusOK, response: []store.WebhookDelivery{}},
+	{method: http.MethodPost, path: "/v1/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", id: "redeliverWebhook", summary: "Queue a delivery again",
+		status: http.StatusAccepted, response: store.WebhookDelivery{}},
+
+	// Users
+	{method: http.MethodPut, path: "/v1/users/activate/{token}", id: "activateUser", summary: "Activate an account with the emailed token",
+		public: true, status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/v1/users/me", id: "getMe", summary: "Get the signed in user",
+		status: http.StatusOK, response: store.User{}},
+	{method: http.MethodPatch, path: "/v1/users/me/email", id: "updateMyEmail", summary: "Change email address",
+		body: UpdateEmailPayload{}, status: http.StatusOK, response: store.User{}},
+	{method: http.MethodPatch, path: "/v1/users/me/password", id: "updateMyPassword", summary: "Change password",
+		body: UpdatePasswordPayload{}, status: http.StatusNoContent},
+	{method: http.MethodPatch, path: "/v1/users/me/timezone", id: "updateMyTimezone", summary: "Change timezone",
+		body: UpdateTimezonePayload{}, status: http.StatusOK, response: store.User{}},
+	{method: http.MethodGet, path: "/v1/users/me/preferences", id: "getMyPreferences", summary: "Get email and language preferences",
+		status: http.StatusOK, response: store.Preferences{}},
+	{method: http.MethodGet, path: "/v1/users/me/push-subscriptions", id: "getPushSubscriptions", summary: "List Web Push subscriptions",
+		status: http.StatusOK, response: []store.PushSubscription{}},
+	{method: http.MethodPost, path: "/v1/users/me/push-subscriptions", id: "createPushSubscription", summary: "Save a Web Push subscription",
+		body: CreatePushSubscriptionPayload{}, status: http.StatusCreated, response: store.PushSubscription{}},
+	{method: http.MethodDelete, path: "/v1/users/me/push-subscriptions/{subscriptionID}", id: "deletePushSubscription", summary: "Delete a Web Push subscription",
+		status: http.StatusNoContent},
+	{method: http.MethodPatch, path: "/v1/users/me/preferences", id: "updateMyPreferences", summary: "Update email and language preferences",
+		body: UpdatePreferencesPayload{}, status: http.StatusOK, response: store.Preferences{}},
+	{method: http.MethodGet, path: "/v1/users/me/heatmap", id: "getMyHeatmap", summary: "Yearly heatmap of all habits",
+		query:  []openAPIParameter{query("year", "integer", "Defaults to the current year")},
+		status: http.StatusOK, response: heatmapResponse{}},
+	{method: http.MethodGet, path: "/v1/users/me/review/{year}", id: "getYearReview", summary: "Year in review",
+		status: http.StatusOK, response: store.YearReview{}},
+	{method: http.MethodGet, path: "/v1/users/feed", id: "getUserFeed", summary: "Page through the user's habits",
+		query: []openAPIParameter{
+			query("limit", "integer", "1-20, default 20"),
+			query("offset", "integer", "Default 0"),
+			query("sort", "string", "asc or desc, default desc"),
+			tagsQuery,
+		},
+		status: http.StatusOK, response: []store.Habit{}},
+
+	// Authentication
+	{method: http.MethodPost, path: "/v1/authentication/user", id: "registerUser", summary: "Register; the activation link is emailed",
+		public: true, body: RegisterUserPayload{}, status: http.StatusCreated, response: UserWithToken{}},
+	{method: http.MethodPost, path: "/v1/authentication/token", id: "createToken", summary: "Sign in and get a bearer token",
+		public: true, body: CreateUserTokenPayload{}, status: http.StatusCreated, response: UserWithToken{}},
+	{method: http.MethodPost, path: "/v1/authentication/forgot-password", id: "forgotPassword", summary: "Email a password reset link",
+		public: true, body: ForgotPasswordPayload{}, status: http.StatusAccepted},
+	{method: http.MethodPost, path: "/v1/authentication/reset-password", id: "resetPassword", summary: "Set a new password with a reset token",
+		public: true, body: ResetPasswordPayload{}, status: http.StatusNoContent},
+
+	// Email and push
+	{method: http.MethodGet, path: "/v1/unsubscribe/{token}", id: "unsubscribeLink", summary: "Confirmation page for an unsubscribe link",
+		public: true, status: http.StatusOK, contentType: "text/html"},
+	{method: http.MethodGet, path: "/v1/push/vapid-public-key", id: "getVAPIDPublicKey", summary: "Public key for Web Push subscriptions",
+		public: true, status: http.StatusOK, response: map[string]string{}},
+	{method: http.MethodPost, path: "/v1/unsubscribe/{token}", id: "unsubscribe", summary: "One-click unsubscribe (RFC 8058)",
+		public: true, status: http.StatusNoContent},
+
+	// API docs
+	{method: http.MethodGet, path: "/v1/openapi.json", id: "getOpenAPI", summary: "This document",
+		public: true, status: http.StatusOK, response: map[string]any{}, bare: true},
+	{method: http.MethodGet, path: "/v1/docs", id: "getAPIDocs", summary: "API reference page",
+		public: true, status: http.StatusOK, contentType: "text/html"},
+
+	// Development helpers
+	{method: http.MethodGet, path: "/v1/dev/mail", id: "getDevMailTemplates", summary: "List previewable mail templates",
+		public: true, dev: true, status: http.StatusOK, response: struct {
+			Templates []string `json:"templates"`
+			Locales   []string `json:"locales"`
+		}{}},
+	{method: http.MethodGet, path: "/v1/dev/mail/{template}", id: "previewMail", summary: "Render a mail template with sample data; html and text formats return the raw body",
+		public: true, dev: true,
+		query: []openAPIParameter{
+			query("locale", "string", "Defaults to en"),
+			query("format", "string", "html (default), text or json"),
+		},
+		status: http.StatusOK, response: mailer.Rendered{}},
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// TestOpenAPICoversRoutes fails when a route is added to mount without an
+// entry in apiDocs, or an entry outlives its route
+func TestOpenAPICoversRoutes(t *testing.T) {
+	for _, env := range []string{"development", "production"} {
+		t.Run(env, func(t *testing.T) {
+			api := &api{config: config{env: env}, logger: zap.NewNop().Sugar()}
+			mux, ok := api.mount().(chi.Routes)
+			if !ok {
+				t.Fatal("mount doesn't return a chi router")
+			}
+
+			spec := buildOpenAPI(apiDocs, env != "production")
+
+			routed := map[string]bool{}
+			err := chi.Walk(mux, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+				if route != "/" {
+					route = strings.TrimSuffix(route, "/")
+				}
+				key := method + " " + route
+				routed[key] = true
+
+				if spec.Paths[route][strings.ToLower(method)] == nil {
+					t.Errorf("%s is not documented in apiDocs", key)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for path, ops := range spec.Paths {
+				for method := range ops {
+					if key := strings.ToUpper(method) + " " + path; !routed[key] {
+						t.Errorf("%s is documented but not routed", key)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	ids := map[string]bool{}
+	for _, d := range apiDocs {
+		if ids[d.id] {
+			t.Errorf("duplicate operationId %q", d.id)
+		}
+		ids[d.id] = true
+	}
+
+	api := &api{config: config{env: "development"}, logger: zap.NewNop().Sugar()}
+	rr := httptest.NewRecorder()
+	api.mount().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want %d got %d", http.StatusOK, rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openAPIVersion {
+		t.Fatalf("openapi: want %q got %q", openAPIVersion, doc.OpenAPI)
+	}
+
+	// every $ref must point to a component
+	for _, ref := range strings.Split(rr.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("dangling $ref %q", name)
+		}
+	}
+
+	// spot check the reflected schemas
+	var habit struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(doc.Components.Schemas["Habit"], &habit); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := habit.Properties["schedule_days"]; !ok {
+		t.Errorf("Habit schema is missing schedule_days: %s", doc.Components.Schemas["Habit"])
+	}
+	if _, ok := habit.Properties["UserID"]; ok {
+		t.Error(`Habit schema includes the json:"-" UserID`)
+	}
+
+	var payload struct {
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			MaxLength int    `json:"maxLength"`
+			Format    string `json:"format"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(doc.Components.Schemas["RegisterUserPayload"], &payload); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(payload.Required, ",") != "username,email,password" {
+		t.Errorf("required: got %v", payload.Required)
+	}
+	if p := payload.Properties["email"]; p.Format != "email" || p.MaxLength != 255 {
+		t.Errorf("email constraints: got %+v", p)
+	}
+
+	// nullable PATCH fields are documented as their value type
+	var update struct {
+		Properties map[string]struct {
+			Type     string `json:"type"`
+			Nullable bool   `json:"nullable"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(doc.Components.Schemas["UpdateHabitPayload"], &update); err != nil {
+		t.Fatal(err)
+	}
+	if p := update.Properties["group_id"]; p.Type != "integer" || !p.Nullable {
+		t.Errorf("group_id: want a nullable integer got %+v", p)
+	}
+}
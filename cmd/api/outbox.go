@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"juhojarvi/habits/internal/mailer"
+	"juhojarvi/habits/internal/store"
+	"juhojarvi/habits/internal/webhook"
+	"time"
+)
+
+const (
+	outboxBatchSize   = 50
+	outboxLease       = 2 * time.Minute
+	outboxBackoffBase = 30 * time.Second
+	outboxBackoffMax  = time.Hour
+)
+
+// dispatchOutbox sends the messages written to the outbox alongside the changes
+// that caused them. A failed send is retried with backoff up to mailer.MaxRetries
+// times, so a mail provider outage only delays the mail. Template data (links
+// with tokens) is dropped once a message is sent or given up on.
+func (api *api) dispatchOutbox(ctx context.Context) error {
+	for {
+		due, err := api.store.Outbox.ClaimDue(ctx, outboxBatchSize, mailer.MaxRetries, outboxLease)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range due {
+			sendErr := api.sendOutboxMessage(m)
+			if sendErr == nil {
+				if err := api.store.Outbox.MarkSent(ctx, m.ID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var retryAt *time.Time
+			if m.Attempts < mailer.MaxRetries {
+				t := time.Now().Add(webhook.Backoff(m.Attempts, outboxBackoffBase, outboxBackoffMax))
+				retryAt = &t
+			} else {
+				api.logger.Errorw("giving up on outbox message", "id", m.ID, "topic", m.Topic, "error", sendErr)
+			}
+
+			if err := api.store.Outbox.MarkFailed(ctx, m.ID, sendErr.Error(), retryAt); err != nil {
+				return err
+			}
+		}
+
+		if len(due) < outboxBatchSize {
+			return nil
+		}
+	}
+}
+
+func (api *api) sendOutboxMessage(m store.OutboxMessage) error {
+	switch m.Topic {
+	case store.OutboxTopicEmail:
+		var email struct {
+			Template string         `json:"template"`
+			Locale   string         `json:"locale"`
+			Username string         `json:"username"`
+			Email    string         `json:"email"`
+			Data     map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(m.Payload, &email); err != nil {
+			return err
+		}
+
+		isProdEnv := api.config.env == "production"
+		_, err := api.mailer.Send(email.Template, email.Locale, email.Username, email.Email, email.Data, !isProdEnv)
+		return err
+	default:
+		return fmt.Errorf("unknown outbox topic %q", m.Topic)
+	}
+}
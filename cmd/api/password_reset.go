@@ -45,29 +45,25 @@ func (api *api) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	hash := sha256.Sum256([]byte(plainToken))
 	hashToken := hex.EncodeToString(hash[:])
 
-	expiry := time.Now().Add(1 * time.Hour)
-	if err := api.store.PasswordResetTokens.Create(ctx, user.ID, hashToken, expiry); err != nil {
-		api.internalServerError(w, r, err)
-		return
-	}
-
 	resetURL := fmt.Sprintf("%s/reset-password/%s", api.config.frontendURL, plainToken)
+	expiry := time.Now().Add(1 * time.Hour)
 
-	isProdEnv := api.config.env == "production"
-	vars := struct {
-		Username string
-		ResetURL string
-		Expiry   string
-	}{
+	// queued with the token and sent by the outbox dispatcher
+	mail := &store.OutboxEmail{
+		Template: mailer.PasswordResetTemplate,
+		Locale:   user.Locale,
 		Username: user.Username,
-		ResetURL: resetURL,
-		Expiry:   "1 hour",
+		Email:    user.Email,
+		Data: map[string]any{
+			"Username":  user.Username,
+			"ResetURL":  resetURL,
+			"ExpiresAt": expiry.In(user.Location()),
+		},
 	}
 
-	_, sendErr := api.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv)
-	if sendErr != nil {
-		api.logger.Errorw("error sending password reset email", "error", sendErr)
-		// Still return Accepted; no enumeration.
+	if err := api.store.PasswordResetTokens.Create(ctx, user.ID, hashToken, expiry, mail); err != nil {
+		api.internalServerError(w, r, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"juhojarvi/habits/internal/store"
 	"net/http"
+	"time"
 )
 
 var errUnauthorized = errors.New("unauthorized")
@@ -13,6 +14,16 @@ type UpdateEmailPayload struct {
 	CurrentPassword string `json:"currentPassword" validate:"required,min=3,max=72"`
 }
 
+type UpdateTimezonePayload struct {
+	Timezone string `json:"timezone" validate:"required,max=64"`
+}
+
+type UpdatePreferencesPayload struct {
+	WeeklyDigest *bool   `json:"weekly_digest"`
+	DigestDay    *int    `json:"digest_day" validate:"omitempty,min=1,max=7"` // ISO, 1 = Monday
+	Locale       *string `json:"locale" validate:"omitempty,oneof=en fi"`
+}
+
 type UpdatePasswordPayload struct {
 	CurrentPassword string `json:"currentPassword" validate:"required,min=3,max=72"`
 	NewPassword     string `json:"newPassword" validate:"required,min=3,max=72"`
@@ -105,3 +116,109 @@ func (api *api) updateMyPasswordHandler(w http.ResponseWriter, r *http.Request)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (api *api) updateMyTimezoneHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil {
+		api.unauthorizedErrorResponse(w, r, errUnauthorized)
+		return
+	}
+
+	var payload UpdateTimezonePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestResponse(w, r, err)
+		return
+	}
+
+	// IANA name, e.g. Europe/Helsinki. "Local" is the server's zone, not a name
+	// Postgres knows; the store checks the name against Postgres as well.
+	if _, err := time.LoadLocation(payload.Timezone); err != nil || payload.Timezone == "Local" {
+		api.badRequestResponse(w, r, errors.New("invalid timezone"))
+		return
+	}
+
+	if err := api.store.Users.UpdateTimezone(r.Context(), user.ID, payload.Timezone); err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidTimezone):
+			api.badRequestResponse(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user.Timezone = payload.Timezone
+
+	if err := api.jsonResponse(w, http.StatusOK, user); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+func (api *api) getMyPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil {
+		api.unauthorizedErrorResponse(w, r, errUnauthorized)
+		return
+	}
+
+	prefs, err := api.store.Users.GetPreferences(r.Context(), user.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+func (api *api) updateMyPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil {
+		api.unauthorizedErrorResponse(w, r, errUnauthorized)
+		return
+	}
+
+	var payload UpdatePreferencesPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	prefs, err := api.store.Users.GetPreferences(ctx, user.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if payload.WeeklyDigest != nil {
+		prefs.WeeklyDigest = *payload.WeeklyDigest
+	}
+	if payload.DigestDay != nil {
+		prefs.DigestDay = *payload.DigestDay
+	}
+	if payload.Locale != nil {
+		prefs.Locale = *payload.Locale
+	}
+
+	if err := api.store.Users.UpdatePreferences(ctx, user.ID, prefs); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
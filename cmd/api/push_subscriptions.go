@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"juhojarvi/habits/internal/notify"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errPushNotConfigured = errors.New("push notifications are not configured")
+
+// CreatePushSubscriptionPayload is the browser's PushSubscription.toJSON().
+type CreatePushSubscriptionPayload struct {
+	Endpoint       string `json:"endpoint" validate:"required,url,max=2048"`
+	ExpirationTime *int64 `json:"expirationTime"` // ignored, expired subscriptions are removed on 404/410
+	Keys           struct {
+		P256dh string `json:"p256dh" validate:"required,max=200"`
+		Auth   string `json:"auth" validate:"required,max=50"`
+	} `json:"keys"`
+}
+
+// Get the VAPID public key the browser needs to subscribe
+func (api *api) getVAPIDPublicKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if api.webPush == nil {
+		api.notFoundError(w, r, errPushNotConfigured)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, map[string]string{"public_key": api.webPush.PublicKey()}); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+func (api *api) getPushSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	subs, err := api.store.PushSubscriptions.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, subs); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+func (api *api) createPushSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if api.webPush == nil {
+		api.notFoundError(w, r, errPushNotConfigured)
+		return
+	}
+
+	var payload CreatePushSubscriptionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	// Push services are public https endpoints
+	endpoint, err := url.Parse(payload.Endpoint)
+	if err == nil {
+		err = api.outbound.CheckURL(endpoint)
+	}
+	if err != nil {
+		api.badRequestError(w, r, fmt.Errorf("invalid endpoint: %w", err))
+		return
+	}
+
+	p256dh, auth, err := notify.DecodeSubscriptionKeys(payload.Keys.P256dh, payload.Keys.Auth)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	sub := &store.PushSubscription{
+		UserID:    user.ID,
+		Endpoint:  payload.Endpoint,
+		P256dh:    p256dh,
+		Auth:      auth,
+		UserAgent: r.UserAgent(),
+	}
+
+	if err := api.store.PushSubscriptions.Save(r.Context(), sub); err != nil {
+		switch {
+		case errors.Is(err, store.ErrPushSubscriptionTaken):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, sub); err != nil {
+		api.internalServerError(w, r, err)
+	}
+}
+
+func (api *api) deletePushSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "subscriptionID"), 10, 64)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := api.store.PushSubscriptions.Delete(r.Context(), id, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"juhojarvi/habits/internal/netguard"
+	"juhojarvi/habits/internal/notify"
+)
+
+func TestVAPIDPublicKey_DerivedFromPrivateKey(t *testing.T) {
+	publicKey, privateKey, err := notify.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only VAPID_PRIVATE_KEY is set
+	vapid, err := notify.NewVAPID("", privateKey, "mailto:test@example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard, err := netguard.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := &api{
+		config:  config{env: "test", push: pushConfig{vapidPrivateKey: privateKey}},
+		logger:  zap.NewNop().Sugar(),
+		webPush: notify.NewWebPushChannel(nil, vapid, guard),
+	}
+
+	rr := httptest.NewRecorder()
+	api.getVAPIDPublicKeyHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/push/vapid-public-key", nil))
+
+	var body struct {
+		Data struct {
+			PublicKey string `json:"public_key"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || body.Data.PublicKey != publicKey {
+		t.Fatalf("vapid public key: got %d %q want %q", rr.Code, body.Data.PublicKey, publicKey)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"juhojarvi/habits/internal/notify"
+	"juhojarvi/habits/internal/store"
+	"juhojarvi/habits/internal/webhook"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// reminderWindow is how late a reminder may still be sent, e.g. after downtime
+	reminderWindow      = time.Hour
+	reminderLease       = 2 * time.Minute
+	reminderMaxAttempts = 5
+	// a reminder is late after a few retries, so the backoff stays short
+	reminderBackoffBase = time.Minute
+	reminderBackoffMax  = 15 * time.Minute
+	reminderBatchSize   = 50
+)
+
+type CreateReminderPayload struct {
+	Time    string `json:"time" validate:"required"` // Format: 15:04, in the user's timezone
+	Enabled *bool  `json:"enabled"`
+}
+
+type UpdateReminderPayload struct {
+	Time    *string `json:"time"`
+	Enabled *bool   `json:"enabled"`
+}
+
+// parseReminderTime accepts a wall-clock time as HH:MM.
+func parseReminderTime(s string) (string, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return "", errors.New("time must be in HH:MM format")
+	}
+	return t.Format("15:04"), nil
+}
+
+func (api *api) getRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+	ctx := r.Context()
+
+	reminders, err := api.store.Reminders.GetByHabit(ctx, habit.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, reminders); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) createReminderHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+
+	var payload CreateReminderPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	remindAt, err := parseReminderTime(payload.Time)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	reminder := &store.Reminder{
+		HabitID: habit.ID,
+		UserID:  habit.UserID,
+		Time:    remindAt,
+		Enabled: true,
+	}
+	if payload.Enabled != nil {
+		reminder.Enabled = *payload.Enabled
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.Reminders.Create(ctx, reminder); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateReminder):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, reminder); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) updateReminderHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "reminderID"), 10, 64)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	var payload UpdateReminderPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	reminder, err := api.store.Reminders.GetByID(ctx, id, habit.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if payload.Time != nil {
+		reminder.Time, err = parseReminderTime(*payload.Time)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+	}
+	if payload.Enabled != nil {
+		reminder.Enabled = *payload.Enabled
+	}
+
+	if err := api.store.Reminders.Update(ctx, reminder); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrDuplicateReminder):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, reminder); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) deleteReminderHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "reminderID"), 10, 64)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.Reminders.Delete(ctx, id, habit.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendDueReminders queues the reminders whose time has come and delivers them.
+// Rows are claimed with a lease, so a crash mid-send leads to a retry rather
+// than a lost reminder (at-least-once), and SKIP LOCKED keeps instances from
+// sending the same reminder. Failed sends are retried with backoff until
+// reminderMaxAttempts.
+func (api *api) sendDueReminders(ctx context.Context) error {
+	if _, err := api.store.Reminders.EnqueueDue(ctx, reminderWindow); err != nil {
+		return err
+	}
+
+	for {
+		due, err := api.store.Reminders.ClaimDue(ctx, reminderBatchSize, reminderMaxAttempts, reminderLease)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range due {
+			text := notificationTexts[userLocale(d.Locale)]
+			n := notify.Notification{
+				UserID:   d.UserID,
+				Username: d.Username,
+				Email:    d.Email,
+				Locale:   d.Locale,
+				Title:    fmt.Sprintf(text.reminderTitle, d.HabitName),
+				Body:     fmt.Sprintf(text.reminderBody, d.HabitName),
+				URL:      fmt.Sprintf("%s/today", api.config.frontendURL),
+			}
+
+			err := api.notifier.Send(ctx, n)
+			// Nowhere to deliver, e.g. webpush only and no subscriptions; retrying won't help
+			if errors.Is(err, notify.ErrNoSubscriptions) {
+				if err := api.store.Reminders.MarkSkipped(ctx, d.ReminderID, d.ScheduledFor, err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				api.logger.Errorw("error sending reminder", "reminder_id", d.ReminderID, "attempt", d.Attempts, "error", err)
+				var retryAt *time.Time
+				if d.Attempts < reminderMaxAttempts {
+					t := time.Now().Add(webhook.Backoff(d.Attempts, reminderBackoffBase, reminderBackoffMax))
+					retryAt = &t
+				}
+				if err := api.store.Reminders.MarkFailed(ctx, d.ReminderID, d.ScheduledFor, err.Error(), retryAt); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := api.store.Reminders.MarkSent(ctx, d.ReminderID, d.ScheduledFor); err != nil {
+				return err
+			}
+		}
+
+		if len(due) < reminderBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"juhojarvi/habits/internal/mailer"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	yearReviewEmailKind = "year_review"
+	// yearReviewSendDays is how many days into January the review email is sent
+	yearReviewSendDays  = 7
+	yearReviewBatchSize = 100
+)
+
+// Get the user's year in review
+func (api *api) getYearReviewHandler(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil || year < 2000 || year > 2100 {
+		api.badRequestError(w, r, errors.New("invalid year"))
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	review, err := api.store.YearReviews.Get(ctx, user.ID, year, localDate(time.Now(), user.Location()))
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, review); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// yearReviewPeriod returns the year whose review should be mailed at now, if any.
+func yearReviewPeriod(now time.Time) (int, bool) {
+	if now.Month() != time.January || now.Day() > yearReviewSendDays {
+		return 0, false
+	}
+	return now.Year() - 1, true
+}
+
+type yearReviewEmail struct {
+	Username          string
+	Year              int
+	TotalCompletions  int
+	ActiveDays        int
+	CompletionPercent int
+	BestStreaks       []struct {
+		Name string
+		Days int
+	}
+	MostConsistent []struct {
+		Name    string
+		Percent int
+	}
+	GoalsAchieved int
+	GoalsTotal    int
+	ReviewURL     string
+}
+
+func newYearReviewEmail(user *store.User, review *store.YearReview, frontendURL string) yearReviewEmail {
+	vars := yearReviewEmail{
+		Username:          user.Username,
+		Year:              review.Year,
+		TotalCompletions:  review.TotalCompletions,
+		ActiveDays:        review.ActiveDays,
+		CompletionPercent: int(review.CompletionRate*100 + 0.5),
+		ReviewURL:         fmt.Sprintf("%s/review/%d", frontendURL, review.Year),
+	}
+
+	for _, h := range review.BestStreaks {
+		vars.BestStreaks = append(vars.BestStreaks, struct {
+			Name string
+			Days int
+		}{h.Name, h.LongestStreak})
+	}
+
+	for _, h := range review.MostConsistent {
+		vars.MostConsistent = append(vars.MostConsistent, struct {
+			Name    string
+			Percent int
+		}{h.Name, int(h.CompletionRate*100 + 0.5)})
+	}
+
+	for _, c := range review.GoalsByCategory {
+		vars.GoalsAchieved += c.Achieved
+		vars.GoalsTotal += c.Total
+	}
+
+	return vars
+}
+
+// sendYearReviews mails last year's review to every active user during the
+// first days of January. Each send is claimed first so several API instances
+// never mail the same user twice; a failed send releases the claim for a retry,
+// and a claim whose sender died is taken over once its lease expires.
+func (api *api) sendYearReviews(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	year, ok := yearReviewPeriod(now)
+	if !ok {
+		return nil
+	}
+	period := strconv.Itoa(year)
+	isProdEnv := api.config.env == "production"
+
+	for {
+		users, err := api.store.EmailDeliveries.PendingUsers(ctx, yearReviewEmailKind, period, yearReviewBatchSize)
+		if err != nil {
+			return err
+		}
+
+		sent := 0
+		for i := range users {
+			user := &users[i]
+
+			claimed, err := api.store.EmailDeliveries.Claim(ctx, user.ID, yearReviewEmailKind, period, deliveryClaimLease)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+
+			review, err := api.store.YearReviews.Get(ctx, user.ID, year, now)
+			if err == nil {
+				vars := newYearReviewEmail(user, review, api.config.frontendURL)
+				_, err = api.mailer.Send(mailer.YearReviewTemplate, user.Locale, user.Username, user.Email, vars, !isProdEnv)
+			}
+			if err != nil {
+				api.logger.Errorw("error sending year review email", "user_id", user.ID, "error", err)
+				if err := api.store.EmailDeliveries.Release(ctx, user.ID, yearReviewEmailKind, period); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := api.store.EmailDeliveries.MarkSent(ctx, user.ID, yearReviewEmailKind, period); err != nil {
+				return err
+			}
+
+			sent++
+		}
+
+		// Stop on the last page, or when nothing could be sent so failures aren't retried in a loop
+		if len(users) < yearReviewBatchSize || sent == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestYearReviewPeriod(t *testing.T) {
+	tests := []struct {
+		now      time.Time
+		wantYear int
+		wantOK   bool
+	}{
+		{time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), 2024, true},
+		{time.Date(2025, time.January, 7, 23, 0, 0, 0, time.UTC), 2024, true},
+		{time.Date(2025, time.January, 8, 0, 0, 0, 0, time.UTC), 0, false},
+		{time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC), 0, false},
+	}
+
+	for _, tt := range tests {
+		year, ok := yearReviewPeriod(tt.now)
+		if year != tt.wantYear || ok != tt.wantOK {
+			t.Errorf("yearReviewPeriod(%s) = %d, %v; want %d, %v", tt.now.Format(time.DateOnly), year, ok, tt.wantYear, tt.wantOK)
+		}
+	}
+}
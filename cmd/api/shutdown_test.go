@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestServeDrainsOnShutdown(t *testing.T) {
+	api := &api{
+		config: config{
+			env:      "test",
+			shutdown: shutdownConfig{drainDelay: 100 * time.Millisecond, timeout: 2 * time.Second},
+		},
+		logger: zap.NewNop().Sugar(),
+	}
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", api.mount())
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	// the shutdown hook shares the deadline and is waited for
+	var hookDeadline time.Time
+	hookDone := false
+	onShutdown := func(ctx context.Context) {
+		hookDeadline, _ = ctx.Deadline()
+		time.Sleep(50 * time.Millisecond)
+		hookDone = true
+	}
+
+	go func() { served <- api.serve(ctx, ln, mux, onShutdown) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		slow <- result{body: string(b), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	// readiness fails while the server drains
+	time.Sleep(20 * time.Millisecond)
+	rr := httptest.NewRecorder()
+	api.readyHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/ready", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ready while draining: want %d got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	// the in-flight request still completes
+	if res := <-slow; res.err != nil || res.body != "done" {
+		t.Fatalf("in-flight request: got %q, %v", res.body, res.err)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("serve did not return after shutdown")
+	}
+
+	if !hookDone || time.Until(hookDeadline) > 2*time.Second {
+		t.Fatalf("shutdown hook: done %v, deadline in %s", hookDone, time.Until(hookDeadline))
+	}
+
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), 100*time.Millisecond); err == nil {
+		t.Fatal("listener still accepts connections after shutdown")
+	}
+}
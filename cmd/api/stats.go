@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errInvalidRange = errors.New("range must look like 30d, 12w, 6m or 1y")
+
+// maxStatsRangeDays caps the stats window so a single request can't scan years of data
+const maxStatsRangeDays = 3 * 366
+
+// Get completion statistics for a habit. The window is given with ?range= and
+// ends today in the user's timezone.
+func (api *api) getHabitStatsHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+	user := getUserFromContext(r)
+
+	endDate := localDate(time.Now(), user.Location())
+
+	startDate, err := parseStatsRange(r.URL.Query().Get("range"), endDate)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	stats, err := api.store.HabitCompletions.GetHabitStats(ctx, habit.ID, user.ID, startDate, endDate)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, stats); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// parseStatsRange turns a range such as 30d, 12w, 6m or 1y into the first day
+// of a window that ends on (and includes) end. An empty range means 30d.
+func parseStatsRange(s string, end time.Time) (time.Time, error) {
+	if s == "" {
+		s = "30d"
+	}
+
+	if len(s) < 2 {
+		return time.Time{}, errInvalidRange
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 1 {
+		return time.Time{}, errInvalidRange
+	}
+
+	var start time.Time
+	switch s[len(s)-1] {
+	case 'd':
+		start = end.AddDate(0, 0, -n)
+	case 'w':
+		start = end.AddDate(0, 0, -7*n)
+	case 'm':
+		start = subMonths(end, n)
+	case 'y':
+		start = subMonths(end, 12*n)
+	default:
+		return time.Time{}, errInvalidRange
+	}
+	start = start.AddDate(0, 0, 1)
+
+	if end.Sub(start).Hours()/24 >= maxStatsRangeDays {
+		return time.Time{}, fmt.Errorf("range can be at most %d days", maxStatsRangeDays)
+	}
+
+	return start, nil
+}
+
+// subMonths goes n months back from t, clamping to the last day of a shorter
+// month instead of overflowing like time.AddDate does (Mar 31 - 1m = Feb 29).
+func subMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m-time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+
+	return first.AddDate(0, 0, min(d, last)-1)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStatsRange(t *testing.T) {
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "2024-03-02", false},
+		{"7d", "2024-03-25", false},
+		{"2w", "2024-03-18", false},
+		{"1m", "2024-03-01", false},
+		{"1y", "2023-04-01", false},
+		{"0d", "", true},
+		{"d", "", true},
+		{"5x", "", true},
+		{"-3d", "", true},
+		{"10y", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStatsRange(tt.in, end)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseStatsRange(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseStatsRange(%q) unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got.Format("2006-01-02") != tt.want {
+			t.Errorf("parseStatsRange(%q) = %s, want %s", tt.in, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"juhojarvi/habits/internal/notify"
+	"strings"
+	"time"
+)
+
+const (
+	streakWarningKind      = "streak_warning"
+	streakWarningBatchSize = 100
+	// streakWarningMinStreak is the shortest streak worth a warning
+	streakWarningMinStreak = 3
+)
+
+// sendStreakWarnings pushes an evening warning to users whose running streaks
+// would break today. Each user is handled at most once per local day; users
+// with nothing at risk are claimed too so they aren't checked again.
+func (api *api) sendStreakWarnings(ctx context.Context) error {
+	if api.webPush == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	for {
+		users, err := api.store.PushSubscriptions.PendingStreakWarnings(ctx, streakWarningKind, api.config.push.streakWarningHour, streakWarningBatchSize)
+		if err != nil {
+			return err
+		}
+
+		handled := 0
+		for i := range users {
+			user := &users[i]
+			today := localDate(now, user.Location())
+			period := today.Format("2006-01-02")
+
+			claimed, err := api.store.EmailDeliveries.Claim(ctx, user.ID, streakWarningKind, period, deliveryClaimLease)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+
+			habits, err := api.store.Habits.GetDueOn(ctx, user.ID, today)
+			if err != nil {
+				return err
+			}
+
+			var atRisk []string
+			longest := 0
+			for _, h := range habits {
+				if h.Completed || h.Skipped || h.Streak < streakWarningMinStreak {
+					continue
+				}
+				atRisk = append(atRisk, h.Name)
+				longest = max(longest, h.Streak)
+			}
+
+			if len(atRisk) == 0 {
+				if err := api.store.EmailDeliveries.MarkSent(ctx, user.ID, streakWarningKind, period); err != nil {
+					return err
+				}
+				handled++
+				continue
+			}
+
+			text := notificationTexts[userLocale(user.Locale)]
+			n := notify.Notification{
+				UserID:   user.ID,
+				Username: user.Username,
+				Email:    user.Email,
+				Locale:   user.Locale,
+				Title:    fmt.Sprintf(text.streakTitle, longest),
+				Body:     fmt.Sprintf(text.streakBody, strings.Join(atRisk, ", ")),
+				URL:      fmt.Sprintf("%s/today", api.config.frontendURL),
+			}
+
+			if err := api.webPush.Send(ctx, n); err != nil {
+				// Subscriptions may all have expired in the meantime; nothing to retry then
+				if !errors.Is(err, notify.ErrNoSubscriptions) {
+					api.logger.Errorw("error sending streak warning", "user_id", user.ID, "error", err)
+					if err := api.store.EmailDeliveries.Release(ctx, user.ID, streakWarningKind, period); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+
+			if err := api.store.EmailDeliveries.MarkSent(ctx, user.ID, streakWarningKind, period); err != nil {
+				return err
+			}
+
+			handled++
+		}
+
+		if len(users) < streakWarningBatchSize || handled == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type tagKey string
+
+const tagCtxKey tagKey = "tag"
+
+type CreateTagPayload struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Color string `json:"color" validate:"omitempty,hexcolor"`
+	Icon  string `json:"icon" validate:"omitempty,max=50"`
+}
+
+type UpdateTagPayload struct {
+	Name  *string `json:"name" validate:"omitnil,min=1,max=50"`
+	Color *string `json:"color" validate:"omitempty,hexcolor"`
+	Icon  *string `json:"icon" validate:"omitempty,max=50"`
+}
+
+type SetHabitTagsPayload struct {
+	TagIDs []int64 `json:"tag_ids" validate:"unique"`
+}
+
+func (api *api) createTagHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateTagPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	tag := &store.Tag{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Color:  payload.Color,
+		Icon:   payload.Icon,
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.Tags.Create(ctx, tag); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateTagName):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusCreated, tag); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) getTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	tags, err := api.store.Tags.GetByUser(ctx, user.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, tags); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) getTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag := getTagFromCtx(r)
+
+	if err := api.jsonResponse(w, http.StatusOK, tag); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) updateTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag := getTagFromCtx(r)
+
+	var payload UpdateTagPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.Name != nil {
+		tag.Name = *payload.Name
+	}
+
+	if payload.Color != nil {
+		tag.Color = *payload.Color
+	}
+
+	if payload.Icon != nil {
+		tag.Icon = *payload.Icon
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.Tags.Update(ctx, tag); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrDuplicateTagName):
+			api.conflictError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, tag); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) deleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag := getTagFromCtx(r)
+	ctx := r.Context()
+
+	if err := api.store.Tags.Delete(ctx, tag.ID, tag.UserID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Korvaa tavan tagit annetulla listalla
+func (api *api) setHabitTagsHandler(w http.ResponseWriter, r *http.Request) {
+	habit := getHabitFromCtx(r)
+	user := getUserFromContext(r)
+
+	var payload SetHabitTagsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.TagIDs == nil {
+		payload.TagIDs = []int64{}
+	}
+
+	ctx := r.Context()
+
+	if err := api.store.Tags.SetHabitTags(ctx, habit.ID, user.ID, payload.TagIDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.badRequestError(w, r, errors.New("invalid tag"))
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	habit.TagIDs = payload.TagIDs
+
+	if err := api.jsonResponse(w, http.StatusOK, habit); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Tagikohtainen toteutumisaste aikaväliltä (oletuksena viimeiset 30 päivää).
+// Kun tagi on polussa, palautetaan vain sen tilastot.
+func (api *api) getTagStatsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	startDate, endDate, err := parseDateRange(r, 30)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	stats, err := api.store.Tags.GetStats(ctx, user.ID, startDate, endDate)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if tag := getTagFromCtx(r); tag != nil {
+		for _, st := range stats {
+			if st.TagID == tag.ID {
+				if err := api.jsonResponse(w, http.StatusOK, st); err != nil {
+					api.internalServerError(w, r, err)
+				}
+				return
+			}
+		}
+		api.notFoundError(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, stats); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) tagContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idParam := chi.URLParam(r, "tagID")
+		id, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+
+		user := getUserFromContext(r)
+		ctx := r.Context()
+
+		tag, err := api.store.Tags.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				api.notFoundError(w, r, err)
+			default:
+				api.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, tagCtxKey, tag)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getTagFromCtx(r *http.Request) *store.Tag {
+	tag, _ := r.Context().Value(tagCtxKey).(*store.Tag)
+	return tag
+}
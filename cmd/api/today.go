@@ -0,0 +1,46 @@
+package main
+
+import (
+	"juhojarvi/habits/internal/store"
+	"net/http"
+	"time"
+)
+
+type todayResponse struct {
+	Date   string             `json:"date"`
+	Habits []store.TodayHabit `json:"habits"`
+}
+
+// Home screen: every habit due on the given date (default today) with its
+// completion status, progress, current streak and linked goal
+func (api *api) getTodayHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	date := localDate(time.Now(), user.Location())
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		var err error
+		date, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	habits, err := api.store.Habits.GetDueOn(ctx, user.ID, date)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	resp := todayResponse{
+		Date:   date.Format("2006-01-02"),
+		Habits: habits,
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, resp); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"juhojarvi/habits/internal/store"
+	"juhojarvi/habits/internal/webhook"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type webhookKey string
+
+const webhookCtxKey webhookKey = "webhook"
+
+const (
+	webhookBatchSize   = 50
+	webhookLease       = time.Minute
+	webhookTimeout     = 10 * time.Second
+	webhookMaxAttempts = 8
+	// webhookBackoffBase doubles per attempt: 30s, 1m, 2m, ... up to webhookBackoffMax
+	webhookBackoffBase = 30 * time.Second
+	webhookBackoffMax  = 6 * time.Hour
+	// webhookLogLimit is how many deliveries the log endpoint returns by default
+	webhookLogLimit = 50
+)
+
+type CreateWebhookPayload struct {
+	URL         string   `json:"url" validate:"required,url,max=2048"`
+	Events      []string `json:"events" validate:"omitempty,unique"` // empty = every event
+	Description string   `json:"description" validate:"max=200"`
+}
+
+type UpdateWebhookPayload struct {
+	URL         *string  `json:"url" validate:"omitempty,url,max=2048"`
+	Events      []string `json:"events" validate:"omitempty,unique"`
+	Description *string  `json:"description" validate:"omitempty,max=200"`
+	Active      *bool    `json:"active"`
+}
+
+// validateWebhook checks the target URL and the subscribed event names.
+func (api *api) validateWebhook(rawURL string, events []string) error {
+	// The server POSTs to this URL; the sender's dialer checks the resolved
+	// address again on every delivery
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.New("invalid url")
+	}
+	if err := api.outbound.CheckURL(u); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		if !slices.Contains(store.WebhookEvents, e) {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+
+	return nil
+}
+
+func (api *api) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := api.validateWebhook(payload.URL, payload.Events); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	hook := &store.Webhook{
+		UserID:      user.ID,
+		URL:         payload.URL,
+		Secret:      secret,
+		Events:      []string{},
+		Description: payload.Description,
+		Active:      true,
+	}
+	if payload.Events != nil {
+		hook.Events = payload.Events
+	}
+
+	if err := api.store.Webhooks.Create(r.Context(), hook); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	// The secret is only ever returned here
+	if err := api.jsonResponse(w, http.StatusCreated, hook); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	hooks, err := api.store.Webhooks.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, hooks); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook := getWebhookFromCtx(r)
+
+	if err := api.jsonResponse(w, http.StatusOK, hook); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook := getWebhookFromCtx(r)
+
+	var payload UpdateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.URL != nil {
+		hook.URL = *payload.URL
+	}
+	if payload.Events != nil {
+		hook.Events = payload.Events
+	}
+	if payload.Description != nil {
+		hook.Description = *payload.Description
+	}
+	if payload.Active != nil {
+		hook.Active = *payload.Active
+	}
+
+	if err := api.validateWebhook(hook.URL, hook.Events); err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	if err := api.store.Webhooks.Update(r.Context(), hook); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, hook); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook := getWebhookFromCtx(r)
+
+	if err := api.store.Webhooks.Delete(r.Context(), hook.ID, hook.UserID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Get the webhook's delivery log, newest first (?limit=, max 200)
+func (api *api) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	hook := getWebhookFromCtx(r)
+
+	limit := webhookLogLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 200 {
+			api.badRequestError(w, r, errors.New("limit must be between 1 and 200"))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := api.store.WebhookDeliveries.GetByWebhook(r.Context(), hook.ID, limit)
+	if err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusOK, deliveries); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Queue a new delivery of a logged event; the worker sends it on its next run
+func (api *api) redeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook := getWebhookFromCtx(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		api.badRequestError(w, r, err)
+		return
+	}
+
+	delivery, err := api.store.WebhookDeliveries.Redeliver(r.Context(), id, hook.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			api.notFoundError(w, r, err)
+		default:
+			api.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := api.jsonResponse(w, http.StatusAccepted, delivery); err != nil {
+		api.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (api *api) webhookContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+		if err != nil {
+			api.badRequestError(w, r, err)
+			return
+		}
+
+		user := getUserFromContext(r)
+		ctx := r.Context()
+
+		hook, err := api.store.Webhooks.GetByID(ctx, id, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				api.notFoundError(w, r, err)
+			default:
+				api.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, webhookCtxKey, hook)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getWebhookFromCtx(r *http.Request) *store.Webhook {
+	hook, _ := r.Context().Value(webhookCtxKey).(*store.Webhook)
+	return hook
+}
+
+// habitCompletionEvent is the data of habit.completed and habit.uncompleted.
+type habitCompletionEvent struct {
+	Habit      *store.Habit           `json:"habit"`
+	Date       string                 `json:"date"`
+	Completion *store.HabitCompletion `json:"completion,omitempty"`
+}
+
+// deliverWebhooks sends queued webhook deliveries. Failed deliveries are retried
+// with exponential backoff until webhookMaxAttempts; a claimed delivery whose
+// worker died is picked up again once its lease expires. A failed status update
+// is logged and the rest of the batch still goes out; the row is retried when
+// its lease expires.
+func (api *api) deliverWebhooks(ctx context.Context) error {
+	sender := webhook.NewSender(webhookTimeout, api.outbound)
+
+	for {
+		due, err := api.store.WebhookDeliveries.ClaimDue(ctx, webhookBatchSize, webhookMaxAttempts, webhookLease)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range due {
+			result, err := sender.Send(ctx, webhook.Request{
+				URL:        d.URL,
+				Secret:     d.Secret,
+				Event:      d.Event,
+				DeliveryID: d.ID,
+				Body:       d.Payload,
+			})
+			if err == nil {
+				if err := api.store.WebhookDeliveries.MarkSucceeded(ctx, d.ID, result.Status, result.Body); err != nil {
+					api.logger.Errorw("error marking webhook delivery succeeded", "delivery_id", d.ID, "error", err)
+				}
+				continue
+			}
+
+			var status *int
+			if result.Status != 0 {
+				status = &result.Status
+			}
+
+			var retryAt *time.Time
+			if d.Attempts < webhookMaxAttempts {
+				t := time.Now().Add(webhook.Backoff(d.Attempts, webhookBackoffBase, webhookBackoffMax))
+				retryAt = &t
+			}
+
+			if err := api.store.WebhookDeliveries.MarkFailed(ctx, d.ID, status, result.Body, err.Error(), retryAt); err != nil {
+				api.logger.Errorw("error marking webhook delivery failed", "delivery_id", d.ID, "error", err)
+			}
+		}
+
+		if len(due) < webhookBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_habits_user_group_position;
+ALTER TABLE habits DROP COLUMN IF EXISTS position;
+ALTER TABLE habits DROP COLUMN IF EXISTS group_id;
+DROP TABLE IF EXISTS habit_groups;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS habit_groups (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name varchar(50) NOT NULL,
+    position int NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_habit_groups_user_id ON habit_groups(user_id);
+
+ALTER TABLE habits ADD COLUMN IF NOT EXISTS group_id bigint REFERENCES habit_groups(id) ON DELETE SET NULL;
+ALTER TABLE habits ADD COLUMN IF NOT EXISTS position int NOT NULL DEFAULT 0;
+
+-- Existing habits keep their creation order.
+UPDATE habits h
+SET position = o.rn
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY id) - 1 AS rn
+    FROM habits
+) o
+WHERE h.id = o.id;
+
+CREATE INDEX IF NOT EXISTS idx_habits_user_group_position ON habits(user_id, group_id, position);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name varchar(50) NOT NULL,
+    color varchar(9) NOT NULL DEFAULT '',
+    icon varchar(50) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS habit_tags (
+    habit_id bigint NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    tag_id bigint NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (habit_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_habit_tags_tag_id ON habit_tags(tag_id);
@@ -0,0 +1,4 @@
+ALTER TABLE habit_completions DROP COLUMN IF EXISTS amount;
+
+ALTER TABLE habits DROP COLUMN IF EXISTS target;
+ALTER TABLE habits DROP COLUMN IF EXISTS schedule_days;
@@ -0,0 +1,5 @@
+-- schedule_days: ISO weekdays (1 = Monday ... 7 = Sunday). Empty means every day.
+ALTER TABLE habits ADD COLUMN IF NOT EXISTS schedule_days smallint[] NOT NULL DEFAULT '{}';
+ALTER TABLE habits ADD COLUMN IF NOT EXISTS target int NOT NULL DEFAULT 1;
+
+ALTER TABLE habit_completions ADD COLUMN IF NOT EXISTS amount int NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS habit_skips (
+    habit_id bigint NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    skip_date date NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (habit_id, skip_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_habit_skips_user_id ON habit_skips(user_id);
@@ -0,0 +1 @@
+ALTER TABLE goals DROP COLUMN IF EXISTS target_quantity;
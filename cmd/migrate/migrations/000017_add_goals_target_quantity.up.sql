@@ -0,0 +1,2 @@
+-- target_quantity: optional numeric target reached by summing linked habits' completion amounts.
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS target_quantity int;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS goal_checkins;
+DROP TABLE IF EXISTS goal_milestones;
+
+ALTER TABLE goals DROP COLUMN IF EXISTS deadline;
+ALTER TABLE goals DROP COLUMN IF EXISTS unit;
+ALTER TABLE goals DROP COLUMN IF EXISTS target_value;
+ALTER TABLE goals DROP COLUMN IF EXISTS start_value;
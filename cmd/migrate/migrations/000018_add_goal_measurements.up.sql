@@ -0,0 +1,27 @@
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS start_value double precision;
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS target_value double precision;
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS unit varchar(20) NOT NULL DEFAULT '';
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS deadline date;
+
+CREATE TABLE IF NOT EXISTS goal_milestones (
+    id bigserial PRIMARY KEY,
+    goal_id bigint NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    title varchar(200) NOT NULL,
+    due_date date,
+    completed_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_goal_milestones_goal_id ON goal_milestones(goal_id);
+
+CREATE TABLE IF NOT EXISTS goal_checkins (
+    id bigserial PRIMARY KEY,
+    goal_id bigint NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    value double precision NOT NULL,
+    note text NOT NULL DEFAULT '',
+    checked_on date NOT NULL DEFAULT CURRENT_DATE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_goal_checkins_goal_checked_on ON goal_checkins(goal_id, checked_on);
@@ -0,0 +1,14 @@
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS category varchar(50);
+
+UPDATE goals g
+SET category = c.name
+FROM goal_categories c
+WHERE c.id = g.category_id;
+
+ALTER TABLE goals ALTER COLUMN category SET NOT NULL;
+ALTER TABLE goals DROP COLUMN IF EXISTS category_id;
+
+-- Fails if a user has several goals in the same category and year
+ALTER TABLE goals ADD CONSTRAINT goals_user_id_year_category_key UNIQUE (user_id, year, category);
+
+DROP TABLE IF EXISTS goal_categories;
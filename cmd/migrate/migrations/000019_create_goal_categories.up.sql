@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS goal_categories (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name varchar(50) NOT NULL,
+    color varchar(9) NOT NULL DEFAULT '',
+    position int NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, name)
+);
+
+-- Seed the former built-in categories for every existing user
+INSERT INTO goal_categories (user_id, name, color, position)
+SELECT u.id, d.name, d.color, d.position
+FROM users u
+CROSS JOIN (VALUES
+    ('career', '#6366f1', 0),
+    ('financial', '#16a34a', 1),
+    ('health', '#ef4444', 2),
+    ('learning', '#f59e0b', 3)
+) AS d(name, color, position)
+ON CONFLICT (user_id, name) DO NOTHING;
+
+INSERT INTO goal_categories (user_id, name, position)
+SELECT DISTINCT user_id, category, 100
+FROM goals
+ON CONFLICT (user_id, name) DO NOTHING;
+
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS category_id bigint REFERENCES goal_categories(id) ON DELETE RESTRICT;
+
+UPDATE goals g
+SET category_id = c.id
+FROM goal_categories c
+WHERE c.user_id = g.user_id AND c.name = g.category;
+
+ALTER TABLE goals ALTER COLUMN category_id SET NOT NULL;
+ALTER TABLE goals DROP CONSTRAINT IF EXISTS goals_user_id_year_category_key;
+ALTER TABLE goals DROP COLUMN IF EXISTS category;
+
+CREATE INDEX IF NOT EXISTS idx_goals_category_id ON goals(category_id);
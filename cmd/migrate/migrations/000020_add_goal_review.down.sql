@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS goals_rolled_over_from_key;
+
+ALTER TABLE goals DROP COLUMN IF EXISTS rolled_over_from;
+ALTER TABLE goals DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE goals DROP COLUMN IF EXISTS reflection;
+ALTER TABLE goals DROP COLUMN IF EXISTS outcome;
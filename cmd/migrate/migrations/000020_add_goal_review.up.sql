@@ -0,0 +1,8 @@
+-- outcome: achieved | partial | missed | dropped, set in the yearly review
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS outcome varchar(20);
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS reflection text NOT NULL DEFAULT '';
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS reviewed_at timestamp(0) with time zone;
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS rolled_over_from bigint REFERENCES goals(id) ON DELETE SET NULL;
+
+-- A goal can be carried over to the next year only once
+CREATE UNIQUE INDEX IF NOT EXISTS goals_rolled_over_from_key ON goals(rolled_over_from);
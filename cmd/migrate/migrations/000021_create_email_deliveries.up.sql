@@ -0,0 +1,9 @@
+-- Records which scheduled emails have been sent so every instance sends each one only once.
+-- period identifies the occurrence, e.g. '2024' for a yearly review.
+CREATE TABLE IF NOT EXISTS email_deliveries (
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind varchar(50) NOT NULL,
+    period varchar(20) NOT NULL,
+    sent_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, kind, period)
+);
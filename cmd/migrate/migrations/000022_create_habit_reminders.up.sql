@@ -0,0 +1,29 @@
+-- remind_at is a wall-clock time in the user's timezone
+CREATE TABLE IF NOT EXISTS habit_reminders (
+    id bigserial PRIMARY KEY,
+    habit_id bigint NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    remind_at time NOT NULL,
+    enabled boolean NOT NULL DEFAULT true,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE(habit_id, remind_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_habit_reminders_user_id ON habit_reminders(user_id);
+
+-- One row per reminder occurrence. The primary key makes enqueueing idempotent across
+-- instances and the status/lease columns let workers claim rows with SKIP LOCKED.
+CREATE TABLE IF NOT EXISTS reminder_deliveries (
+    reminder_id bigint NOT NULL REFERENCES habit_reminders(id) ON DELETE CASCADE,
+    scheduled_for timestamp(0) with time zone NOT NULL,
+    status varchar(10) NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 0,
+    claimed_until timestamp(0) with time zone,
+    last_error text NOT NULL DEFAULT '',
+    sent_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (reminder_id, scheduled_for)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminder_deliveries_status ON reminder_deliveries(status, scheduled_for);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS digest_day,
+    DROP COLUMN IF EXISTS weekly_digest;
@@ -0,0 +1,4 @@
+-- digest_day is an ISO weekday (1 = Monday) in the user's timezone
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS weekly_digest boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS digest_day smallint NOT NULL DEFAULT 1 CHECK (digest_day BETWEEN 1 AND 7);
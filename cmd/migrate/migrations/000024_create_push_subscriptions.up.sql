@@ -0,0 +1,13 @@
+-- Browser push subscriptions (Web Push). p256dh and auth are base64url encoded
+-- as the browser's PushSubscription.toJSON() returns them.
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    endpoint text NOT NULL UNIQUE,
+    p256dh text NOT NULL,
+    auth text NOT NULL,
+    user_agent text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user_id ON push_subscriptions(user_id);
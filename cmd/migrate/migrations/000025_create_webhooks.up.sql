@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url text NOT NULL,
+    secret text NOT NULL,
+    -- empty = every event
+    events text[] NOT NULL DEFAULT '{}',
+    description varchar(200) NOT NULL DEFAULT '',
+    active boolean NOT NULL DEFAULT true,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
+
+-- Delivery log and queue. Rows are claimed by workers with a lease
+-- (FOR UPDATE SKIP LOCKED) and retried at next_attempt_at with backoff.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id bigserial PRIMARY KEY,
+    webhook_id bigint NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id uuid NOT NULL,
+    event varchar(50) NOT NULL,
+    payload jsonb NOT NULL,
+    status varchar(10) NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 0,
+    next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    claimed_until timestamp(0) with time zone,
+    response_status int,
+    response_body text NOT NULL DEFAULT '',
+    last_error text NOT NULL DEFAULT '',
+    redelivery_of bigint REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    delivered_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_queue ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, id DESC);
@@ -0,0 +1,16 @@
+-- Transactional outbox: rows are written in the same transaction as the change
+-- that causes them and dispatched by a background worker with retries.
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    topic varchar(50) NOT NULL,
+    payload jsonb NOT NULL,
+    status varchar(10) NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 0,
+    next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    claimed_until timestamp(0) with time zone,
+    last_error text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    sent_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_queue ON outbox(status, next_attempt_at);
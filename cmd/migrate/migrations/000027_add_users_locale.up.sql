@@ -0,0 +1,2 @@
+-- locale selects the language of emails and notifications (en, fi)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale varchar(5) NOT NULL DEFAULT 'en';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_reminder_deliveries_queue;
+CREATE INDEX IF NOT EXISTS idx_reminder_deliveries_status ON reminder_deliveries(status, scheduled_for);
+
+ALTER TABLE reminder_deliveries DROP COLUMN IF EXISTS next_attempt_at;
@@ -0,0 +1,5 @@
+-- Failed sends are retried at next_attempt_at with backoff, like webhook deliveries
+ALTER TABLE reminder_deliveries ADD COLUMN IF NOT EXISTS next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+DROP INDEX IF EXISTS idx_reminder_deliveries_status;
+CREATE INDEX IF NOT EXISTS idx_reminder_deliveries_queue ON reminder_deliveries(status, next_attempt_at);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS users_check_timezone ON users;
+DROP FUNCTION IF EXISTS users_check_timezone();
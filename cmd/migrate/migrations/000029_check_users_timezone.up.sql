@@ -0,0 +1,20 @@
+-- Jobs convert times for all users in one statement, so a single timezone
+-- Postgres doesn't know would stop them for everyone.
+-- A CHECK constraint can't query pg_timezone_names, hence the trigger.
+UPDATE users SET timezone = 'UTC'
+WHERE timezone NOT IN (SELECT name FROM pg_timezone_names);
+
+CREATE OR REPLACE FUNCTION users_check_timezone() RETURNS trigger AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = NEW.timezone) THEN
+        RAISE EXCEPTION 'invalid timezone: %', NEW.timezone
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_check_timezone ON users;
+CREATE TRIGGER users_check_timezone
+    BEFORE INSERT OR UPDATE OF timezone ON users
+    FOR EACH ROW EXECUTE FUNCTION users_check_timezone();
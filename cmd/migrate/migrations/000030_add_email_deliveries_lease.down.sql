@@ -0,0 +1,4 @@
+DELETE FROM email_deliveries WHERE sent_at IS NULL;
+ALTER TABLE email_deliveries ALTER COLUMN sent_at SET DEFAULT NOW();
+ALTER TABLE email_deliveries ALTER COLUMN sent_at SET NOT NULL;
+ALTER TABLE email_deliveries DROP COLUMN IF EXISTS claimed_until;
@@ -0,0 +1,6 @@
+-- A claim is leased until claimed_until and sent_at is set only after the send,
+-- so a claim left by a crashed instance is taken over once the lease expires.
+-- Existing rows were written after sending and keep their sent_at.
+ALTER TABLE email_deliveries ADD COLUMN IF NOT EXISTS claimed_until timestamp(0) with time zone;
+ALTER TABLE email_deliveries ALTER COLUMN sent_at DROP NOT NULL;
+ALTER TABLE email_deliveries ALTER COLUMN sent_at DROP DEFAULT;
@@ -0,0 +1,82 @@
+package main
+
+// habitTemplate is one habit a seeded user may pick. rate is the base chance
+// of completing it on a due day before the user's own consistency is applied.
+type habitTemplate struct {
+	name     string
+	impact   string
+	group    string
+	tags     []string
+	schedule []int64
+	target   int
+	rate     float64
+	goal     string // key in goalTemplates, empty for none
+}
+
+var habitTemplates = []habitTemplate{
+	{name: "Morning run", impact: "positive", group: "Morning", tags: []string{"health", "outdoors"}, schedule: []int64{1, 3, 5}, target: 1, rate: 0.75, goal: "run"},
+	{name: "Gym", impact: "positive", group: "Evening", tags: []string{"health"}, schedule: []int64{2, 4, 6}, target: 1, rate: 0.7, goal: "run"},
+	{name: "Drink water (glasses)", impact: "positive", tags: []string{"health"}, target: 8, rate: 0.85},
+	{name: "Meditate", impact: "positive", group: "Morning", tags: []string{"mind"}, target: 1, rate: 0.6},
+	{name: "Read 20 pages", impact: "positive", group: "Evening", tags: []string{"learning"}, target: 1, rate: 0.65, goal: "books"},
+	{name: "Practice Finnish", impact: "positive", tags: []string{"learning"}, schedule: []int64{1, 2, 3, 4, 5}, target: 1, rate: 0.55, goal: "language"},
+	{name: "Write in journal", impact: "positive", group: "Evening", tags: []string{"mind"}, target: 1, rate: 0.5},
+	{name: "Walk 10k steps", impact: "positive", tags: []string{"health", "outdoors"}, target: 1, rate: 0.6},
+	{name: "Deep work block", impact: "positive", tags: []string{"work"}, schedule: []int64{1, 2, 3, 4, 5}, target: 2, rate: 0.7, goal: "project"},
+	{name: "Review budget", impact: "positive", tags: []string{"money"}, schedule: []int64{7}, target: 1, rate: 0.8, goal: "savings"},
+	{name: "Cook at home", impact: "positive", tags: []string{"money", "health"}, target: 1, rate: 0.65, goal: "savings"},
+	{name: "Stretch", impact: "positive", group: "Morning", tags: []string{"health"}, target: 1, rate: 0.55},
+	{name: "No social media", impact: "negative", tags: []string{"mind"}, target: 1, rate: 0.45},
+	{name: "No sugar", impact: "negative", tags: []string{"health"}, target: 1, rate: 0.5},
+	{name: "No snoozing", impact: "negative", group: "Morning", tags: []string{"mind"}, schedule: []int64{1, 2, 3, 4, 5}, target: 1, rate: 0.6},
+}
+
+var tagColors = map[string]string{
+	"health":   "#ef4444",
+	"outdoors": "#22c55e",
+	"mind":     "#8b5cf6",
+	"learning": "#f59e0b",
+	"work":     "#6366f1",
+	"money":    "#16a34a",
+}
+
+// goalTemplate describes a yearly goal. Goals with a quantity are tracked by
+// the completions of their habits, goals with a start and target value by
+// monthly check-ins.
+type goalTemplate struct {
+	category    string // one of store.DefaultGoalCategories
+	description string
+	quantity    int
+	start       float64
+	target      float64
+	unit        string
+}
+
+var goalTemplates = map[string]goalTemplate{
+	"run":      {category: "health", description: "Work out 150 times", quantity: 150},
+	"books":    {category: "learning", description: "Read 15 books", quantity: 240},
+	"language": {category: "learning", description: "Reach B1 in Finnish", quantity: 200},
+	"project":  {category: "career", description: "Ship the side project", quantity: 300},
+	"savings":  {category: "financial", description: "Build an emergency fund", start: 0, target: 5000, unit: "€"},
+}
+
+// goalOrder keeps goal creation deterministic, map iteration isn't
+var goalOrder = []string{"run", "books", "language", "project", "savings"}
+
+var weightGoal = goalTemplate{category: "health", description: "Get down to 75 kg", start: 84, target: 75, unit: "kg"}
+
+var timezones = []string{
+	"Europe/Helsinki",
+	"Europe/Stockholm",
+	"Europe/Berlin",
+	"Europe/London",
+	"America/New_York",
+	"America/Los_Angeles",
+	"Asia/Tokyo",
+	"UTC",
+}
+
+var firstNames = []string{
+	"aino", "eero", "helmi", "juho", "kaisa", "leo", "mikko", "noora", "oona", "pekka",
+	"sanna", "tuomas", "venla", "alex", "emma", "liam", "maria", "noah", "olivia", "sam",
+}
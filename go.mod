@@ -13,6 +13,7 @@ require (
 	github.com/sendgrid/sendgrid-go v3.16.1+incompatible
 	go.uber.org/zap v1.27.0
 	golang.org/x/crypto v0.33.0
+	golang.org/x/net v0.34.0
 	gopkg.in/mail.v2 v2.3.1
 )
 
@@ -23,7 +24,6 @@ require (
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/sendgrid/rest v2.6.9+incompatible // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 func GetString(key, fallback string) string {
@@ -26,3 +27,32 @@ func GetInt(key string, fallback int) int {
 
   return valAsInt
 }
+
+func GetBool(key string, fallback bool) bool {
+  val, ok := os.LookupEnv(key)
+  if !ok {
+    return fallback
+  }
+
+  valAsBool, err := strconv.ParseBool(val)
+  if err != nil {
+    return fallback
+  }
+
+  return valAsBool
+}
+
+// GetDuration parses values like "30s" or "1m30s"
+func GetDuration(key string, fallback time.Duration) time.Duration {
+  val, ok := os.LookupEnv(key)
+  if !ok {
+    return fallback
+  }
+
+  valAsDuration, err := time.ParseDuration(val)
+  if err != nil {
+    return fallback
+  }
+
+  return valAsDuration
+}
@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FileClient writes every message as an .eml file into a directory instead of
+// sending it. The files open in any mail client; meant for local development and tests.
+type FileClient struct {
+	fromEmail string
+	dir       string
+	seq       atomic.Int64
+}
+
+func NewFileClient(dir, fromEmail string) (*FileClient, error) {
+	if dir == "" {
+		return nil, errors.New("no mail directory provided")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileClient{
+		fromEmail: fromEmail,
+		dir:       dir,
+	}, nil
+}
+
+func (m *FileClient) Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error) {
+	r, err := Render(templateFile, locale, data)
+	if err != nil {
+		return -1, err
+	}
+
+	message := newMessage(m.fromEmail, username, email, r)
+
+	// timestamp first so the files sort in sending order
+	name := fmt.Sprintf("%s-%04d-%s-%s.eml",
+		time.Now().UTC().Format("20060102T150405.000"),
+		m.seq.Add(1)%10000,
+		strings.TrimSuffix(templateFile, filepath.Ext(templateFile)),
+		sanitizeFilename(email),
+	)
+
+	f, err := os.Create(filepath.Join(m.dir, name))
+	if err != nil {
+		return -1, err
+	}
+	defer f.Close()
+
+	if _, err := message.WriteTo(f); err != nil {
+		return -1, err
+	}
+
+	return 200, f.Close()
+}
+
+func sanitizeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == '@':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"html/template"
+	"slices"
+	"time"
+)
+
+// DefaultLocale is used for users without a supported locale and for templates
+// that haven't been translated
+const DefaultLocale = "en"
+
+// Locales are the locales the templates are written in
+var Locales = []string{"en", "fi"}
+
+func IsSupportedLocale(locale string) bool {
+	return slices.Contains(Locales, locale)
+}
+
+// layouts of the date helpers per locale
+var dateLayouts = map[string]struct {
+	date      string
+	shortDate string
+	dateTime  string
+}{
+	"en": {date: "Jan 2, 2006", shortDate: "Jan 2", dateTime: "Jan 2, 2006 3:04 PM"},
+	"fi": {date: "2.1.2006", shortDate: "2.1.", dateTime: "2.1.2006 klo 15.04"},
+}
+
+// templateFuncs are the helpers available in the templates. The date helpers
+// accept a time.Time or an RFC 3339 / YYYY-MM-DD string, which is what a
+// time.Time becomes after a round trip through the outbox.
+func templateFuncs(locale string) template.FuncMap {
+	layouts, ok := dateLayouts[locale]
+	if !ok {
+		layouts = dateLayouts[DefaultLocale]
+	}
+
+	format := func(layout string) func(v any) string {
+		return func(v any) string {
+			t, ok := toTime(v)
+			if !ok {
+				return ""
+			}
+			return t.Format(layout)
+		}
+	}
+
+	return template.FuncMap{
+		"date":      format(layouts.date),
+		"shortdate": format(layouts.shortDate),
+		"datetime":  format(layouts.dateTime),
+	}
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -1,17 +1,131 @@
 package mailer
 
-import "embed"
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"path"
+
+	gomail "gopkg.in/mail.v2"
+)
+
+// MaxRetries is how many times the outbox dispatcher tries to send a message
+// before giving up. Clients make a single attempt per Send.
+const MaxRetries = 8
 
 const (
 	FromName              = "Habitisti"
-	maxRetires            = 3
 	UserWelcomeTemplate   = "user_invitation.tmpl"
 	PasswordResetTemplate = "password_reset.tmpl"
+	YearReviewTemplate    = "year_review.tmpl"
+	HabitReminderTemplate = "habit_reminder.tmpl"
+	WeeklyDigestTemplate  = "weekly_digest.tmpl"
 )
 
+// Templates live in templates/<locale>/. A template missing from a locale
+// falls back to DefaultLocale, which has every template.
+//
 //go:embed "templates"
 var FS embed.FS
 
 type Client interface {
-	Send(templateFile, username, email string, data any, isSandbox bool) (int, error)
+	// Send renders templateFile in the recipient's locale and sends it
+	Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error)
+}
+
+// HeaderProvider is implemented by template data that adds headers to the
+// message, e.g. List-Unsubscribe
+type HeaderProvider interface {
+	MailHeaders() map[string]string
+}
+
+// Rendered is a template executed for one recipient
+type Rendered struct {
+	Subject string            `json:"subject"`
+	HTML    string            `json:"html"`
+	Text    string            `json:"text"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Render executes the subject and body blocks of an embedded template in the
+// given locale. The plain-text alternative is generated from the HTML body.
+func Render(templateFile, locale string, data any) (*Rendered, error) {
+	locale = resolveLocale(templateFile, locale)
+
+	// missingkey=error makes map data (e.g. from the outbox) fail like a struct would
+	tmpl, err := template.New(templateFile).
+		Option("missingkey=error").
+		Funcs(templateFuncs(locale)).
+		ParseFS(FS, path.Join("templates", locale, templateFile))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	if err := tmpl.ExecuteTemplate(subject, "subject", data); err != nil {
+		return nil, err
+	}
+
+	body := new(bytes.Buffer)
+	if err := tmpl.ExecuteTemplate(body, "body", data); err != nil {
+		return nil, err
+	}
+
+	rendered := &Rendered{
+		Subject: subject.String(),
+		HTML:    body.String(),
+		Text:    htmlToText(body.String()),
+	}
+	if h, ok := data.(HeaderProvider); ok {
+		rendered.Headers = h.MailHeaders()
+	}
+
+	return rendered, nil
+}
+
+// Templates lists the embedded templates, i.e. those of the default locale
+func Templates() ([]string, error) {
+	entries, err := fs.ReadDir(FS, path.Join("templates", DefaultLocale))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && path.Ext(e.Name()) == ".tmpl" {
+			names = append(names, e.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// resolveLocale returns the locale whose variant of templateFile is used
+func resolveLocale(templateFile, locale string) string {
+	if !IsSupportedLocale(locale) {
+		return DefaultLocale
+	}
+
+	if _, err := fs.Stat(FS, path.Join("templates", locale, templateFile)); err != nil {
+		return DefaultLocale
+	}
+
+	return locale
+}
+
+// newMessage builds the MIME message sent by the SMTP based clients: plain text
+// first and HTML as the preferred alternative
+func newMessage(fromEmail, username, email string, r *Rendered) *gomail.Message {
+	message := gomail.NewMessage()
+	message.SetAddressHeader("From", fromEmail, FromName)
+	message.SetAddressHeader("To", email, username)
+	message.SetHeader("Subject", r.Subject)
+	for k, v := range r.Headers {
+		message.SetHeader(k, v)
+	}
+	message.SetBody("text/plain", r.Text)
+	message.AddAlternative("text/html", r.HTML)
+
+	return message
 }
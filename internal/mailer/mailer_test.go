@@ -0,0 +1,224 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var resetData = map[string]any{
+	"Username":  "Maija",
+	"ResetURL":  "http://example.test/reset-password/abc",
+	"ExpiresAt": "2026-10-19T15:04:00+03:00",
+}
+
+func TestFileClient_WritesEML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	client, err := NewFileClient(dir, "no-reply@example.test")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	if _, err := client.Send(PasswordResetTemplate, "en", "Maija", "maija@example.test", resetData, true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("want one .eml file got %v (%v)", files, err)
+	}
+	if !strings.Contains(filepath.Base(files[0]), "password_reset-maija@example.test") {
+		t.Fatalf("file name: got %s", files[0])
+	}
+
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	for _, want := range []string{
+		`From: "Habitisti" <no-reply@example.test>`,
+		`To: "Maija" <maija@example.test>`,
+		"Subject: Reset your password",
+		"Content-Type: text/plain",
+		"Content-Type: text/html",
+		"Click here to reset your password (http://example.test/reset-password/abc)",
+		"Oct 19, 2026 3:04 PM",
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Fatalf("eml: want %q in\n%s", want, raw)
+		}
+	}
+}
+
+func TestSMTPClient_SendsToServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go serveSMTP(t, ln, received)
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	client, err := NewSMTPClient(SMTPConfig{Host: "127.0.0.1", Port: port, TLS: SMTPNoTLS}, "no-reply@example.test")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	if _, err := client.Send(PasswordResetTemplate, "fi", "Maija", "maija@example.test", resetData, false); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	data := <-received
+	if !strings.Contains(data, "To: \"Maija\" <maija@example.test>") || !strings.Contains(data, "19.10.2026 klo 15.04") {
+		t.Fatalf("smtp data: got\n%s", data)
+	}
+}
+
+func TestRender_LocaleFallback(t *testing.T) {
+	tests := []struct {
+		locale      string
+		wantSubject string
+	}{
+		{"fi", "Salasanan vaihto"},
+		{"en", "Reset your password"},
+		{"sv", "Reset your password"},
+		{"", "Reset your password"},
+	}
+
+	for _, tt := range tests {
+		r, err := Render(PasswordResetTemplate, tt.locale, resetData)
+		if err != nil {
+			t.Fatalf("render %q: %v", tt.locale, err)
+		}
+		if r.Subject != tt.wantSubject {
+			t.Errorf("render %q: subject = %q, want %q", tt.locale, r.Subject, tt.wantSubject)
+		}
+	}
+}
+
+type resetWithHeaders struct {
+	Username  string
+	ResetURL  string
+	ExpiresAt string
+}
+
+func (resetWithHeaders) MailHeaders() map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<http://example.test/unsubscribe/abc>",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+func TestNewMessage_DataHeaders(t *testing.T) {
+	r, err := Render(PasswordResetTemplate, "en", resetWithHeaders{Username: "Maija", ResetURL: "http://example.test/reset-password/abc"})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	message := newMessage("no-reply@example.test", "Maija", "maija@example.test", r)
+	if got := message.GetHeader("List-Unsubscribe"); len(got) != 1 || got[0] != "<http://example.test/unsubscribe/abc>" {
+		t.Fatalf("List-Unsubscribe: got %v", got)
+	}
+	if got := message.GetHeader("List-Unsubscribe-Post"); len(got) != 1 || got[0] != "List-Unsubscribe=One-Click" {
+		t.Fatalf("List-Unsubscribe-Post: got %v", got)
+	}
+
+	if r, _ := Render(PasswordResetTemplate, "en", resetData); len(r.Headers) != 0 {
+		t.Fatalf("headers without a provider: got %v", r.Headers)
+	}
+}
+
+func TestSendGridMailer_FailsOnErrorStatus(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	m := NewSendgrid("key", "no-reply@example.test")
+	m.client.BaseURL = server.URL + "/v3/mail/send"
+
+	code, err := m.Send(PasswordResetTemplate, "en", "Maija", "maija@example.test", resetData, false)
+	if err == nil || code != http.StatusServiceUnavailable {
+		t.Fatalf("503: want an error got %d, %v", code, err)
+	}
+
+	status = http.StatusAccepted
+	if _, err := m.Send(PasswordResetTemplate, "en", "Maija", "maija@example.test", resetData, false); err != nil {
+		t.Fatalf("202: %v", err)
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	body := `<!doctype html><html><head><title>x</title><style>p{}</style></head><body>
+		<p>Hi   Maija,</p>
+		<ul><li>Read: 3</li><li>Run: 1</li></ul>
+		<p><a href="http://example.test/a">http://example.test/a</a> or <a href="http://example.test/b">here</a></p>
+	</body></html>`
+
+	want := "Hi Maija,\n\n- Read: 3\n- Run: 1\n\nhttp://example.test/a or here (http://example.test/b)\n"
+	if got := htmlToText(body); got != want {
+		t.Fatalf("htmlToText:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestNewSMTPClient_RejectsUnknownTLSMode(t *testing.T) {
+	if _, err := NewSMTPClient(SMTPConfig{Host: "localhost", Port: 25, TLS: "maybe"}, "no-reply@example.test"); err == nil {
+		t.Fatal("want error for unknown tls mode")
+	}
+}
+
+// serveSMTP answers a single SMTP session and sends the DATA section to received.
+func serveSMTP(t *testing.T, ln net.Listener, received chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	reply := func(line string) {
+		if err := tp.PrintfLine("%s", line); err != nil {
+			t.Errorf("smtp write: %v", err)
+		}
+	}
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := bufio.NewReader(tp.DotReader()).ReadString(0)
+			if err != nil && data == "" {
+				t.Errorf("smtp data: %v", err)
+			}
+			received <- data
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
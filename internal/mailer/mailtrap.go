@@ -1,10 +1,8 @@
 package mailer
 
 import (
-	"bytes"
 	"errors"
-	"fmt"
-	"html/template"
+	"log"
 
 	gomail "gopkg.in/mail.v2"
 )
@@ -25,38 +23,18 @@ func NewMailTrapClient(apiKey, fromEmail string) (mailtrapClient, error) {
 	}, nil
 }
 
-func (m mailtrapClient) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
-	// Template parsing and building
-	tmpl, err := template.ParseFS(FS, "templates/"+templateFile)
+func (m mailtrapClient) Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error) {
+	r, err := Render(templateFile, locale, data)
 	if err != nil {
 		return -1, err
 	}
 
-	subject := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "subject", data)
-	if err != nil {
-		return -1, err
-	}
-
-	body := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(body, "body", data)
-	if err != nil {
-		return -1, err
-	}
-
-	message := gomail.NewMessage()
-	message.SetHeader("From", m.fromEmail)
-
-	message.SetHeader("To", email)
-
-	message.SetHeader("Subject", subject.String())
-	message.AddAlternative("text/html", body.String())
+	message := newMessage(m.fromEmail, username, email, r)
 
+	// The body holds activation and reset links, so it is never printed;
+	// MAIL_PROVIDER=file keeps the messages for reading in development
 	if isSandbox {
-		fmt.Println("DEV: skipping email sending in sandbox mode")
-		fmt.Println("To:", email)
-		fmt.Println("Subject:", subject.String())
-		fmt.Println("Body:", body.String())
+		log.Printf("mailtrap: sandbox mode, not sending %s email", templateFile)
 		return 200, nil
 	}
 
@@ -0,0 +1,68 @@
+package mailer
+
+import "time"
+
+// sampleTime is fixed so previews and snapshots are stable
+var sampleTime = time.Date(2026, time.October, 19, 15, 4, 0, 0, time.FixedZone("EEST", 3*60*60))
+
+// samples mirror the data the API passes to Send for each template; keep them
+// in sync when a template gains a field
+var samples = map[string]map[string]any{
+	UserWelcomeTemplate: {
+		"Username":      "maija",
+		"ActivationURL": "http://localhost:5173/confirm/3f1c7a52-5b1e-4c39-9d5e-6a0f6f2a9b10",
+	},
+	PasswordResetTemplate: {
+		"Username":  "maija",
+		"ResetURL":  "http://localhost:5173/reset-password/3f1c7a52-5b1e-4c39-9d5e-6a0f6f2a9b10",
+		"ExpiresAt": sampleTime.Add(time.Hour),
+	},
+	HabitReminderTemplate: {
+		"Username": "maija",
+		"Title":    "Reminder: Read",
+		"Body":     "Don't forget to Read today.",
+		"URL":      "http://localhost:5173/today",
+	},
+	WeeklyDigestTemplate: {
+		"Username":          "maija",
+		"WeekStart":         sampleTime.AddDate(0, 0, -7),
+		"WeekEnd":           sampleTime.AddDate(0, 0, -1),
+		"TotalCompletions":  23,
+		"CompletionPercent": 82,
+		"Streaks": []map[string]any{
+			{"Name": "Read", "Days": 12},
+			{"Name": "Run", "Days": 4},
+		},
+		"Missed": []map[string]any{
+			{"Name": "Meditate", "Days": 1},
+			{"Name": "Stretch", "Days": 3},
+		},
+		"Goals": []map[string]any{
+			{"Description": "Read 24 books", "Percent": 63},
+		},
+		"AppURL":         "http://localhost:5173",
+		"UnsubscribeURL": "http://localhost:8080/v1/unsubscribe/sample-token",
+	},
+	YearReviewTemplate: {
+		"Username":          "maija",
+		"Year":              2025,
+		"TotalCompletions":  1024,
+		"ActiveDays":        301,
+		"CompletionPercent": 78,
+		"BestStreaks": []map[string]any{
+			{"Name": "Read", "Days": 64},
+		},
+		"MostConsistent": []map[string]any{
+			{"Name": "Run", "Percent": 91},
+		},
+		"GoalsAchieved": 3,
+		"GoalsTotal":    4,
+		"ReviewURL":     "http://localhost:5173/review/2025",
+	},
+}
+
+// SampleData returns example data for rendering templateFile in previews and tests
+func SampleData(templateFile string) (map[string]any, bool) {
+	data, ok := samples[templateFile]
+	return data, ok
+}
@@ -1,13 +1,10 @@
 package mailer
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
-	"html/template"
 	"log"
-	"time"
 )
 
 type SendGridMailer struct {
@@ -26,49 +23,37 @@ func NewSendgrid(apiKey, fromEmail string) *SendGridMailer {
 	}
 }
 
-func (m *SendGridMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+func (m *SendGridMailer) Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error) {
 	from := mail.NewEmail(FromName, m.fromEmail)
 	to := mail.NewEmail(username, email)
 
-	// template parsing and building
-	tmpl, err := template.ParseFS(FS, "templates/"+templateFile)
+	r, err := Render(templateFile, locale, data)
 	if err != nil {
 		return -1, err
 	}
 
-	subject := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "subject", data)
-	if err != nil {
-		return -1, err
-	}
-
-	body := new(bytes.Buffer)
-	if err = tmpl.ExecuteTemplate(body, "body", data); err != nil {
-		return -1, err
+	message := mail.NewSingleEmail(from, r.Subject, to, r.Text, r.HTML)
+	for k, v := range r.Headers {
+		message.SetHeader(k, v)
 	}
 
-	message := mail.NewSingleEmail(from, subject.String(), to, "", body.String())
-
 	message.SetMailSettings(&mail.MailSettings{
 		SandboxMode: &mail.Setting{
 			Enable: &isSandbox,
 		},
 	})
 
-	for i := 0; i < maxRetires; i++ {
-		response, err := m.client.Send(message)
-		if err != nil {
-			log.Printf("Failed to send email to %v, attempt %d of %d", email, i+1, maxRetires)
-			log.Printf("Error: %v", err.Error())
-
-			// exponnential backoff
-			time.Sleep(time.Second * time.Duration(i+1))
-			continue
-		}
+	// retries are handled by the outbox dispatcher
+	response, err := m.client.Send(message)
+	if err != nil {
+		return -1, fmt.Errorf("failed to send email to %v: %w", email, err)
+	}
 
-		log.Printf("Email sent with status code %v", response.StatusCode)
-		return 200, nil
+	// SendGrid returns no error for rejected requests, only the status code
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("failed to send email to %v: status %d: %s", email, response.StatusCode, response.Body)
 	}
 
-	return -1, fmt.Errorf("Failed to send email after %d attempts", maxRetires)
+	log.Printf("Email sent with status code %v", response.StatusCode)
+	return response.StatusCode, nil
 }
@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+
+	gomail "gopkg.in/mail.v2"
+)
+
+// TLS modes of an SMTP connection
+const (
+	// SMTPStartTLS upgrades a plain connection with STARTTLS and fails if the server doesn't support it
+	SMTPStartTLS = "starttls"
+	// SMTPImplicitTLS connects over TLS right away, usually on port 465
+	SMTPImplicitTLS = "tls"
+	// SMTPNoTLS sends in plain text, e.g. to a local Mailpit
+	SMTPNoTLS = "none"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLS      string
+}
+
+// SMTPClient sends mail through any SMTP server. Unlike the API based clients
+// it has no sandbox mode: in development point it to a local capture server.
+type SMTPClient struct {
+	fromEmail string
+	dialer    *gomail.Dialer
+}
+
+func NewSMTPClient(cfg SMTPConfig, fromEmail string) (*SMTPClient, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("no smtp host provided")
+	}
+
+	dialer := gomail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password)
+
+	switch cfg.TLS {
+	case SMTPStartTLS, "":
+		dialer.SSL = false
+		dialer.StartTLSPolicy = gomail.MandatoryStartTLS
+	case SMTPImplicitTLS:
+		dialer.SSL = true
+	case SMTPNoTLS:
+		dialer.SSL = false
+		dialer.StartTLSPolicy = gomail.NoStartTLS
+	default:
+		return nil, fmt.Errorf("unknown smtp tls mode %q", cfg.TLS)
+	}
+
+	return &SMTPClient{
+		fromEmail: fromEmail,
+		dialer:    dialer,
+	}, nil
+}
+
+func (m *SMTPClient) Send(templateFile, locale, username, email string, data any, isSandbox bool) (int, error) {
+	r, err := Render(templateFile, locale, data)
+	if err != nil {
+		return -1, err
+	}
+
+	message := newMessage(m.fromEmail, username, email, r)
+
+	if err := m.dialer.DialAndSend(message); err != nil {
+		return -1, err
+	}
+
+	return 200, nil
+}
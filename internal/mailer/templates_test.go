@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the template snapshots in testdata")
+
+// TestTemplates_Snapshots renders every embedded template in every locale with
+// its sample data and compares the result to testdata/<locale>/<template>.golden.
+// Run `go test ./internal/mailer -run Snapshots -update` after changing a template.
+func TestTemplates_Snapshots(t *testing.T) {
+	templates, err := Templates()
+	if err != nil {
+		t.Fatalf("list templates: %v", err)
+	}
+	if len(templates) == 0 {
+		t.Fatal("no templates embedded")
+	}
+
+	for _, locale := range Locales {
+		for _, name := range templates {
+			t.Run(locale+"/"+name, func(t *testing.T) {
+				data, ok := SampleData(name)
+				if !ok {
+					t.Fatalf("no sample data for %s", name)
+				}
+
+				r, err := Render(name, locale, data)
+				if err != nil {
+					t.Fatalf("render: %v", err)
+				}
+				if strings.TrimSpace(r.Subject) == "" || strings.TrimSpace(r.Text) == "" {
+					t.Fatalf("render: empty subject or body: %+v", r)
+				}
+
+				assertSnapshot(t, filepath.Join("testdata", locale, strings.TrimSuffix(name, ".tmpl")+".golden"),
+					"Subject: "+r.Subject+"\n\n--- text ---\n"+r.Text+"\n--- html ---\n"+r.HTML)
+			})
+		}
+	}
+}
+
+// TestTemplates_LocalesHaveDefault makes sure every translated template also
+// exists in the default locale, which is what the fallback relies on.
+func TestTemplates_LocalesHaveDefault(t *testing.T) {
+	for _, locale := range Locales {
+		entries, err := fs.ReadDir(FS, path.Join("templates", locale))
+		if err != nil {
+			t.Fatalf("read %s: %v", locale, err)
+		}
+
+		for _, e := range entries {
+			if _, err := fs.Stat(FS, path.Join("templates", DefaultLocale, e.Name())); err != nil {
+				t.Errorf("%s/%s has no %s version", locale, e.Name(), DefaultLocale)
+			}
+		}
+	}
+}
+
+// assertSnapshot compares got to the file at path, or writes it with -update
+func assertSnapshot(t *testing.T, path, got string) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("snapshot dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("write snapshot: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot (run with -update to create it): %v", err)
+	}
+
+	if got != string(want) {
+		t.Fatalf("%s is out of date (run with -update if the change is intended)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
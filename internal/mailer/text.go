@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	spaces     = regexp.MustCompile(`[ \t\r\n]+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText converts a rendered HTML body to the plain-text alternative:
+// paragraphs become blank line separated, list items get a dash and links are
+// written out as "text (url)".
+func htmlToText(body string) string {
+	var b strings.Builder
+	var skip int
+	var href, linkText string
+	inLink := false
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		tok := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch tok.Data {
+			case "head", "style", "script", "title":
+				if tt == html.StartTagToken {
+					skip++
+				}
+			case "br":
+				b.WriteString("\n")
+			case "p", "div", "ul", "ol", "table", "tr", "h1", "h2", "h3", "h4":
+				b.WriteString("\n\n")
+			case "li":
+				b.WriteString("\n- ")
+			case "a":
+				inLink = true
+				linkText = ""
+				href = ""
+				for _, a := range tok.Attr {
+					if a.Key == "href" {
+						href = a.Val
+					}
+				}
+			}
+		case html.EndTagToken:
+			switch tok.Data {
+			case "head", "style", "script", "title":
+				if skip > 0 {
+					skip--
+				}
+			case "p", "div", "ul", "ol", "table", "tr", "h1", "h2", "h3", "h4":
+				b.WriteString("\n\n")
+			case "a":
+				inLink = false
+				text := strings.TrimSpace(linkText)
+				switch {
+				case href == "" || text == href:
+					b.WriteString(text)
+				case text == "":
+					b.WriteString(href)
+				default:
+					b.WriteString(text + " (" + href + ")")
+				}
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := spaces.ReplaceAllString(tok.Data, " ")
+			if inLink {
+				linkText += text
+				continue
+			}
+			b.WriteString(text)
+		}
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")) + "\n"
+}
@@ -0,0 +1,391 @@
+// Package migrate applies the SQL migrations embedded in the binaries.
+//
+// State is kept in golang-migrate's schema_migrations(version, dirty) table, so
+// databases migrated with the migrate CLI keep working and vice versa. Every
+// command holds a Postgres advisory lock, so instances starting at the same
+// time run the migrations once.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NilVersion is the version of a database without migrations
+const NilVersion = -1
+
+var (
+	ErrDirty       = errors.New("database is dirty: fix the failed migration by hand and force the version")
+	ErrNoChange    = errors.New("no change")
+	ErrNoMigration = errors.New("no such migration version")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is one numbered pair of up and down scripts
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	hasUp   bool
+	hasDown bool
+}
+
+// Status describes the state of the database
+type Status struct {
+	Version    int
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+type MigrationStatus struct {
+	Version int
+	Name    string
+	Applied bool
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New reads the <version>_<name>.up.sql and .down.sql files at the root of fsys
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := parse(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(body)
+			mig.hasUp = true
+		} else {
+			mig.Down = string(body)
+			mig.hasDown = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if !mig.hasUp {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrations returns the known migrations in order
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the newest known version, or NilVersion without migrations
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return NilVersion
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the current version of the database. It doesn't lock or
+// create anything, so it's cheap enough for health checks.
+func (m *Migrator) Version(ctx context.Context) (version int, dirty bool, err error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return NilVersion, false, err
+	}
+	if !exists {
+		return NilVersion, false, nil
+	}
+
+	return currentVersion(ctx, m.db)
+}
+
+// Status returns the current version and which migrations are applied
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Version: version, Dirty: dirty}
+	for _, mig := range m.migrations {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version: mig.Version,
+			Name:    mig.Name,
+			Applied: mig.Version <= version,
+		})
+	}
+
+	return status, nil
+}
+
+// Up applies all pending migrations, or at most n when n > 0
+func (m *Migrator) Up(ctx context.Context, n int) ([]Migration, error) {
+	return m.locked(ctx, func(conn *sql.Conn, version int) ([]Migration, error) {
+		var pending []Migration
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				pending = append(pending, mig)
+			}
+		}
+		if n > 0 && len(pending) > n {
+			pending = pending[:n]
+		}
+
+		return m.apply(ctx, conn, pending, true)
+	})
+}
+
+// Down rolls back the n newest applied migrations
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	return m.locked(ctx, func(conn *sql.Conn, version int) ([]Migration, error) {
+		var applied []Migration
+		for i := len(m.migrations) - 1; i >= 0 && len(applied) < n; i-- {
+			if m.migrations[i].Version <= version {
+				applied = append(applied, m.migrations[i])
+			}
+		}
+
+		return m.apply(ctx, conn, applied, false)
+	})
+}
+
+// To migrates up or down to version
+func (m *Migrator) To(ctx context.Context, version int) ([]Migration, error) {
+	if version != NilVersion && m.index(version) < 0 {
+		return nil, ErrNoMigration
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn, current int) ([]Migration, error) {
+		var steps []Migration
+		if version >= current {
+			for _, mig := range m.migrations {
+				if mig.Version > current && mig.Version <= version {
+					steps = append(steps, mig)
+				}
+			}
+			return m.apply(ctx, conn, steps, true)
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if mig := m.migrations[i]; mig.Version <= current && mig.Version > version {
+				steps = append(steps, mig)
+			}
+		}
+		return m.apply(ctx, conn, steps, false)
+	})
+}
+
+// Force sets the version without running migrations and clears the dirty flag.
+// NilVersion marks the database as having no migrations.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version != NilVersion && m.index(version) < 0 {
+		return ErrNoMigration
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := lock(ctx, conn); err != nil {
+		return err
+	}
+	defer unlock(conn)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return setVersion(ctx, conn, version, false)
+}
+
+// locked runs fn on a single connection holding the advisory lock. fn gets the
+// current version; a dirty database is refused.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, version int) ([]Migration, error)) ([]Migration, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := lock(ctx, conn); err != nil {
+		return nil, err
+	}
+	defer unlock(conn)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	version, dirty, err := currentVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, fmt.Errorf("version %d: %w", version, ErrDirty)
+	}
+
+	return fn(conn, version)
+}
+
+// apply runs the migrations in the given order. The version is marked dirty
+// while a script runs, so a failure leaves the database dirty like the
+// migrate CLI does.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, steps []Migration, up bool) ([]Migration, error) {
+	if len(steps) == 0 {
+		return nil, ErrNoChange
+	}
+
+	var done []Migration
+	for _, mig := range steps {
+		script, target := mig.Up, mig.Version
+		if !up {
+			if !mig.hasDown {
+				return done, fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+			}
+			script, target = mig.Down, m.previous(mig.Version)
+		}
+
+		if err := setVersion(ctx, conn, target, true); err != nil {
+			return done, err
+		}
+
+		if strings.TrimSpace(script) != "" {
+			if _, err := conn.ExecContext(ctx, script); err != nil {
+				return done, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		if err := setVersion(ctx, conn, target, false); err != nil {
+			return done, err
+		}
+
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+func (m *Migrator) index(version int) int {
+	for i, mig := range m.migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// previous returns the version before version, or NilVersion for the first one
+func (m *Migrator) previous(version int) int {
+	i := m.index(version)
+	if i <= 0 {
+		return NilVersion
+	}
+	return m.migrations[i-1].Version
+}
+
+// the lock key is per database, so separate databases on one server don't block each other
+func lock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext(current_database() || '.schema_migrations'))`)
+	return err
+}
+
+func unlock(conn *sql.Conn) {
+	_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext(current_database() || '.schema_migrations'))`)
+}
+
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`)
+	return err
+}
+
+// querier is the part of *sql.DB and *sql.Conn currentVersion needs
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func currentVersion(ctx context.Context, conn querier) (int, bool, error) {
+	var version int
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return NilVersion, false, nil
+	}
+	if err != nil {
+		return NilVersion, false, err
+	}
+
+	return version, dirty, nil
+}
+
+// setVersion replaces the single schema_migrations row; NilVersion leaves the table empty
+func setVersion(ctx context.Context, conn *sql.Conn, version int, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `TRUNCATE schema_migrations`); err != nil {
+		return err
+	}
+
+	if version != NilVersion {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
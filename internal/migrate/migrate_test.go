@@ -0,0 +1,170 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"juhojarvi/habits/cmd/migrate/migrations"
+	"juhojarvi/habits/internal/migrate"
+
+	_ "github.com/lib/pq"
+)
+
+func TestNew_EmbeddedMigrations(t *testing.T) {
+	m, err := migrate.New(nil, migrations.FS)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	prev := migrate.NilVersion
+	for _, mig := range m.Migrations() {
+		if mig.Version != prev+1 && prev != migrate.NilVersion {
+			t.Errorf("migration %d follows %d: versions should be sequential", mig.Version, prev)
+		}
+		if mig.Up == "" || mig.Down == "" {
+			t.Errorf("migration %d_%s: want up and down scripts", mig.Version, mig.Name)
+		}
+		prev = mig.Version
+	}
+
+	if m.Latest() != prev || prev < 1 {
+		t.Fatalf("latest: got %d want %d", m.Latest(), prev)
+	}
+}
+
+func TestNew_InvalidMigrations(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing up": {
+			"000001_a.down.sql": {Data: []byte("SELECT 1")},
+		},
+		"version reused": {
+			"000001_a.up.sql": {Data: []byte("SELECT 1")},
+			"000001_b.up.sql": {Data: []byte("SELECT 1")},
+		},
+	}
+
+	for name, fsys := range tests {
+		if _, err := migrate.New(nil, fsys); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+// TestMigrator runs toy migrations in a scratch schema, so the real schema of
+// the test database is left alone.
+func TestMigrator(t *testing.T) {
+	db := scratchDB(t)
+	ctx := context.Background()
+
+	m, err := migrate.New(db, fstest.MapFS{
+		"000001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id int)")},
+		"000001_create_a.down.sql": {Data: []byte("DROP TABLE a")},
+		"000002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id int); CREATE TABLE c (id int)")},
+		"000002_create_b.down.sql": {Data: []byte("DROP TABLE c; DROP TABLE b")},
+		"000003_broken.up.sql":     {Data: []byte("CREATE TABLE a (id int)")},
+		"000003_broken.down.sql":   {Data: []byte("")},
+		"README.md":                {Data: []byte("not a migration")},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	assertVersion := func(want int, wantDirty bool) {
+		t.Helper()
+		got, dirty, err := m.Version(ctx)
+		if err != nil {
+			t.Fatalf("version: %v", err)
+		}
+		if got != want || dirty != wantDirty {
+			t.Fatalf("version: got %d dirty=%v want %d dirty=%v", got, dirty, want, wantDirty)
+		}
+	}
+
+	assertVersion(migrate.NilVersion, false)
+
+	if applied, err := m.To(ctx, 2); err != nil || len(applied) != 2 {
+		t.Fatalf("to 2: applied %d err %v", len(applied), err)
+	}
+	assertVersion(2, false)
+
+	if _, err := m.To(ctx, 2); !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("to 2 again: want ErrNoChange got %v", err)
+	}
+
+	// 3 fails and leaves the database dirty until forced
+	if _, err := m.Up(ctx, 0); err == nil {
+		t.Fatal("up: want error from the broken migration")
+	}
+	assertVersion(3, true)
+
+	if _, err := m.Down(ctx, 1); !errors.Is(err, migrate.ErrDirty) {
+		t.Fatalf("down while dirty: want ErrDirty got %v", err)
+	}
+
+	if err := m.Force(ctx, 2); err != nil {
+		t.Fatalf("force: %v", err)
+	}
+	assertVersion(2, false)
+
+	if applied, err := m.Down(ctx, 2); err != nil || len(applied) != 2 {
+		t.Fatalf("down 2: applied %d err %v", len(applied), err)
+	}
+	assertVersion(migrate.NilVersion, false)
+
+	var tables int
+	if err := db.QueryRow(`SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name IN ('a', 'b', 'c')`).Scan(&tables); err != nil {
+		t.Fatalf("count tables: %v", err)
+	}
+	if tables != 0 {
+		t.Fatalf("after down: want no tables got %d", tables)
+	}
+}
+
+func scratchDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	addr := os.Getenv("TEST_DB_ADDR")
+	if addr == "" {
+		addr = os.Getenv("DB_ADDR")
+	}
+	if addr == "" {
+		t.Skip("DB_ADDR/TEST_DB_ADDR is not set")
+	}
+
+	admin, err := sql.Open("postgres", addr)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer admin.Close()
+
+	if _, err := admin.Exec(`DROP SCHEMA IF EXISTS migrate_test CASCADE; CREATE SCHEMA migrate_test`); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		t.Fatalf("parse addr: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", "migrate_test")
+	u.RawQuery = q.Encode()
+
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatalf("open scratch: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if admin, err := sql.Open("postgres", addr); err == nil {
+			_, _ = admin.Exec(`DROP SCHEMA IF EXISTS migrate_test CASCADE`)
+			admin.Close()
+		}
+	})
+
+	return db
+}
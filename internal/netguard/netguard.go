@@ -0,0 +1,165 @@
+// Package netguard keeps requests the server makes to user supplied URLs
+// (webhooks, push endpoints) away from internal networks.
+//
+// URLs are checked when they are saved, and every connection is checked again
+// after DNS resolution, so a hostname that later resolves to a private address
+// (DNS rebinding) is refused as well.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBlockedAddress = errors.New("address not allowed")
+	ErrInvalidURL     = errors.New("invalid url")
+)
+
+// blocked lists special-purpose ranges not covered by the netip predicates
+var blocked = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64 can reach any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// Guard decides which addresses outbound requests may connect to.
+type Guard struct {
+	allow []netip.Prefix
+}
+
+// New returns a Guard that only allows public addresses, plus the comma
+// separated addresses or CIDR prefixes in allow, e.g. "127.0.0.1" for a local
+// test receiver. Allowed addresses may also be used over plain http.
+func New(allow string) (*Guard, error) {
+	g := &Guard{}
+	for _, s := range strings.Split(allow, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("netguard: invalid address %q", s)
+			}
+			g.allow = append(g.allow, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("netguard: invalid prefix %q", s)
+		}
+		g.allow = append(g.allow, prefix.Masked())
+	}
+
+	return g, nil
+}
+
+// Allowed reports whether connecting to addr is permitted.
+func (g *Guard) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if g.explicitlyAllowed(addr) {
+		return true
+	}
+
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return false
+	}
+
+	for _, p := range blocked {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (g *Guard) explicitlyAllowed(addr netip.Addr) bool {
+	for _, p := range g.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL validates a URL before it is saved: it must be https (http only for
+// explicitly allowed addresses) and must not name a local or internal host.
+func (g *Guard) CheckURL(u *url.URL) error {
+	if u == nil || u.Host == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if g.explicitlyAllowed(addr.Unmap()) && (u.Scheme == "https" || u.Scheme == "http") {
+			return nil
+		}
+		if u.Scheme != "https" {
+			return ErrInvalidURL
+		}
+		if !g.Allowed(addr) {
+			return ErrBlockedAddress
+		}
+		return nil
+	}
+
+	if u.Scheme != "https" {
+		return ErrInvalidURL
+	}
+
+	// Single label names resolve through the search domains of the host network
+	if !strings.Contains(host, ".") ||
+		host == "localhost" || strings.HasSuffix(host, ".localhost") ||
+		strings.HasSuffix(host, ".internal") || strings.HasSuffix(host, ".local") {
+		return ErrBlockedAddress
+	}
+
+	return nil
+}
+
+// Control is a net.Dialer Control function. It runs for every address dialed,
+// after DNS resolution.
+func (g *Guard) Control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	if !g.Allowed(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ap.Addr())
+	}
+	return nil
+}
+
+// Transport returns an http.Transport that only connects to allowed addresses.
+// Proxies are not used, as the proxy would make the connection instead.
+func (g *Guard) Transport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   g.Control,
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
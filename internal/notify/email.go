@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"context"
+	"juhojarvi/habits/internal/mailer"
+)
+
+// EmailChannel sends notifications with the habit reminder mail template.
+type EmailChannel struct {
+	client    mailer.Client
+	isSandbox bool
+}
+
+func NewEmailChannel(client mailer.Client, isSandbox bool) *EmailChannel {
+	return &EmailChannel{client: client, isSandbox: isSandbox}
+}
+
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+func (c *EmailChannel) Send(ctx context.Context, n Notification) error {
+	vars := struct {
+		Username string
+		Title    string
+		Body     string
+		URL      string
+	}{
+		Username: n.Username,
+		Title:    n.Title,
+		Body:     n.Body,
+		URL:      n.URL,
+	}
+
+	_, err := c.client.Send(mailer.HabitReminderTemplate, n.Locale, n.Username, n.Email, vars, c.isSandbox)
+	return err
+}
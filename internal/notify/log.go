@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// LogChannel only logs notifications. Useful in development.
+type LogChannel struct {
+	logger *zap.SugaredLogger
+}
+
+func NewLogChannel(logger *zap.SugaredLogger) *LogChannel {
+	return &LogChannel{logger: logger}
+}
+
+func (c *LogChannel) Name() string {
+	return "log"
+}
+
+func (c *LogChannel) Send(ctx context.Context, n Notification) error {
+	c.logger.Infow("notification", "user_id", n.UserID, "title", n.Title, "body", n.Body)
+	return nil
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrNoChannels = errors.New("no notification channels configured")
+
+// Notification is a channel-agnostic message to a single user.
+type Notification struct {
+	UserID   int64
+	Username string
+	Email    string
+	Locale   string
+	Title    string
+	Body     string
+	URL      string
+}
+
+// Channel delivers notifications over one medium (email, web push, ...).
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// Dispatcher fans a notification out to every configured channel.
+type Dispatcher struct {
+	channels []Channel
+}
+
+func NewDispatcher(channels ...Channel) *Dispatcher {
+	return &Dispatcher{channels: channels}
+}
+
+// Send delivers n through all channels. It succeeds when at least one channel
+// delivered the notification, so a retry doesn't spam the channels that worked;
+// it fails with every channel's error when none did. A channel with nowhere to
+// deliver (ErrNoSubscriptions) isn't a failure, and when no channel had anywhere
+// to deliver Send returns ErrNoSubscriptions so the caller doesn't retry.
+func (d *Dispatcher) Send(ctx context.Context, n Notification) error {
+	if len(d.channels) == 0 {
+		return ErrNoChannels
+	}
+
+	var errs []error
+	skipped := 0
+	for _, ch := range d.channels {
+		if err := ch.Send(ctx, n); err != nil {
+			if errors.Is(err, ErrNoSubscriptions) {
+				skipped++
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", ch.Name(), err))
+		}
+	}
+
+	switch {
+	case skipped == len(d.channels):
+		return ErrNoSubscriptions
+	case len(errs) > 0 && len(errs)+skipped == len(d.channels):
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeChannel struct {
+	name string
+	err  error
+	sent int
+}
+
+func (c *fakeChannel) Name() string { return c.name }
+
+func (c *fakeChannel) Send(ctx context.Context, n Notification) error {
+	c.sent++
+	return c.err
+}
+
+func TestDispatcher_Send(t *testing.T) {
+	failing := errors.New("down")
+
+	t.Run("succeeds when one channel delivers", func(t *testing.T) {
+		ok := &fakeChannel{name: "ok"}
+		bad := &fakeChannel{name: "bad", err: failing}
+
+		if err := NewDispatcher(bad, ok).Send(context.Background(), Notification{}); err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		if ok.sent != 1 || bad.sent != 1 {
+			t.Fatalf("want every channel tried once, got ok=%d bad=%d", ok.sent, bad.sent)
+		}
+	})
+
+	t.Run("fails when every channel fails", func(t *testing.T) {
+		err := NewDispatcher(&fakeChannel{name: "a", err: failing}, &fakeChannel{name: "b", err: failing}).
+			Send(context.Background(), Notification{})
+		if !errors.Is(err, failing) {
+			t.Fatalf("want joined channel errors, got %v", err)
+		}
+	})
+
+	t.Run("skips channels with nowhere to deliver", func(t *testing.T) {
+		none := &fakeChannel{name: "webpush", err: ErrNoSubscriptions}
+
+		if err := NewDispatcher(none).Send(context.Background(), Notification{}); !errors.Is(err, ErrNoSubscriptions) {
+			t.Fatalf("only channel without subscriptions: want ErrNoSubscriptions, got %v", err)
+		}
+
+		err := NewDispatcher(&fakeChannel{name: "email", err: failing}, none).Send(context.Background(), Notification{})
+		if !errors.Is(err, failing) || errors.Is(err, ErrNoSubscriptions) {
+			t.Fatalf("failing channel: want only its error, got %v", err)
+		}
+
+		if err := NewDispatcher(&fakeChannel{name: "email"}, none).Send(context.Background(), Notification{}); err != nil {
+			t.Fatalf("one channel delivered: want nil error, got %v", err)
+		}
+	})
+
+	t.Run("fails without channels", func(t *testing.T) {
+		if err := NewDispatcher().Send(context.Background(), Notification{}); !errors.Is(err, ErrNoChannels) {
+			t.Fatalf("want ErrNoChannels, got %v", err)
+		}
+	})
+}
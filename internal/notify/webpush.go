@@ -0,0 +1,353 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"juhojarvi/habits/internal/netguard"
+	"juhojarvi/habits/internal/store"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// recordSize is the aes128gcm record size; the whole payload goes in one record
+	recordSize = 4096
+	// MaxPushPayload keeps the body within the 4096 bytes push services must
+	// accept: 86 bytes of header, the padding delimiter and the GCM tag
+	MaxPushPayload = 4096 - 86 - 1 - 16
+	pushTTL        = 24 * time.Hour
+	vapidExpiry    = 12 * time.Hour
+)
+
+var (
+	ErrNoSubscriptions     = errors.New("user has no push subscriptions")
+	ErrPayloadTooLarge     = errors.New("push payload too large")
+	errSubscriptionGone    = errors.New("push subscription expired")
+	errInvalidVAPIDKey     = errors.New("invalid VAPID key")
+	errInvalidSubscription = errors.New("invalid push subscription keys")
+)
+
+// PushSubscriptions is the subset of the store the web push channel needs.
+type PushSubscriptions interface {
+	GetByUser(ctx context.Context, userID int64) ([]store.PushSubscription, error)
+	DeleteByEndpoint(ctx context.Context, endpoint string) error
+}
+
+// VAPID identifies the application server to push services (RFC 8292).
+type VAPID struct {
+	// PublicKey is the uncompressed P-256 point, base64url encoded, as browsers
+	// expect it in pushManager.subscribe({applicationServerKey})
+	PublicKey string
+	subject   string
+	key       *ecdsa.PrivateKey
+}
+
+// NewVAPID parses a base64url encoded P-256 private key (the raw 32 byte
+// scalar, as generated by e.g. `npx web-push generate-vapid-keys`). subject is a
+// mailto: or https: contact for the push service operators.
+func NewVAPID(publicKey, privateKey, subject string) (*VAPID, error) {
+	d, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, errInvalidVAPIDKey
+	}
+
+	priv, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, errInvalidVAPIDKey
+	}
+
+	pub := priv.PublicKey().Bytes()
+	if publicKey != "" && publicKey != base64.RawURLEncoding.EncodeToString(pub) {
+		return nil, fmt.Errorf("%w: public key does not match the private key", errInvalidVAPIDKey)
+	}
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+
+	return &VAPID{
+		PublicKey: base64.RawURLEncoding.EncodeToString(pub),
+		subject:   subject,
+		key:       key,
+	}, nil
+}
+
+// GenerateVAPIDKeys returns a new base64url encoded key pair for NewVAPID.
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(priv.Bytes()), nil
+}
+
+// authorization returns the Authorization header for a push to endpoint.
+func (v *VAPID) authorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	// aud must be a plain string, so RegisteredClaims (which may encode it as an array) isn't used
+	claims := jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(vapidExpiry).Unix(),
+		"sub": v.subject,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(v.key)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("vapid t=%s, k=%s", token, v.PublicKey), nil
+}
+
+// WebPushChannel delivers notifications to every browser the user has
+// subscribed. Subscriptions the push service reports gone (404/410) are removed.
+type WebPushChannel struct {
+	subs   PushSubscriptions
+	vapid  *VAPID
+	client *http.Client
+}
+
+// NewWebPushChannel returns a channel that only connects to the addresses
+// guard allows, as the endpoints come from the browser.
+func NewWebPushChannel(subs PushSubscriptions, vapid *VAPID, guard *netguard.Guard) *WebPushChannel {
+	return &WebPushChannel{
+		subs:   subs,
+		vapid:  vapid,
+		client: &http.Client{Timeout: 10 * time.Second, Transport: guard.Transport()},
+	}
+}
+
+func (c *WebPushChannel) Name() string {
+	return "webpush"
+}
+
+// PublicKey returns the VAPID public key, derived from the private key when
+// it wasn't configured.
+func (c *WebPushChannel) PublicKey() string {
+	return c.vapid.PublicKey
+}
+
+// pushMessage is the JSON payload the service worker (web/public/sw.js) receives.
+type pushMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Send succeeds when the notification reached at least one of the user's browsers.
+func (c *WebPushChannel) Send(ctx context.Context, n Notification) error {
+	subs, err := c.subs.GetByUser(ctx, n.UserID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(pushMessage{Title: n.Title, Body: n.Body, URL: n.URL})
+	if err != nil {
+		return err
+	}
+
+	delivered := 0
+	var errs []error
+	for _, sub := range subs {
+		err := c.push(ctx, sub, payload)
+		switch {
+		case errors.Is(err, errSubscriptionGone):
+			if err := c.subs.DeleteByEndpoint(ctx, sub.Endpoint); err != nil {
+				errs = append(errs, err)
+			}
+		case err != nil:
+			errs = append(errs, err)
+		default:
+			delivered++
+		}
+	}
+
+	if delivered > 0 {
+		return nil
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return ErrNoSubscriptions
+}
+
+func (c *WebPushChannel) push(ctx context.Context, sub store.PushSubscription, payload []byte) error {
+	p256dh, err := base64.RawURLEncoding.DecodeString(sub.P256dh)
+	if err != nil {
+		return errInvalidSubscription
+	}
+
+	authSecret, err := base64.RawURLEncoding.DecodeString(sub.Auth)
+	if err != nil {
+		return errInvalidSubscription
+	}
+
+	body, err := encryptPayload(p256dh, authSecret, payload)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := c.vapid.authorization(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(pushTTL.Seconds())))
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errSubscriptionGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("push service responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// encryptPayload encrypts plaintext for the user agent per RFC 8291 using the
+// aes128gcm content coding (RFC 8188) with a single record.
+func encryptPayload(uaPublic, authSecret, plaintext []byte) ([]byte, error) {
+	if len(plaintext) > MaxPushPayload {
+		return nil, ErrPayloadTooLarge
+	}
+	if len(authSecret) != 16 {
+		return nil, errInvalidSubscription
+	}
+
+	curve := ecdh.P256()
+
+	uaKey, err := curve.NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, errInvalidSubscription
+	}
+
+	// A fresh application server key pair for every message
+	asKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	sharedSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	cek, nonce, err := deriveContentKeys(sharedSecret, authSecret, salt, uaPublic, asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x02 marks the last (and only) record
+	record := append(append([]byte{}, plaintext...), 0x02)
+
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	return gcm.Seal(header, nonce, record, nil), nil
+}
+
+// deriveContentKeys derives the content encryption key and nonce (RFC 8291 section 3.4).
+func deriveContentKeys(sharedSecret, authSecret, salt, uaPublic, asPublic []byte) (cek, nonce []byte, err error) {
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cek, err = hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce, err = hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cek, nonce, nil
+}
+
+// DecodeSubscriptionKeys validates the keys of a browser PushSubscription and
+// returns them base64url encoded without padding, the form push() expects.
+func DecodeSubscriptionKeys(p256dh, auth string) (string, string, error) {
+	pub, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(p256dh, "="))
+	if err != nil {
+		return "", "", errInvalidSubscription
+	}
+	if _, err := ecdh.P256().NewPublicKey(pub); err != nil {
+		return "", "", errInvalidSubscription
+	}
+
+	secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(auth, "="))
+	if err != nil || len(secret) != 16 {
+		return "", "", errInvalidSubscription
+	}
+
+	return base64.RawURLEncoding.EncodeToString(pub), base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,274 @@
+package notify
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"juhojarvi/habits/internal/netguard"
+	"juhojarvi/habits/internal/store"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type fakeSubscriptions struct {
+	subs    []store.PushSubscription
+	deleted []string
+}
+
+func (f *fakeSubscriptions) GetByUser(ctx context.Context, userID int64) ([]store.PushSubscription, error) {
+	return f.subs, nil
+}
+
+func (f *fakeSubscriptions) DeleteByEndpoint(ctx context.Context, endpoint string) error {
+	f.deleted = append(f.deleted, endpoint)
+	return nil
+}
+
+// browser is the user agent side of a subscription: its key pair and auth secret.
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) *browser {
+	t.Helper()
+
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		t.Fatal(err)
+	}
+
+	return &browser{key: key, auth: auth}
+}
+
+func (b *browser) subscription(endpoint string) store.PushSubscription {
+	return store.PushSubscription{
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(b.auth),
+	}
+}
+
+// decrypt reverses encryptPayload the way a browser does.
+func (b *browser) decrypt(body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("short body")
+	}
+
+	salt := body[:16]
+	rs := binary.BigEndian.Uint32(body[16:20])
+	idLen := int(body[20])
+	asPublic := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+
+	if uint32(len(ciphertext)) > rs {
+		return nil, errors.New("record larger than rs")
+	}
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedSecret, err := b.key.ECDH(asKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, nonce, err := deriveContentKeys(sharedSecret, b.auth, salt, b.key.PublicKey().Bytes(), asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(record) == 0 || record[len(record)-1] != 0x02 {
+		return nil, errors.New("missing last record delimiter")
+	}
+
+	return record[:len(record)-1], nil
+}
+
+// verifyVAPID checks the Authorization header like a push service would.
+func verifyVAPID(r *http.Request, audience string) error {
+	header := r.Header.Get("Authorization")
+
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[k] = v
+	}
+
+	pub, err := base64.RawURLEncoding.DecodeString(params["k"])
+	if err != nil || len(pub) != 65 {
+		return errors.New("bad k")
+	}
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pub[1:33]),
+		Y:     new(big.Int).SetBytes(pub[33:]),
+	}
+
+	token, err := jwt.Parse(params["t"], func(*jwt.Token) (any, error) { return key, nil },
+		jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(audience), jwt.WithExpirationRequired())
+	if err != nil {
+		return err
+	}
+
+	if sub, _ := token.Claims.GetSubject(); sub != "mailto:test@example.test" {
+		return errors.New("bad sub")
+	}
+
+	return nil
+}
+
+func TestWebPushChannel_Send(t *testing.T) {
+	publicKey, privateKey, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vapid, err := NewVAPID(publicKey, privateKey, "mailto:test@example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newBrowser(t)
+
+	var received []pushMessage
+	var serverURL string
+	pushService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+
+		if err := verifyVAPID(r, serverURL); err != nil {
+			t.Errorf("vapid: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if got := r.Header.Get("Content-Encoding"); got != "aes128gcm" {
+			t.Errorf("Content-Encoding: want aes128gcm got %q", got)
+		}
+		if r.Header.Get("TTL") == "" {
+			t.Error("TTL header missing")
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		plaintext, err := b.decrypt(body)
+		if err != nil {
+			t.Errorf("decrypt: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var msg pushMessage
+		if err := json.Unmarshal(plaintext, &msg); err != nil {
+			t.Errorf("payload: %v", err)
+		}
+		received = append(received, msg)
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer pushService.Close()
+	serverURL = pushService.URL
+
+	subs := &fakeSubscriptions{subs: []store.PushSubscription{
+		b.subscription(pushService.URL + "/gone"),
+		b.subscription(pushService.URL + "/push/1"),
+	}}
+
+	guard, err := netguard.New("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := NewWebPushChannel(subs, vapid, guard)
+
+	err = ch.Send(context.Background(), Notification{UserID: 1, Title: "Reminder: Read", Body: "Don't forget", URL: "http://example.test/today"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if len(received) != 1 || received[0].Title != "Reminder: Read" || received[0].URL != "http://example.test/today" {
+		t.Fatalf("received: want the reminder once, got %+v", received)
+	}
+
+	if len(subs.deleted) != 1 || subs.deleted[0] != pushService.URL+"/gone" {
+		t.Fatalf("deleted: want the gone subscription removed, got %v", subs.deleted)
+	}
+
+	t.Run("fails without subscriptions", func(t *testing.T) {
+		ch := NewWebPushChannel(&fakeSubscriptions{}, vapid, guard)
+		if err := ch.Send(context.Background(), Notification{UserID: 1}); !errors.Is(err, ErrNoSubscriptions) {
+			t.Fatalf("want ErrNoSubscriptions, got %v", err)
+		}
+	})
+}
+
+func TestNewVAPID_RejectsMismatchedKeys(t *testing.T) {
+	publicKey, _, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, privateKey, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewVAPID(publicKey, privateKey, "mailto:test@example.test"); err == nil {
+		t.Fatal("want error for mismatched keys")
+	}
+}
+
+func TestEncryptPayload_TooLarge(t *testing.T) {
+	b := newBrowser(t)
+
+	_, err := encryptPayload(b.key.PublicKey().Bytes(), b.auth, make([]byte, MaxPushPayload+1))
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("want ErrPayloadTooLarge, got %v", err)
+	}
+
+	body, err := encryptPayload(b.key.PublicKey().Bytes(), b.auth, make([]byte, MaxPushPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) > 4096 {
+		t.Fatalf("body: want at most 4096 bytes got %d", len(body))
+	}
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// EmailDeliveryStore pitää kirjaa ajastetuista sähköposteista ja ilmoituksista. Rivi
+// varataan lease-ajaksi ennen lähetystä, joten useampi instanssi ei lähetä samaa viestiä
+// kahdesti, ja merkitään lähetetyksi onnistumisen jälkeen. Jos prosessi kaatuu välissä,
+// vanhentunut varaus otetaan uudelleen.
+type EmailDeliveryStore struct {
+	db *sql.DB
+}
+
+// Claim varaa lähetyksen lease-ajaksi. Palauttaa false, jos viesti on jo lähetetty tai
+// joku muu pitää voimassa olevaa varausta.
+func (s *EmailDeliveryStore) Claim(ctx context.Context, userID int64, kind, period string, lease time.Duration) (bool, error) {
+	query := `
+		INSERT INTO email_deliveries (user_id, kind, period, claimed_until, sent_at)
+		VALUES ($1, $2, $3, NOW() + $4 * interval '1 second', NULL)
+		ON CONFLICT (user_id, kind, period) DO UPDATE
+		SET claimed_until = EXCLUDED.claimed_until
+		WHERE email_deliveries.sent_at IS NULL AND email_deliveries.claimed_until < NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, kind, period, lease.Seconds())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// MarkSent merkitsee varatun lähetyksen tehdyksi, jolloin sitä ei enää varata uudelleen
+func (s *EmailDeliveryStore) MarkSent(ctx context.Context, userID int64, kind, period string) error {
+	query := `
+		UPDATE email_deliveries
+		SET sent_at = NOW(), claimed_until = NULL
+		WHERE user_id = $1 AND kind = $2 AND period = $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, kind, period)
+	return err
+}
+
+// Release vapauttaa varauksen epäonnistuneen lähetyksen jälkeen, jotta se yritetään uudelleen
+func (s *EmailDeliveryStore) Release(ctx context.Context, userID int64, kind, period string) error {
+	query := `DELETE FROM email_deliveries WHERE user_id = $1 AND kind = $2 AND period = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, kind, period)
+	return err
+}
+
+// PendingUsers palauttaa aktiiviset käyttäjät, joilla on tapoja ja joille viestiä ei ole vielä lähetetty
+func (s *EmailDeliveryStore) PendingUsers(ctx context.Context, kind, period string, limit int) ([]User, error) {
+	query := `
+		SELECT u.id, u.public_id, u.username, u.email, u.created_at, u.is_active, u.timezone, u.locale
+		FROM users u
+		WHERE u.is_active
+		  AND EXISTS (SELECT 1 FROM habits h WHERE h.user_id = u.id)
+		  AND NOT EXISTS (
+			SELECT 1 FROM email_deliveries d
+			WHERE d.user_id = u.id AND d.kind = $1 AND d.period = $2
+			  AND (d.sent_at IS NOT NULL OR d.claimed_until > NOW())
+		  )
+		ORDER BY u.id
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, kind, period, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(
+			&user.ID,
+			&user.PublicID,
+			&user.Username,
+			&user.Email,
+			&user.CreatedAt,
+			&user.IsActive,
+			&user.Timezone,
+			&user.Locale,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
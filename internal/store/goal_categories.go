@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrDuplicateGoalCategory = errors.New("a goal category with this name already exists")
+	ErrGoalCategoryInUse     = errors.New("goal category still has goals")
+)
+
+type GoalCategory struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultGoalCategories luodaan jokaiselle uudelle käyttäjälle
+var DefaultGoalCategories = []GoalCategory{
+	{Name: "career", Color: "#6366f1", Position: 0},
+	{Name: "financial", Color: "#16a34a", Position: 1},
+	{Name: "health", Color: "#ef4444", Position: 2},
+	{Name: "learning", Color: "#f59e0b", Position: 3},
+}
+
+type GoalCategoryStore struct {
+	db *sql.DB
+}
+
+// Create lisää kategorian käyttäjän listan loppuun
+func (s *GoalCategoryStore) Create(ctx context.Context, category *GoalCategory) error {
+	query := `
+		INSERT INTO goal_categories (user_id, name, color, position)
+		VALUES ($1, $2, $3, COALESCE((SELECT MAX(position) + 1 FROM goal_categories WHERE user_id = $1), 0))
+		RETURNING id, position, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, category.UserID, category.Name, category.Color).Scan(
+		&category.ID,
+		&category.Position,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "goal_categories_user_id_name_key"`:
+			return ErrDuplicateGoalCategory
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *GoalCategoryStore) GetByID(ctx context.Context, id int64, userID int64) (*GoalCategory, error) {
+	query := `
+		SELECT id, user_id, name, color, position, created_at, updated_at
+		FROM goal_categories
+		WHERE id = $1 AND user_id = $2
+	`
+
+	return s.getOne(ctx, query, id, userID)
+}
+
+func (s *GoalCategoryStore) GetByName(ctx context.Context, name string, userID int64) (*GoalCategory, error) {
+	query := `
+		SELECT id, user_id, name, color, position, created_at, updated_at
+		FROM goal_categories
+		WHERE name = $1 AND user_id = $2
+	`
+
+	return s.getOne(ctx, query, name, userID)
+}
+
+func (s *GoalCategoryStore) getOne(ctx context.Context, query string, args ...any) (*GoalCategory, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	category := &GoalCategory{}
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&category.ID,
+		&category.UserID,
+		&category.Name,
+		&category.Color,
+		&category.Position,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return category, nil
+}
+
+func (s *GoalCategoryStore) GetByUser(ctx context.Context, userID int64) ([]GoalCategory, error) {
+	query := `
+		SELECT id, user_id, name, color, position, created_at, updated_at
+		FROM goal_categories
+		WHERE user_id = $1
+		ORDER BY position, id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []GoalCategory{}
+	for rows.Next() {
+		var category GoalCategory
+		if err := rows.Scan(
+			&category.ID,
+			&category.UserID,
+			&category.Name,
+			&category.Color,
+			&category.Position,
+			&category.CreatedAt,
+			&category.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (s *GoalCategoryStore) Update(ctx context.Context, category *GoalCategory) error {
+	query := `
+		UPDATE goal_categories
+		SET name = $1, color = $2, position = $3, updated_at = NOW()
+		WHERE id = $4 AND user_id = $5
+		RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		category.Name,
+		category.Color,
+		category.Position,
+		category.ID,
+		category.UserID,
+	).Scan(&category.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		case err.Error() == `pq: duplicate key value violates unique constraint "goal_categories_user_id_name_key"`:
+			return ErrDuplicateGoalCategory
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete poistaa kategorian. Kategoriaa, jolla on tavoitteita, ei voi poistaa.
+func (s *GoalCategoryStore) Delete(ctx context.Context, id int64, userID int64) error {
+	query := `DELETE FROM goal_categories WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: update or delete on table "goal_categories" violates foreign key constraint "goals_category_id_fkey" on table "goals"`:
+			return ErrGoalCategoryInUse
+		default:
+			return err
+		}
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// seedGoalCategories luo oletuskategoriat uudelle käyttäjälle samassa transaktiossa
+func seedGoalCategories(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		INSERT INTO goal_categories (user_id, name, color, position)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, name) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for _, c := range DefaultGoalCategories {
+		if _, err := tx.ExecContext(ctx, query, userID, c.Name, c.Color, c.Position); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
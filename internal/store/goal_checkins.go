@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// GoalCheckin on tavoitteen mitattu arvo tiettynä päivänä (esim. säästetty summa)
+type GoalCheckin struct {
+	ID        int64     `json:"id"`
+	GoalID    int64     `json:"goal_id"`
+	Value     float64   `json:"value"`
+	Note      string    `json:"note"`
+	CheckedOn time.Time `json:"checked_on"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GoalCheckinStore struct {
+	db *sql.DB
+}
+
+func (s *GoalCheckinStore) Create(ctx context.Context, c *GoalCheckin) error {
+	query := `
+		INSERT INTO goal_checkins (goal_id, value, note, checked_on)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, checked_on, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, c.GoalID, c.Value, c.Note, c.CheckedOn.Format("2006-01-02")).Scan(
+		&c.ID,
+		&c.CheckedOn,
+		&c.CreatedAt,
+	)
+}
+
+// GetByGoal palauttaa check-in-historian uusimmasta vanhimpaan
+func (s *GoalCheckinStore) GetByGoal(ctx context.Context, goalID int64) ([]GoalCheckin, error) {
+	query := `
+		SELECT id, goal_id, value, note, checked_on, created_at
+		FROM goal_checkins
+		WHERE goal_id = $1
+		ORDER BY checked_on DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, goalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkins := []GoalCheckin{}
+	for rows.Next() {
+		var c GoalCheckin
+		if err := rows.Scan(
+			&c.ID,
+			&c.GoalID,
+			&c.Value,
+			&c.Note,
+			&c.CheckedOn,
+			&c.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		checkins = append(checkins, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return checkins, nil
+}
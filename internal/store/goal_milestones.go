@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// GoalMilestone on tavoitteen välietappi, jolla on oma valmistumistila
+type GoalMilestone struct {
+	ID          int64      `json:"id"`
+	GoalID      int64      `json:"goal_id"`
+	Title       string     `json:"title"`
+	DueDate     *time.Time `json:"due_date"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type GoalMilestoneStore struct {
+	db *sql.DB
+}
+
+func (s *GoalMilestoneStore) Create(ctx context.Context, m *GoalMilestone) error {
+	query := `
+		INSERT INTO goal_milestones (goal_id, title, due_date, completed_at)
+		VALUES ($1, $2, $3, CASE WHEN $4 THEN NOW() END)
+		RETURNING id, completed_at, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, m.GoalID, m.Title, m.DueDate, m.Completed).Scan(
+		&m.ID,
+		&m.CompletedAt,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+	)
+}
+
+func (s *GoalMilestoneStore) GetByID(ctx context.Context, id, goalID int64) (*GoalMilestone, error) {
+	query := `
+		SELECT id, goal_id, title, due_date, completed_at, created_at, updated_at
+		FROM goal_milestones
+		WHERE id = $1 AND goal_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	m := &GoalMilestone{}
+	err := s.db.QueryRowContext(ctx, query, id, goalID).Scan(
+		&m.ID,
+		&m.GoalID,
+		&m.Title,
+		&m.DueDate,
+		&m.CompletedAt,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	m.Completed = m.CompletedAt != nil
+
+	return m, nil
+}
+
+// GetByGoal palauttaa välietapit eräpäivän mukaan, päivättömät viimeisenä
+func (s *GoalMilestoneStore) GetByGoal(ctx context.Context, goalID int64) ([]GoalMilestone, error) {
+	query := `
+		SELECT id, goal_id, title, due_date, completed_at, created_at, updated_at
+		FROM goal_milestones
+		WHERE goal_id = $1
+		ORDER BY due_date NULLS LAST, id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, goalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	milestones := []GoalMilestone{}
+	for rows.Next() {
+		var m GoalMilestone
+		if err := rows.Scan(
+			&m.ID,
+			&m.GoalID,
+			&m.Title,
+			&m.DueDate,
+			&m.CompletedAt,
+			&m.CreatedAt,
+			&m.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		m.Completed = m.CompletedAt != nil
+		milestones = append(milestones, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return milestones, nil
+}
+
+// Update tallentaa välietapin. Valmistumisaika säilyy, jos etappi oli jo valmis.
+func (s *GoalMilestoneStore) Update(ctx context.Context, m *GoalMilestone) error {
+	query := `
+		UPDATE goal_milestones
+		SET title = $1,
+			due_date = $2,
+			completed_at = CASE WHEN $3 THEN COALESCE(completed_at, NOW()) END,
+			updated_at = NOW()
+		WHERE id = $4 AND goal_id = $5
+		RETURNING completed_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, m.Title, m.DueDate, m.Completed, m.ID, m.GoalID).Scan(
+		&m.CompletedAt,
+		&m.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *GoalMilestoneStore) Delete(ctx context.Context, id, goalID int64) error {
+	query := `DELETE FROM goal_milestones WHERE id = $1 AND goal_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, goalID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// GoalProgress on tavoitteen laskettu edistyminen linkitettyjen tapojen perusteella
+// tavoitteen vuoden alusta päivään asOf (tai vuoden loppuun).
+type GoalProgress struct {
+	HabitIDs         []int64 `json:"habit_ids"`
+	PossibleDays     int     `json:"possible_days"`
+	CompletedDays    int     `json:"completed_days"`
+	CompletionRate   float64 `json:"completion_rate"`
+	Quantity         int     `json:"quantity"`
+	TargetQuantity   *int    `json:"target_quantity"`
+	QuantityProgress float64 `json:"quantity_progress"`
+	// ProjectedQuantity arvioi vuoden lopun määrän nykyisellä tahdilla
+	ProjectedQuantity float64 `json:"projected_quantity"`
+	// OnTrack on nil, kun tavoitteella ei ole määrätavoitetta tai vuosi ei ole alkanut
+	OnTrack *bool `json:"on_track"`
+}
+
+// GetProgress laskee annettujen tavoitteiden edistymisen yhdellä aggregaattikyselyllä.
+// Mahdolliset päivät noudattavat tapojen aikataulua, luontipäivää ja ohitettuja päiviä.
+func (s *GoalStore) GetProgress(ctx context.Context, userID int64, goalIDs []int64, asOf time.Time) (map[int64]*GoalProgress, error) {
+	progress := map[int64]*GoalProgress{}
+	if len(goalIDs) == 0 {
+		return progress, nil
+	}
+
+	query := `
+		WITH g AS (
+			SELECT id, year, target_quantity,
+				make_date(year, 1, 1) AS start_date,
+				LEAST(make_date(year, 12, 31), $3::date) AS stop_date
+			FROM goals
+			WHERE user_id = $1 AND id = ANY($2)
+		), linked AS (
+			SELECT g.id AS goal_id, h.id AS habit_id, h.target, h.schedule_days, h.created_at::date AS created
+			FROM g
+			JOIN habits h ON h.goal_id = g.id AND h.user_id = $1
+		), days AS (
+			SELECT l.goal_id, COUNT(*) AS possible, COUNT(c.id) AS completed
+			FROM linked l
+			JOIN g ON g.id = l.goal_id
+			CROSS JOIN LATERAL generate_series(GREATEST(g.start_date, l.created), g.stop_date, interval '1 day') AS d
+			LEFT JOIN habit_completions c
+				ON c.habit_id = l.habit_id AND c.completed_date = d::date AND c.amount >= l.target
+			WHERE (cardinality(l.schedule_days) = 0 OR EXTRACT(ISODOW FROM d::date)::smallint = ANY(l.schedule_days))
+			  AND NOT EXISTS (SELECT 1 FROM habit_skips s WHERE s.habit_id = l.habit_id AND s.skip_date = d::date)
+			GROUP BY l.goal_id
+		), qty AS (
+			SELECT l.goal_id, SUM(c.amount) AS quantity
+			FROM linked l
+			JOIN g ON g.id = l.goal_id
+			JOIN habit_completions c
+				ON c.habit_id = l.habit_id AND c.completed_date BETWEEN g.start_date AND g.stop_date
+			GROUP BY l.goal_id
+		)
+		SELECT g.id, g.year, g.target_quantity,
+			ARRAY(SELECT habit_id FROM linked WHERE goal_id = g.id ORDER BY habit_id),
+			COALESCE(days.possible, 0),
+			COALESCE(days.completed, 0),
+			COALESCE(qty.quantity, 0)
+		FROM g
+		LEFT JOIN days ON days.goal_id = g.id
+		LEFT JOIN qty ON qty.goal_id = g.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(goalIDs), asOf.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var goalID int64
+		var year int
+		p := &GoalProgress{}
+		if err := rows.Scan(
+			&goalID,
+			&year,
+			&p.TargetQuantity,
+			pq.Array(&p.HabitIDs),
+			&p.PossibleDays,
+			&p.CompletedDays,
+			&p.Quantity,
+		); err != nil {
+			return nil, err
+		}
+
+		if p.HabitIDs == nil {
+			p.HabitIDs = []int64{}
+		}
+		p.CompletionRate = rate(p.CompletedDays, p.PossibleDays)
+		p.project(year, asOf)
+
+		progress[goalID] = p
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
+
+// project laskee vuoden lopun ennusteen lineaarisesti kuluneiden päivien perusteella
+func (p *GoalProgress) project(year int, asOf time.Time) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	y, m, d := asOf.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	if today.After(end) {
+		today = end
+	}
+
+	totalDays := end.Sub(start).Hours()/24 + 1
+	elapsedDays := today.Sub(start).Hours()/24 + 1
+	if elapsedDays <= 0 {
+		return
+	}
+
+	p.ProjectedQuantity = float64(p.Quantity) / elapsedDays * totalDays
+
+	if p.TargetQuantity == nil || *p.TargetQuantity <= 0 {
+		return
+	}
+
+	p.QuantityProgress = min(float64(p.Quantity)/float64(*p.TargetQuantity), 1)
+
+	onTrack := p.ProjectedQuantity >= float64(*p.TargetQuantity)
+	p.OnTrack = &onTrack
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var ErrGoalAlreadyRolledOver = errors.New("goal has already been rolled over")
+
+// GoalReview on yhden tavoitteen vuosikatselmoinnin tulos
+type GoalReview struct {
+	GoalID     int64
+	Outcome    string
+	Reflection string
+}
+
+// Rollover kertoo mistä tavoitteesta uusi tavoite kopioitiin
+type Rollover struct {
+	FromGoalID int64 `json:"from_goal_id"`
+	GoalID     int64 `json:"goal_id"`
+}
+
+// SaveReview tallentaa vuoden tavoitteiden tulokset ja pohdinnat yhdessä transaktiossa.
+// Tavoite merkitään tehdyksi vain, jos tulos on achieved.
+func (s *GoalStore) SaveReview(ctx context.Context, userID int64, year int, reviews []GoalReview) error {
+	query := `
+		UPDATE goals
+		SET outcome = $1, reflection = $2, completed = ($1 = 'achieved'), reviewed_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND user_id = $4 AND year = $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		for _, review := range reviews {
+			res, err := tx.ExecContext(ctx, query, review.Outcome, review.Reflection, review.GoalID, userID, year)
+			if err != nil {
+				return err
+			}
+
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			if rows == 0 {
+				return ErrNotFound
+			}
+		}
+
+		return nil
+	})
+}
+
+// Rollover kopioi valitut keskeneräiset tavoitteet seuraavalle vuodelle ja siirtää niihin
+// linkitetyt tavat yhdessä transaktiossa. Välietappeja ja check-inejä ei kopioida.
+func (s *GoalStore) Rollover(ctx context.Context, userID int64, year int, goalIDs []int64) ([]Rollover, error) {
+	insertQuery := `
+		INSERT INTO goals (user_id, year, category_id, description, target_quantity,
+			start_value, target_value, unit, deadline, rolled_over_from)
+		SELECT user_id, year + 1, category_id, description, target_quantity,
+			start_value, target_value, unit, (deadline + interval '1 year')::date, id
+		FROM goals
+		WHERE user_id = $1 AND year = $2 AND id = ANY($3) AND completed IS NOT TRUE
+		RETURNING rolled_over_from, id
+	`
+
+	relinkQuery := `
+		UPDATE habits h
+		SET goal_id = g.id, version = h.version + 1
+		FROM goals g
+		WHERE g.rolled_over_from = h.goal_id
+		  AND g.id = ANY($2)
+		  AND h.user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rollovers := []Rollover{}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, insertQuery, userID, year, pq.Array(goalIDs))
+		if err != nil {
+			return rolloverError(err)
+		}
+		defer rows.Close()
+
+		newIDs := []int64{}
+		for rows.Next() {
+			var r Rollover
+			if err := rows.Scan(&r.FromGoalID, &r.GoalID); err != nil {
+				return err
+			}
+			rollovers = append(rollovers, r)
+			newIDs = append(newIDs, r.GoalID)
+		}
+
+		if err := rows.Err(); err != nil {
+			return rolloverError(err)
+		}
+
+		// Some goal doesn't exist, belongs to another year or is already completed
+		if len(rollovers) != len(goalIDs) {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, relinkQuery, userID, pq.Array(newIDs)); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rollovers, nil
+}
+
+func rolloverError(err error) error {
+	switch {
+	case err.Error() == `pq: duplicate key value violates unique constraint "goals_rolled_over_from_key"`:
+		return ErrGoalAlreadyRolledOver
+	default:
+		return err
+	}
+}
@@ -7,46 +7,73 @@ import (
 )
 
 type Goal struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"-"`
-	Year        int       `json:"year"`
-	Category    string    `json:"category"`
-	Description string    `json:"description"`
-	Completed   *bool     `json:"completed"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             int64           `json:"id"`
+	UserID         int64           `json:"-"`
+	Year           int             `json:"year"`
+	CategoryID     int64           `json:"category_id"`
+	Category       string          `json:"category"` // category name, read-only
+	Description    string          `json:"description"`
+	Completed      *bool           `json:"completed"`
+	TargetQuantity *int            `json:"target_quantity"`
+	StartValue     *float64        `json:"start_value"`
+	TargetValue    *float64        `json:"target_value"`
+	CurrentValue   *float64        `json:"current_value"` // latest check-in, read-only
+	Unit           string          `json:"unit"`
+	Deadline       *time.Time      `json:"deadline"`
+	Outcome        *string         `json:"outcome"`
+	Reflection     string          `json:"reflection"`
+	ReviewedAt     *time.Time      `json:"reviewed_at"`
+	RolledOverFrom *int64          `json:"rolled_over_from"`
+	Progress       *GoalProgress   `json:"progress,omitempty"`
+	Milestones     []GoalMilestone `json:"milestones,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
 }
 
+// goalCurrentValue hakee tavoitteen viimeisimmän check-in-arvon
+const goalCurrentValue = `(SELECT value FROM goal_checkins WHERE goal_id = g.id ORDER BY checked_on DESC, id DESC LIMIT 1)`
+
 type GoalStore struct {
 	db *sql.DB
 }
 
-func (s *GoalStore) Create(ctx context.Context, goal *Goal) error {
+func (s *GoalStore) Create(ctx context.Context, goal *Goal, event *Event) error {
 	query := `
-		INSERT INTO goals (user_id, year, category, description, completed)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO goals (user_id, year, category_id, description, completed, target_quantity,
+			start_value, target_value, unit, deadline)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, created_at, updated_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	return s.db.QueryRowContext(
-		ctx,
-		query,
-		goal.UserID,
-		goal.Year,
-		goal.Category,
-		goal.Description,
-		goal.Completed,
-	).Scan(&goal.ID, &goal.CreatedAt, &goal.UpdatedAt)
+	return withEvent(s.db, ctx, goal.UserID, event, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(
+			ctx,
+			query,
+			goal.UserID,
+			goal.Year,
+			goal.CategoryID,
+			goal.Description,
+			goal.Completed,
+			goal.TargetQuantity,
+			goal.StartValue,
+			goal.TargetValue,
+			goal.Unit,
+			goal.Deadline,
+		).Scan(&goal.ID, &goal.CreatedAt, &goal.UpdatedAt)
+	})
 }
 
 func (s *GoalStore) GetByID(ctx context.Context, id int64) (*Goal, error) {
 	query := `
-		SELECT id, user_id, year, category, description, completed, created_at, updated_at
-		FROM goals
-		WHERE id = $1
+		SELECT g.id, g.user_id, g.year, g.category_id, c.name, g.description, g.completed, g.target_quantity,
+			g.start_value, g.target_value, ` + goalCurrentValue + `, g.unit, g.deadline,
+			g.outcome, g.reflection, g.reviewed_at, g.rolled_over_from, g.created_at, g.updated_at
+		FROM goals g
+		JOIN goal_categories c ON c.id = g.category_id
+		WHERE g.id = $1
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -57,9 +84,20 @@ func (s *GoalStore) GetByID(ctx context.Context, id int64) (*Goal, error) {
 		&goal.ID,
 		&goal.UserID,
 		&goal.Year,
+		&goal.CategoryID,
 		&goal.Category,
 		&goal.Description,
 		&goal.Completed,
+		&goal.TargetQuantity,
+		&goal.StartValue,
+		&goal.TargetValue,
+		&goal.CurrentValue,
+		&goal.Unit,
+		&goal.Deadline,
+		&goal.Outcome,
+		&goal.Reflection,
+		&goal.ReviewedAt,
+		&goal.RolledOverFrom,
 		&goal.CreatedAt,
 		&goal.UpdatedAt,
 	)
@@ -78,10 +116,13 @@ func (s *GoalStore) GetByID(ctx context.Context, id int64) (*Goal, error) {
 
 func (s *GoalStore) GetByUserAndYear(ctx context.Context, userID int64, year int) ([]Goal, error) {
 	query := `
-		SELECT id, user_id, year, category, description, completed, created_at, updated_at
-		FROM goals
-		WHERE user_id = $1 AND year = $2
-		ORDER BY category
+		SELECT g.id, g.user_id, g.year, g.category_id, c.name, g.description, g.completed, g.target_quantity,
+			g.start_value, g.target_value, ` + goalCurrentValue + `, g.unit, g.deadline,
+			g.outcome, g.reflection, g.reviewed_at, g.rolled_over_from, g.created_at, g.updated_at
+		FROM goals g
+		JOIN goal_categories c ON c.id = g.category_id
+		WHERE g.user_id = $1 AND g.year = $2
+		ORDER BY c.position, c.id, g.id
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -100,9 +141,20 @@ func (s *GoalStore) GetByUserAndYear(ctx context.Context, userID int64, year int
 			&goal.ID,
 			&goal.UserID,
 			&goal.Year,
+			&goal.CategoryID,
 			&goal.Category,
 			&goal.Description,
 			&goal.Completed,
+			&goal.TargetQuantity,
+			&goal.StartValue,
+			&goal.TargetValue,
+			&goal.CurrentValue,
+			&goal.Unit,
+			&goal.Deadline,
+			&goal.Outcome,
+			&goal.Reflection,
+			&goal.ReviewedAt,
+			&goal.RolledOverFrom,
 			&goal.CreatedAt,
 			&goal.UpdatedAt,
 		)
@@ -119,57 +171,69 @@ func (s *GoalStore) GetByUserAndYear(ctx context.Context, userID int64, year int
 	return goals, nil
 }
 
-func (s *GoalStore) Update(ctx context.Context, goal *Goal) error {
+func (s *GoalStore) Update(ctx context.Context, goal *Goal, event *Event) error {
 	query := `
 		UPDATE goals
-		SET description = $1, completed = $2, updated_at = NOW()
-		WHERE id = $3 AND user_id = $4
+		SET description = $1, completed = $2, target_quantity = $3,
+			start_value = $4, target_value = $5, unit = $6, deadline = $7,
+			category_id = $8, updated_at = NOW()
+		WHERE id = $9 AND user_id = $10
 		RETURNING updated_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		goal.Description,
-		goal.Completed,
-		goal.ID,
-		goal.UserID,
-	).Scan(&goal.UpdatedAt)
+	return withEvent(s.db, ctx, goal.UserID, event, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			goal.Description,
+			goal.Completed,
+			goal.TargetQuantity,
+			goal.StartValue,
+			goal.TargetValue,
+			goal.Unit,
+			goal.Deadline,
+			goal.CategoryID,
+			goal.ID,
+			goal.UserID,
+		).Scan(&goal.UpdatedAt)
 
-	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			return ErrNotFound
-		default:
-			return err
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
-func (s *GoalStore) Delete(ctx context.Context, id int64, userID int64) error {
+func (s *GoalStore) Delete(ctx context.Context, id int64, userID int64, event *Event) error {
 	query := `DELETE FROM goals WHERE id = $1 AND user_id = $2`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	result, err := s.db.ExecContext(ctx, query, id, userID)
-	if err != nil {
-		return err
-	}
+	return withEvent(s.db, ctx, userID, event, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, id, userID)
+		if err != nil {
+			return err
+		}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
 
-	if rows == 0 {
-		return ErrNotFound
-	}
+		if rows == 0 {
+			return ErrNotFound
+		}
 
-	return nil
+		return nil
+	})
 }
@@ -3,7 +3,10 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type HabitCompletion struct {
@@ -11,6 +14,7 @@ type HabitCompletion struct {
 	HabitID       int64     `json:"habit_id"`
 	UserID        int64     `json:"-"`
 	CompletedDate time.Time `json:"completed_date"`
+	Amount        int       `json:"amount"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
@@ -18,83 +22,99 @@ type HabitCompletionStore struct {
 	db *sql.DB
 }
 
-// MarkComplete merkitsee habitin tehdyksi tietylle päivälle
-func (s *HabitCompletionStore) MarkComplete(ctx context.Context, habitID, userID int64, date time.Time) (*HabitCompletion, error) {
+// MarkComplete merkitsee habitin tehdyksi tietylle päivälle. completion-rakenteeseen annetaan
+// tapa, käyttäjä, päivä ja määrä; loput kentät täytetään tallennuksessa.
+// Jos päivällä on jo merkintä, sen määrä päivitetään. Jos tapa ei kuulu käyttäjälle, palautetaan ErrNotFound.
+func (s *HabitCompletionStore) MarkComplete(ctx context.Context, completion *HabitCompletion, event *Event) error {
 	query := `
-		INSERT INTO habit_completions (habit_id, user_id, completed_date)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (habit_id, completed_date) DO NOTHING
-		RETURNING id, habit_id, user_id, completed_date, created_at
+		INSERT INTO habit_completions (habit_id, user_id, completed_date, amount)
+		SELECT h.id, h.user_id, $3, $4
+		FROM habits h
+		WHERE h.id = $1 AND h.user_id = $2
+		ON CONFLICT (habit_id, completed_date) DO UPDATE SET amount = EXCLUDED.amount
+		RETURNING id, habit_id, user_id, completed_date, amount, created_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	completion := &HabitCompletion{}
-	err := s.db.QueryRowContext(ctx, query, habitID, userID, date.Format("2006-01-02")).Scan(
-		&completion.ID,
-		&completion.HabitID,
-		&completion.UserID,
-		&completion.CompletedDate,
-		&completion.CreatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		// Already exists, fetch it
-		return s.GetByHabitAndDate(ctx, habitID, date)
-	}
+	return withEvent(s.db, ctx, completion.UserID, event, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			completion.HabitID,
+			completion.UserID,
+			completion.CompletedDate.Format("2006-01-02"),
+			completion.Amount,
+		).Scan(
+			&completion.ID,
+			&completion.HabitID,
+			&completion.UserID,
+			&completion.CompletedDate,
+			&completion.Amount,
+			&completion.CreatedAt,
+		)
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
 
-	return completion, nil
+		return nil
+	})
 }
 
-// UnmarkComplete poistaa habitin merkinnän tietyltä päivältä
-func (s *HabitCompletionStore) UnmarkComplete(ctx context.Context, habitID int64, date time.Time) error {
+// UnmarkComplete poistaa käyttäjän habitin merkinnän tietyltä päivältä
+func (s *HabitCompletionStore) UnmarkComplete(ctx context.Context, habitID, userID int64, date time.Time, event *Event) error {
 	query := `
 		DELETE FROM habit_completions 
-		WHERE habit_id = $1 AND completed_date = $2
+		WHERE habit_id = $1 AND user_id = $2 AND completed_date = $3
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	result, err := s.db.ExecContext(ctx, query, habitID, date.Format("2006-01-02"))
-	if err != nil {
-		return err
-	}
+	return withEvent(s.db, ctx, userID, event, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, habitID, userID, date.Format("2006-01-02"))
+		if err != nil {
+			return err
+		}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
 
-	if rows == 0 {
-		return ErrNotFound
-	}
+		if rows == 0 {
+			return ErrNotFound
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // GetByHabitAndDate hakee yksittäisen merkinnän
-func (s *HabitCompletionStore) GetByHabitAndDate(ctx context.Context, habitID int64, date time.Time) (*HabitCompletion, error) {
+func (s *HabitCompletionStore) GetByHabitAndDate(ctx context.Context, habitID, userID int64, date time.Time) (*HabitCompletion, error) {
 	query := `
-		SELECT id, habit_id, user_id, completed_date, created_at
+		SELECT id, habit_id, user_id, completed_date, amount, created_at
 		FROM habit_completions
-		WHERE habit_id = $1 AND completed_date = $2
+		WHERE habit_id = $1 AND user_id = $2 AND completed_date = $3
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	completion := &HabitCompletion{}
-	err := s.db.QueryRowContext(ctx, query, habitID, date.Format("2006-01-02")).Scan(
+	err := s.db.QueryRowContext(ctx, query, habitID, userID, date.Format("2006-01-02")).Scan(
 		&completion.ID,
 		&completion.HabitID,
 		&completion.UserID,
 		&completion.CompletedDate,
+		&completion.Amount,
 		&completion.CreatedAt,
 	)
 
@@ -108,21 +128,22 @@ func (s *HabitCompletionStore) GetByHabitAndDate(ctx context.Context, habitID in
 	return completion, nil
 }
 
-// GetCompletionsByHabit hakee habitin kaikki merkinnät aikaväliltä
-func (s *HabitCompletionStore) GetCompletionsByHabit(ctx context.Context, habitID int64, startDate, endDate time.Time) ([]HabitCompletion, error) {
+// GetCompletionsByHabit hakee käyttäjän habitin kaikki merkinnät aikaväliltä
+func (s *HabitCompletionStore) GetCompletionsByHabit(ctx context.Context, habitID, userID int64, startDate, endDate time.Time) ([]HabitCompletion, error) {
 	query := `
-		SELECT id, habit_id, user_id, completed_date, created_at
+		SELECT id, habit_id, user_id, completed_date, amount, created_at
 		FROM habit_completions
 		WHERE habit_id = $1 
-		  AND completed_date >= $2 
-		  AND completed_date <= $3
+		  AND user_id = $2
+		  AND completed_date >= $3 
+		  AND completed_date <= $4
 		ORDER BY completed_date DESC
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, habitID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	rows, err := s.db.QueryContext(ctx, query, habitID, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
 	if err != nil {
 		return nil, err
 	}
@@ -136,6 +157,7 @@ func (s *HabitCompletionStore) GetCompletionsByHabit(ctx context.Context, habitI
 			&completion.HabitID,
 			&completion.UserID,
 			&completion.CompletedDate,
+			&completion.Amount,
 			&completion.CreatedAt,
 		)
 		if err != nil {
@@ -151,21 +173,26 @@ func (s *HabitCompletionStore) GetCompletionsByHabit(ctx context.Context, habitI
 	return completions, nil
 }
 
-// GetCompletionsByUser hakee käyttäjän kaikki merkinnät aikaväliltä
-func (s *HabitCompletionStore) GetCompletionsByUser(ctx context.Context, userID int64, startDate, endDate time.Time) ([]HabitCompletion, error) {
+// GetCompletionsByUser hakee käyttäjän kaikki merkinnät aikaväliltä.
+// Jos tagIDs ei ole tyhjä, palautetaan vain niiden tapojen merkinnät, joilla on jokin annetuista tageista.
+func (s *HabitCompletionStore) GetCompletionsByUser(ctx context.Context, userID int64, startDate, endDate time.Time, tagIDs []int64) ([]HabitCompletion, error) {
 	query := `
-		SELECT id, habit_id, user_id, completed_date, created_at
-		FROM habit_completions
+		SELECT id, habit_id, user_id, completed_date, amount, created_at
+		FROM habit_completions c
 		WHERE user_id = $1 
 		  AND completed_date >= $2 
 		  AND completed_date <= $3
+		  AND (
+			COALESCE(cardinality($4::bigint[]), 0) = 0
+			OR EXISTS (SELECT 1 FROM habit_tags ht WHERE ht.habit_id = c.habit_id AND ht.tag_id = ANY($4))
+		  )
 		ORDER BY completed_date DESC
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	rows, err := s.db.QueryContext(ctx, query, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), pq.Array(tagIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -179,6 +206,7 @@ func (s *HabitCompletionStore) GetCompletionsByUser(ctx context.Context, userID
 			&completion.HabitID,
 			&completion.UserID,
 			&completion.CompletedDate,
+			&completion.Amount,
 			&completion.CreatedAt,
 		)
 		if err != nil {
@@ -193,3 +221,107 @@ func (s *HabitCompletionStore) GetCompletionsByUser(ctx context.Context, userID
 
 	return completions, nil
 }
+
+// BatchMark on yksi erämerkinnän rivi. Completion-kenttään annetaan tapa, päivä ja
+// määrä; tallennus täydentää loput ja kertoo Created-kentässä, oliko merkintä uusi.
+// Event lisätään webhook-jonoon rivin tallennuksen jälkeen.
+type BatchMark struct {
+	Completion HabitCompletion
+	Created    bool
+	Event      *Event
+}
+
+// MarkCompleteBatch tallentaa kaikki merkinnät yhdessä transaktiossa.
+// Jos jokin tapa ei kuulu käyttäjälle, mitään ei tallenneta ja palautetaan ErrNotFound.
+func (s *HabitCompletionStore) MarkCompleteBatch(ctx context.Context, userID int64, marks []BatchMark) error {
+	query := `
+		INSERT INTO habit_completions (habit_id, user_id, completed_date, amount)
+		SELECT h.id, h.user_id, $3, $4
+		FROM habits h
+		WHERE h.id = $1 AND h.user_id = $2
+		ON CONFLICT (habit_id, completed_date) DO UPDATE SET amount = EXCLUDED.amount
+		RETURNING id, habit_id, user_id, completed_date, amount, created_at, (xmax = 0)
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		for i := range marks {
+			m := &marks[i]
+			err := tx.QueryRowContext(ctx, query, m.Completion.HabitID, userID, m.Completion.CompletedDate.Format("2006-01-02"), m.Completion.Amount).Scan(
+				&m.Completion.ID,
+				&m.Completion.HabitID,
+				&m.Completion.UserID,
+				&m.Completion.CompletedDate,
+				&m.Completion.Amount,
+				&m.Completion.CreatedAt,
+				&m.Created,
+			)
+			if err != nil {
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					return ErrNotFound
+				default:
+					return err
+				}
+			}
+
+			if err := enqueueEvent(ctx, tx, userID, m.Event); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CompletionEntry on yksi erämerkinnän poiston rivi. Event lisätään webhook-jonoon
+// vain, jos poistettava merkintä löytyi.
+type CompletionEntry struct {
+	HabitID int64
+	Date    time.Time
+	Event   *Event
+}
+
+// UnmarkCompleteBatch poistaa merkinnät yhdessä transaktiossa ja palauttaa
+// jokaiselle riville tiedon, löytyikö poistettavaa merkintää.
+func (s *HabitCompletionStore) UnmarkCompleteBatch(ctx context.Context, userID int64, entries []CompletionEntry) ([]bool, error) {
+	query := `
+		DELETE FROM habit_completions
+		WHERE habit_id = $1 AND user_id = $2 AND completed_date = $3
+	`
+
+	deleted := make([]bool, 0, len(entries))
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		for _, e := range entries {
+			res, err := tx.ExecContext(ctx, query, e.HabitID, userID, e.Date.Format("2006-01-02"))
+			if err != nil {
+				return err
+			}
+
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, rows > 0)
+
+			if rows > 0 {
+				if err := enqueueEvent(ctx, tx, userID, e.Event); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type HabitGroup struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type HabitGroupStore struct {
+	db *sql.DB
+}
+
+// Create lisää ryhmän käyttäjän listan loppuun
+func (s *HabitGroupStore) Create(ctx context.Context, group *HabitGroup) error {
+	query := `
+		INSERT INTO habit_groups (user_id, name, position)
+		VALUES ($1, $2, COALESCE((SELECT MAX(position) + 1 FROM habit_groups WHERE user_id = $1), 0))
+		RETURNING id, position, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, group.UserID, group.Name).Scan(
+		&group.ID,
+		&group.Position,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+	)
+}
+
+func (s *HabitGroupStore) GetByID(ctx context.Context, id int64, userID int64) (*HabitGroup, error) {
+	query := `
+		SELECT id, user_id, name, position, created_at, updated_at
+		FROM habit_groups
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	group := &HabitGroup{}
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&group.ID,
+		&group.UserID,
+		&group.Name,
+		&group.Position,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return group, nil
+}
+
+func (s *HabitGroupStore) GetByUser(ctx context.Context, userID int64) ([]HabitGroup, error) {
+	query := `
+		SELECT id, user_id, name, position, created_at, updated_at
+		FROM habit_groups
+		WHERE user_id = $1
+		ORDER BY position, id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []HabitGroup{}
+	for rows.Next() {
+		var group HabitGroup
+		if err := rows.Scan(
+			&group.ID,
+			&group.UserID,
+			&group.Name,
+			&group.Position,
+			&group.CreatedAt,
+			&group.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func (s *HabitGroupStore) Update(ctx context.Context, group *HabitGroup) error {
+	query := `
+		UPDATE habit_groups
+		SET name = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+		RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, group.Name, group.ID, group.UserID).Scan(&group.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete poistaa ryhmän, ryhmän tavat jäävät ryhmittelemättömiksi
+func (s *HabitGroupStore) Delete(ctx context.Context, id int64, userID int64) error {
+	query := `DELETE FROM habit_groups WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Reorder asettaa ryhmien järjestyksen annetun listan mukaiseksi yhdessä transaktiossa
+func (s *HabitGroupStore) Reorder(ctx context.Context, userID int64, groupIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			UPDATE habit_groups g
+			SET position = o.position - 1, updated_at = NOW()
+			FROM unnest($1::bigint[]) WITH ORDINALITY AS o(id, position)
+			WHERE g.id = o.id AND g.user_id = $2
+		`
+
+		res, err := tx.ExecContext(ctx, query, pq.Array(groupIDs), userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows != int64(len(groupIDs)) {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// HabitSkip merkitsee päivän, jona tapaa ei tarvitse tehdä (esim. sairaus tai loma).
+// Ohitettuja päiviä ei lasketa mahdollisiin päiviin eivätkä ne katkaise putkea.
+type HabitSkip struct {
+	HabitID   int64     `json:"habit_id"`
+	UserID    int64     `json:"-"`
+	SkipDate  time.Time `json:"skip_date"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type HabitSkipStore struct {
+	db *sql.DB
+}
+
+func (s *HabitSkipStore) Create(ctx context.Context, habitID, userID int64, date time.Time) (*HabitSkip, error) {
+	query := `
+		INSERT INTO habit_skips (habit_id, user_id, skip_date)
+		SELECT h.id, h.user_id, $3
+		FROM habits h
+		WHERE h.id = $1 AND h.user_id = $2
+		ON CONFLICT (habit_id, skip_date) DO UPDATE SET skip_date = EXCLUDED.skip_date
+		RETURNING habit_id, user_id, skip_date, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	skip := &HabitSkip{}
+	err := s.db.QueryRowContext(ctx, query, habitID, userID, date.Format("2006-01-02")).Scan(
+		&skip.HabitID,
+		&skip.UserID,
+		&skip.SkipDate,
+		&skip.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return skip, nil
+}
+
+func (s *HabitSkipStore) Delete(ctx context.Context, habitID, userID int64, date time.Time) error {
+	query := `DELETE FROM habit_skips WHERE habit_id = $1 AND user_id = $2 AND skip_date = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, habitID, userID, date.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
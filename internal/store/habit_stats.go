@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+type PeriodCount struct {
+	Period      time.Time `json:"period"`
+	Completions int       `json:"completions"`
+}
+
+type WeekdayStats struct {
+	Weekday        int     `json:"weekday"` // ISO, 1 = Monday
+	PossibleDays   int     `json:"possible_days"`
+	CompletedDays  int     `json:"completed_days"`
+	CompletionRate float64 `json:"completion_rate"`
+}
+
+// HabitStats on tavan tilastot aikaväliltä. Mahdollisiin päiviin lasketaan vain
+// aikataulun mukaiset päivät tavan luonnista alkaen, ohitetut päivät pois lukien.
+type HabitStats struct {
+	HabitID                int64          `json:"habit_id"`
+	Start                  time.Time      `json:"start"`
+	End                    time.Time      `json:"end"`
+	TotalCompletions       int            `json:"total_completions"`
+	PossibleDays           int            `json:"possible_days"`
+	CompletedDays          int            `json:"completed_days"`
+	CompletionRate         float64        `json:"completion_rate"`
+	AvgPerWeek             float64        `json:"avg_per_week"`
+	AvgPerMonth            float64        `json:"avg_per_month"`
+	PerWeek                []PeriodCount  `json:"per_week"`
+	PerMonth               []PeriodCount  `json:"per_month"`
+	Weekdays               []WeekdayStats `json:"weekdays"`
+	BestWeekday            *int           `json:"best_weekday"`
+	WorstWeekday           *int           `json:"worst_weekday"`
+	PreviousCompletionRate float64        `json:"previous_completion_rate"`
+	Trend                  float64        `json:"trend"` // completion_rate - previous_completion_rate
+}
+
+// GetHabitStats laskee tavan tilastot SQL-aggregaatteina. Vertailujakso on
+// välittömästi edeltävä yhtä pitkä jakso.
+func (s *HabitCompletionStore) GetHabitStats(ctx context.Context, habitID, userID int64, startDate, endDate time.Time) (*HabitStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	stats := &HabitStats{
+		HabitID: habitID,
+		Start:   startDate,
+		End:     endDate,
+	}
+
+	weekdays, err := s.weekdayBreakdown(ctx, habitID, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	stats.Weekdays = weekdays
+
+	for _, wd := range weekdays {
+		stats.PossibleDays += wd.PossibleDays
+		stats.CompletedDays += wd.CompletedDays
+
+		if wd.PossibleDays == 0 {
+			continue
+		}
+		if stats.BestWeekday == nil || wd.CompletionRate > weekdays[*stats.BestWeekday-1].CompletionRate {
+			stats.BestWeekday = &wd.Weekday
+		}
+		if stats.WorstWeekday == nil || wd.CompletionRate < weekdays[*stats.WorstWeekday-1].CompletionRate {
+			stats.WorstWeekday = &wd.Weekday
+		}
+	}
+	stats.CompletionRate = rate(stats.CompletedDays, stats.PossibleDays)
+
+	stats.PerWeek, err = s.countByPeriod(ctx, "week", habitID, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.PerMonth, err = s.countByPeriod(ctx, "month", habitID, userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range stats.PerWeek {
+		stats.TotalCompletions += p.Completions
+	}
+
+	days := endDate.Sub(startDate).Hours()/24 + 1
+	stats.AvgPerWeek = float64(stats.TotalCompletions) / (days / 7)
+	stats.AvgPerMonth = float64(stats.TotalCompletions) / (days / 30.44)
+
+	length := int(days)
+	prevEnd := startDate.AddDate(0, 0, -1)
+	prevStart := startDate.AddDate(0, 0, -length)
+
+	previous, err := s.weekdayBreakdown(ctx, habitID, userID, prevStart, prevEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var prevPossible, prevCompleted int
+	for _, wd := range previous {
+		prevPossible += wd.PossibleDays
+		prevCompleted += wd.CompletedDays
+	}
+	stats.PreviousCompletionRate = rate(prevCompleted, prevPossible)
+	stats.Trend = stats.CompletionRate - stats.PreviousCompletionRate
+
+	return stats, nil
+}
+
+// weekdayBreakdown palauttaa mahdolliset ja tehdyt päivät ISO-viikonpäivittäin (aina 7 riviä)
+func (s *HabitCompletionStore) weekdayBreakdown(ctx context.Context, habitID, userID int64, startDate, endDate time.Time) ([]WeekdayStats, error) {
+	query := `
+		WITH h AS (
+			SELECT id, target, schedule_days, created_at::date AS created
+			FROM habits
+			WHERE id = $1 AND user_id = $2
+		), possible AS (
+			SELECT d::date AS day
+			FROM h, generate_series($3::date, $4::date, interval '1 day') AS d
+			WHERE d::date >= h.created
+			  AND (cardinality(h.schedule_days) = 0 OR EXTRACT(ISODOW FROM d::date)::smallint = ANY(h.schedule_days))
+			  AND NOT EXISTS (SELECT 1 FROM habit_skips s WHERE s.habit_id = h.id AND s.skip_date = d::date)
+		)
+		SELECT EXTRACT(ISODOW FROM p.day)::int AS weekday,
+			COUNT(*) AS possible,
+			COUNT(c.id) AS completed
+		FROM possible p
+		CROSS JOIN h
+		LEFT JOIN habit_completions c
+			ON c.habit_id = h.id AND c.completed_date = p.day AND c.amount >= h.target
+		GROUP BY weekday
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, habitID, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weekdays := make([]WeekdayStats, 7)
+	for i := range weekdays {
+		weekdays[i].Weekday = i + 1
+	}
+
+	for rows.Next() {
+		var wd WeekdayStats
+		if err := rows.Scan(&wd.Weekday, &wd.PossibleDays, &wd.CompletedDays); err != nil {
+			return nil, err
+		}
+		wd.CompletionRate = rate(wd.CompletedDays, wd.PossibleDays)
+		weekdays[wd.Weekday-1] = wd
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return weekdays, nil
+}
+
+// countByPeriod laskee merkinnät viikoittain tai kuukausittain (unit = week | month)
+func (s *HabitCompletionStore) countByPeriod(ctx context.Context, unit string, habitID, userID int64, startDate, endDate time.Time) ([]PeriodCount, error) {
+	query := `
+		SELECT date_trunc($1, completed_date::timestamp)::date AS period, COUNT(*)
+		FROM habit_completions
+		WHERE habit_id = $2
+		  AND user_id = $3
+		  AND completed_date BETWEEN $4 AND $5
+		GROUP BY period
+		ORDER BY period
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, unit, habitID, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []PeriodCount{}
+	for rows.Next() {
+		var p PeriodCount
+		if err := rows.Scan(&p.Period, &p.Completions); err != nil {
+			return nil, err
+		}
+		counts = append(counts, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func rate(done, possible int) float64 {
+	if possible == 0 {
+		return 0
+	}
+	return float64(done) / float64(possible)
+}
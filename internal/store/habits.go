@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type habitKey string
@@ -12,46 +14,88 @@ type habitKey string
 const postCtxKey habitKey = "habit"
 
 type Habit struct {
-	ID         int64  `json:"id"`
-	Name       string `json:"name"`
-	UserID     int64  `json:"-"`
-	Impact     string `json:"impact"`
-	GoalID     *int64 `json:"goal_id"`
-	Created_at string `json:"created_at"`
-	Updated_at string `json:"updated_at"`
-	Version    int    `json:"version"`
-	User       User   `json:"-"`
+	ID           int64   `json:"id"`
+	Name         string  `json:"name"`
+	UserID       int64   `json:"-"`
+	Impact       string  `json:"impact"`
+	GoalID       *int64  `json:"goal_id"`
+	GroupID      *int64  `json:"group_id"`
+	Position     int     `json:"position"`
+	TagIDs       []int64 `json:"tag_ids"`
+	ScheduleDays []int64 `json:"schedule_days"` // ISO-viikonpäivät (1 = ma ... 7 = su), tyhjä = joka päivä
+	Target       int     `json:"target"`
+	Created_at   string  `json:"created_at"`
+	Updated_at   string  `json:"updated_at"`
+	Version      int     `json:"version"`
+	User         User    `json:"-"`
+}
+
+// HabitPosition kertoo tavan paikan käyttäjän järjestämässä listassa
+type HabitPosition struct {
+	ID       int64
+	GroupID  *int64
+	Position int
+}
+
+// IsDueOn kertoo, kuuluuko tapa tehdä annettuna päivänä
+func (h *Habit) IsDueOn(date time.Time) bool {
+	if len(h.ScheduleDays) == 0 {
+		return true
+	}
+
+	weekday := int64(date.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+
+	for _, d := range h.ScheduleDays {
+		if d == weekday {
+			return true
+		}
+	}
+
+	return false
 }
 
 type HabitStore struct {
 	db *sql.DB
 }
 
-func (s *HabitStore) Create(ctx context.Context, habit *Habit) error {
+func (s *HabitStore) Create(ctx context.Context, habit *Habit, event *Event) error {
 	query := `
-    INSERT INTO habits (name, impact, user_id, goal_id)
-    VALUES  ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+    INSERT INTO habits (name, impact, user_id, goal_id, group_id, schedule_days, target, position)
+    VALUES  ($1, $2, $3, $4, $5, $6, $7, COALESCE((SELECT MAX(position) + 1 FROM habits WHERE user_id = $3), 0))
+    RETURNING id, position, created_at, updated_at
   `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, habit.Name, habit.Impact, habit.UserID, habit.GoalID).Scan(
-		&habit.ID,
-		&habit.Created_at,
-		&habit.Updated_at,
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return withEvent(s.db, ctx, habit.UserID, event, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(
+			ctx,
+			query,
+			habit.Name,
+			habit.Impact,
+			habit.UserID,
+			habit.GoalID,
+			habit.GroupID,
+			pq.Array(habit.ScheduleDays),
+			habit.Target,
+		).Scan(
+			&habit.ID,
+			&habit.Position,
+			&habit.Created_at,
+			&habit.Updated_at,
+		)
+	})
 }
 
 func (s *HabitStore) GetByID(ctx context.Context, id int64, userID int64) (*Habit, error) {
 	query := `
-		SELECT id, name, user_id, impact, goal_id, created_at, updated_at, version
+		SELECT id, name, user_id, impact, goal_id, group_id, position,
+			ARRAY(SELECT tag_id FROM habit_tags WHERE habit_id = habits.id ORDER BY tag_id),
+			schedule_days, target, created_at, updated_at, version
     FROM habits
 		WHERE id = $1 AND user_id = $2
   `
@@ -67,6 +111,11 @@ func (s *HabitStore) GetByID(ctx context.Context, id int64, userID int64) (*Habi
 		&habit.UserID,
 		&habit.Impact,
 		&habit.GoalID,
+		&habit.GroupID,
+		&habit.Position,
+		pq.Array(&habit.TagIDs),
+		pq.Array(&habit.ScheduleDays),
+		&habit.Target,
 		&habit.Created_at,
 		&habit.Updated_at,
 		&habit.Version,
@@ -84,6 +133,55 @@ func (s *HabitStore) GetByID(ctx context.Context, id int64, userID int64) (*Habi
 	return &habit, nil
 }
 
+// GetByIDs hakee käyttäjän tavat yhdellä kyselyllä. Muille kuuluvat tai puuttuvat id:t jätetään pois.
+func (s *HabitStore) GetByIDs(ctx context.Context, userID int64, ids []int64) (map[int64]*Habit, error) {
+	query := `
+		SELECT id, name, user_id, impact, goal_id, group_id, position,
+			ARRAY(SELECT tag_id FROM habit_tags WHERE habit_id = habits.id ORDER BY tag_id),
+			schedule_days, target, created_at, updated_at, version
+		FROM habits
+		WHERE user_id = $1 AND id = ANY($2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	habits := map[int64]*Habit{}
+	for rows.Next() {
+		habit := &Habit{}
+		if err := rows.Scan(
+			&habit.ID,
+			&habit.Name,
+			&habit.UserID,
+			&habit.Impact,
+			&habit.GoalID,
+			&habit.GroupID,
+			&habit.Position,
+			pq.Array(&habit.TagIDs),
+			pq.Array(&habit.ScheduleDays),
+			&habit.Target,
+			&habit.Created_at,
+			&habit.Updated_at,
+			&habit.Version,
+		); err != nil {
+			return nil, err
+		}
+		habits[habit.ID] = habit
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return habits, nil
+}
+
 func (s *HabitStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]Habit, error) {
 	query := `
 		SELECT h.id,
@@ -91,17 +189,28 @@ func (s *HabitStore) GetUserFeed(ctx context.Context, userID int64, fq Paginated
       h.user_id,
      	h.impact,
 		  h.goal_id,
+		  h.group_id,
+		  h.position,
+		  ARRAY(SELECT tag_id FROM habit_tags WHERE habit_id = h.id ORDER BY tag_id),
+		  h.schedule_days,
+		  h.target,
 		  h.created_at,
       h.version
 		FROM habits h
+		LEFT JOIN habit_groups g ON g.id = h.group_id
 		WHERE h.user_id = $1
+		  AND (
+			COALESCE(cardinality($4::bigint[]), 0) = 0
+			OR EXISTS (SELECT 1 FROM habit_tags ht WHERE ht.habit_id = h.id AND ht.tag_id = ANY($4))
+		  )
+		ORDER BY g.position NULLS FIRST, g.id, h.position, h.id
 		LIMIT $2 OFFSET $3;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset)
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, pq.Array(fq.Tags))
 	if err != nil {
 		return nil, err
 	}
@@ -117,6 +226,11 @@ func (s *HabitStore) GetUserFeed(ctx context.Context, userID int64, fq Paginated
 			&h.UserID,
 			&h.Impact,
 			&h.GoalID,
+			&h.GroupID,
+			&h.Position,
+			pq.Array(&h.TagIDs),
+			pq.Array(&h.ScheduleDays),
+			&h.Target,
 			&h.Created_at,
 			&h.Version,
 		); err != nil {
@@ -129,49 +243,146 @@ func (s *HabitStore) GetUserFeed(ctx context.Context, userID int64, fq Paginated
 	return feed, nil
 }
 
-func (s *HabitStore) Delete(ctx context.Context, postID int64, userID int64) error {
+func (s *HabitStore) Delete(ctx context.Context, postID int64, userID int64, event *Event) error {
 	query := `DELETE FROM habits WHERE id = $1 AND user_id = $2`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, postID, userID)
-	if err != nil {
-		return err
-	}
+	return withEvent(s.db, ctx, userID, event, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query, postID, userID)
+		if err != nil {
+			return err
+		}
 
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
 
-	if rows == 0 {
-		return ErrNotFound
-	}
+		if rows == 0 {
+			return ErrNotFound
+		}
 
-	return nil
+		return nil
+	})
 }
 
-func (s *HabitStore) Update(ctx context.Context, habit *Habit, userID int64) error {
+// Update tallentaa tavan. Toiseen ryhmään (tai pois ryhmästä) siirretty tapa siirtyy
+// listan loppuun kuten uusi tapa, jotta paikat eivät mene päällekkäin.
+func (s *HabitStore) Update(ctx context.Context, habit *Habit, userID int64, event *Event) error {
 	query := `
 		UPDATE habits
-		SET name = $1, impact = $2, goal_id = $3, version = version + 1
-		WHERE id = $4 AND user_id = $5 AND version = $6
-		RETURNING version
+		SET name = $1, impact = $2, goal_id = $3, group_id = $4, schedule_days = $5, target = $6, version = version + 1,
+			position = CASE WHEN group_id IS DISTINCT FROM $4
+				THEN COALESCE((SELECT MAX(position) + 1 FROM habits WHERE user_id = $8), 0)
+				ELSE position END
+		WHERE id = $7 AND user_id = $8 AND version = $9
+		RETURNING version, position
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, habit.Name, habit.Impact, habit.GoalID, habit.ID, userID, habit.Version).Scan(&habit.Version)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
-			return err
+	return withEvent(s.db, ctx, userID, event, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			habit.Name,
+			habit.Impact,
+			habit.GoalID,
+			habit.GroupID,
+			pq.Array(habit.ScheduleDays),
+			habit.Target,
+			habit.ID,
+			userID,
+			habit.Version,
+		).Scan(&habit.Version, &habit.Position)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
+}
+
+// Reorder päivittää käyttäjän tapojen ryhmät ja järjestyksen yhdessä transaktiossa.
+// Jos jokin tapa tai ryhmä ei kuulu käyttäjälle, mitään ei muuteta. Listasta puuttuvat
+// tavat siirtyvät ryhmänsä listattujen tapojen perään entisessä järjestyksessään, joten
+// paikat ovat aina ryhmän sisällä yksikäsitteiset.
+func (s *HabitStore) Reorder(ctx context.Context, userID int64, positions []HabitPosition) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		groupIDs := []int64{}
+		seen := map[int64]bool{}
+		for _, p := range positions {
+			if p.GroupID != nil && !seen[*p.GroupID] {
+				seen[*p.GroupID] = true
+				groupIDs = append(groupIDs, *p.GroupID)
+			}
+		}
+
+		if len(groupIDs) > 0 {
+			var owned int
+			err := tx.QueryRowContext(
+				ctx,
+				`SELECT COUNT(*) FROM habit_groups WHERE user_id = $1 AND id = ANY($2)`,
+				userID,
+				pq.Array(groupIDs),
+			).Scan(&owned)
+			if err != nil {
+				return err
+			}
+			if owned != len(groupIDs) {
+				return ErrNotFound
+			}
+		}
+
+		query := `UPDATE habits SET group_id = $1, position = $2 WHERE id = $3 AND user_id = $4`
+
+		for _, p := range positions {
+			res, err := tx.ExecContext(ctx, query, p.GroupID, p.Position, p.ID, userID)
+			if err != nil {
+				return err
+			}
+
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			if rows == 0 {
+				return ErrNotFound
+			}
+		}
+
+		ids := make([]int64, 0, len(positions))
+		for _, p := range positions {
+			ids = append(ids, p.ID)
+		}
+
+		renumber := `
+			UPDATE habits h
+			SET position = o.position
+			FROM (
+				SELECT id, ROW_NUMBER() OVER (
+					PARTITION BY group_id
+					ORDER BY id = ANY($2) DESC, position, id
+				) - 1 AS position
+				FROM habits
+				WHERE user_id = $1
+			) o
+			WHERE h.id = o.id AND h.position <> o.position
+		`
+
+		_, err := tx.ExecContext(ctx, renumber, userID, pq.Array(ids))
+		return err
+	})
 }
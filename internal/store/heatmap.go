@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// HeatmapLevels on intensiteettitasojen määrä nollan lisäksi (0 = ei merkintöjä)
+const HeatmapLevels = 4
+
+// HeatmapCell on heatmapin yksi päivä: montako suunniteltua tapaa oli ja montako tehtiin
+type HeatmapCell struct {
+	Date      string  `json:"date"` // 2006-01-02
+	Due       int     `json:"due"`
+	Completed int     `json:"completed"`
+	Rate      float64 `json:"rate"`
+	Level     int     `json:"level"`
+}
+
+// GetHeatmap palauttaa yhden solun jokaiselle päivälle välillä startDate..endDate yhdellä
+// aggregaattikyselyllä. habitID rajaa yhteen tapaan, nil laskee kaikki käyttäjän tavat.
+// Suunnitellut päivät noudattavat aikataulua, luontipäivää ja ohitettuja päiviä.
+func (s *HabitCompletionStore) GetHeatmap(ctx context.Context, userID int64, habitID *int64, startDate, endDate time.Time) ([]HeatmapCell, error) {
+	query := `
+		SELECT to_char(d.day, 'YYYY-MM-DD'),
+			COUNT(h.id) AS due,
+			COUNT(c.id) AS completed
+		FROM (
+			SELECT g::date AS day
+			FROM generate_series($2::date, $3::date, interval '1 day') AS g
+		) d
+		LEFT JOIN habits h
+			ON h.user_id = $1
+			AND ($4::bigint IS NULL OR h.id = $4)
+			AND h.created_at::date <= d.day
+			AND (cardinality(h.schedule_days) = 0 OR EXTRACT(ISODOW FROM d.day)::smallint = ANY(h.schedule_days))
+			AND NOT EXISTS (SELECT 1 FROM habit_skips s WHERE s.habit_id = h.id AND s.skip_date = d.day)
+		LEFT JOIN habit_completions c
+			ON c.habit_id = h.id AND c.completed_date = d.day AND c.amount >= h.target
+		GROUP BY d.day
+		ORDER BY d.day
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), habitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cells := []HeatmapCell{}
+	for rows.Next() {
+		var c HeatmapCell
+		if err := rows.Scan(&c.Date, &c.Due, &c.Completed); err != nil {
+			return nil, err
+		}
+		c.Rate = rate(c.Completed, c.Due)
+		c.Level = heatmapLevel(c.Completed, c.Rate)
+		cells = append(cells, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cells, nil
+}
+
+// heatmapLevel jakaa onnistumisprosentin tasoihin 1..HeatmapLevels; 0 kun mitään ei tehty
+func heatmapLevel(completed int, rate float64) int {
+	if completed == 0 {
+		return 0
+	}
+
+	level := int(math.Ceil(rate * HeatmapLevels))
+	return max(1, min(level, HeatmapLevels))
+}
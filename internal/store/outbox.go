@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// OutboxTopicEmail on sähköpostien aihe outbox-taulussa
+const OutboxTopicEmail = "email"
+
+// OutboxEmail on jonoon tallennettu sähköposti. Data välitetään sellaisenaan
+// mailer.Client.Send-kutsun templatelle.
+type OutboxEmail struct {
+	Template string `json:"template"`
+	Locale   string `json:"locale"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Data     any    `json:"data"`
+}
+
+// OutboxMessage on lähetettäväksi varattu outbox-rivi
+type OutboxMessage struct {
+	ID       int64
+	Topic    string
+	Payload  json.RawMessage
+	Attempts int
+}
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+// enqueueOutbox kirjoittaa viestin outboxiin kutsujan transaktiossa, joten viesti
+// lähtee jos ja vain jos muutos tallentuu
+func enqueueOutbox(ctx context.Context, tx *sql.Tx, topic string, payload any) error {
+	query := `INSERT INTO outbox (topic, payload) VALUES ($1, $2)`
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err = tx.ExecContext(ctx, query, topic, string(body))
+	return err
+}
+
+// ClaimDue varaa enintään limit erääntynyttä viestiä lease-ajaksi. FOR UPDATE SKIP
+// LOCKED estää instansseja varaamasta samoja rivejä. Vanhentunut varaus otetaan
+// uudelleen käsittelyyn, tai merkitään epäonnistuneeksi jos yrityksiä on jo maxAttempts.
+func (s *OutboxStore) ClaimDue(ctx context.Context, limit, maxAttempts int, lease time.Duration) ([]OutboxMessage, error) {
+	expire := `
+		UPDATE outbox
+		SET status = 'failed', claimed_until = NULL, last_error = 'lease expired on the last attempt',
+			payload = payload - 'data'
+		WHERE status = 'sending' AND claimed_until < NOW() AND attempts >= $1
+	`
+
+	query := `
+		WITH due AS (
+			SELECT id
+			FROM outbox
+			WHERE (status = 'pending' AND next_attempt_at <= NOW())
+			   OR (status = 'sending' AND claimed_until < NOW() AND attempts < $3)
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox o
+		SET status = 'sending', attempts = o.attempts + 1, claimed_until = NOW() + $2 * interval '1 second'
+		FROM due
+		WHERE o.id = due.id
+		RETURNING o.id, o.topic, o.payload, o.attempts
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, expire, maxAttempts); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, limit, int(lease.Seconds()), maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []OutboxMessage{}
+	for rows.Next() {
+		var m OutboxMessage
+		var payload []byte
+		if err := rows.Scan(&m.ID, &m.Topic, &payload, &m.Attempts); err != nil {
+			return nil, err
+		}
+		m.Payload = payload
+		messages = append(messages, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkSent merkitsee viestin lähetetyksi. Templaten data (esim. aktivointilinkki)
+// poistetaan, jotta tokenit eivät jää kantaan selväkielisinä.
+func (s *OutboxStore) MarkSent(ctx context.Context, id int64) error {
+	query := `
+		UPDATE outbox
+		SET status = 'sent', sent_at = NOW(), claimed_until = NULL, last_error = '', payload = payload - 'data'
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkFailed ajastaa uuden yrityksen hetkeen retryAt, tai merkitsee viestin
+// lopullisesti epäonnistuneeksi kun retryAt on nil. Lopullisesti epäonnistuneesta
+// viestistä poistetaan data kuten MarkSentissä.
+func (s *OutboxStore) MarkFailed(ctx context.Context, id int64, sendErr string, retryAt *time.Time) error {
+	query := `
+		UPDATE outbox
+		SET status = CASE WHEN $3::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			payload = CASE WHEN $3::timestamptz IS NULL THEN payload - 'data' ELSE payload END,
+			next_attempt_at = COALESCE($3, next_attempt_at),
+			last_error = $2, claimed_until = NULL
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, sendErr, retryAt)
+	return err
+}
@@ -1,14 +1,17 @@
 package store
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type PaginatedFeedQuery struct {
-	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
-	Offset int    `json:"offset" validate:"gte=0"`
-	Sort   string `json:"sort" validate:"oneof=asc desc"`
+	Limit  int     `json:"limit" validate:"gte=1,lte=20"`
+	Offset int     `json:"offset" validate:"gte=0"`
+	Sort   string  `json:"sort" validate:"oneof=asc desc"`
+	Tags   []int64 `json:"tags"`
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
@@ -39,5 +42,29 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Sort = sort
 	}
 
+	tags, err := ParseIDList(qs.Get("tags"))
+	if err != nil {
+		return fq, err
+	}
+	fq.Tags = tags
+
 	return fq, nil
 }
+
+// ParseIDList parses a comma separated list of ids, e.g. "1,2,3"
+func ParseIDList(s string) ([]int64, error) {
+	ids := []int64{}
+	if s == "" {
+		return ids, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
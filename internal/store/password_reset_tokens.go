@@ -11,17 +11,27 @@ type PasswordResetTokenStore struct {
 	db *sql.DB
 }
 
-func (s *PasswordResetTokenStore) Create(ctx context.Context, userID int64, tokenHash string, expiry time.Time) error {
-	query := `
-		INSERT INTO password_reset_tokens (token, user_id, expiry)
-		VALUES ($1, $2, $3)
-	`
+// Create tallentaa reset-tokenin ja jonottaa viestin samassa transaktiossa
+func (s *PasswordResetTokenStore) Create(ctx context.Context, userID int64, tokenHash string, expiry time.Time, mail *OutboxEmail) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO password_reset_tokens (token, user_id, expiry)
+			VALUES ($1, $2, $3)
+		`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, tokenHash, userID, expiry)
-	return err
+		if _, err := tx.ExecContext(ctx, query, tokenHash, userID, expiry); err != nil {
+			return err
+		}
+
+		if mail != nil {
+			return enqueueOutbox(ctx, tx, OutboxTopicEmail, mail)
+		}
+
+		return nil
+	})
 }
 
 func (s *PasswordResetTokenStore) Consume(ctx context.Context, tokenHash string, now time.Time, passwordHash []byte) error {
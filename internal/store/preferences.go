@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Preferences on käyttäjän asetukset sähköposteille ja ilmoituksille
+type Preferences struct {
+	WeeklyDigest bool   `json:"weekly_digest"`
+	DigestDay    int    `json:"digest_day"` // ISO, 1 = Monday
+	Locale       string `json:"locale"`
+}
+
+func (s *UserStore) GetPreferences(ctx context.Context, userID int64) (*Preferences, error) {
+	query := `SELECT weekly_digest, digest_day, locale FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	prefs := &Preferences{}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&prefs.WeeklyDigest, &prefs.DigestDay, &prefs.Locale)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return prefs, nil
+}
+
+func (s *UserStore) UpdatePreferences(ctx context.Context, userID int64, prefs *Preferences) error {
+	query := `UPDATE users SET weekly_digest = $1, digest_day = $2, locale = $3 WHERE id = $4`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, prefs.WeeklyDigest, prefs.DigestDay, prefs.Locale, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrPushSubscriptionTaken = errors.New("push subscription belongs to another user")
+
+// PushSubscription on selaimen Web Push -tilaus
+type PushSubscription struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	Endpoint  string    `json:"endpoint"`
+	P256dh    string    `json:"-"`
+	Auth      string    `json:"-"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PushSubscriptionStore struct {
+	db *sql.DB
+}
+
+// Save tallentaa tilauksen. Endpoint on yksilöllinen, joten saman selaimen uusi
+// tilaus korvaa vanhan avaimet. Toisen käyttäjän tilauksen voi ottaa vain sama
+// selain (sama auth-salaisuus, jota ei näe kuin selain), muuten ErrPushSubscriptionTaken.
+func (s *PushSubscriptionStore) Save(ctx context.Context, sub *PushSubscription) error {
+	query := `
+		INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth, user_agent)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (endpoint) DO UPDATE
+		SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth, user_agent = EXCLUDED.user_agent
+		WHERE push_subscriptions.user_id = EXCLUDED.user_id OR push_subscriptions.auth = EXCLUDED.auth
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, sub.UserID, sub.Endpoint, sub.P256dh, sub.Auth, sub.UserAgent).Scan(
+		&sub.ID,
+		&sub.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		// ON CONFLICT ... WHERE ei päivittänyt mitään
+		return ErrPushSubscriptionTaken
+	}
+
+	return err
+}
+
+func (s *PushSubscriptionStore) GetByUser(ctx context.Context, userID int64) ([]PushSubscription, error) {
+	query := `
+		SELECT id, user_id, endpoint, p256dh, auth, user_agent, created_at
+		FROM push_subscriptions
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []PushSubscription{}
+	for rows.Next() {
+		var sub PushSubscription
+		if err := rows.Scan(
+			&sub.ID,
+			&sub.UserID,
+			&sub.Endpoint,
+			&sub.P256dh,
+			&sub.Auth,
+			&sub.UserAgent,
+			&sub.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+func (s *PushSubscriptionStore) Delete(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM push_subscriptions WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteByEndpoint poistaa tilauksen, jonka push-palvelu on ilmoittanut vanhentuneeksi
+func (s *PushSubscriptionStore) DeleteByEndpoint(ctx context.Context, endpoint string) error {
+	query := `DELETE FROM push_subscriptions WHERE endpoint = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, endpoint)
+	return err
+}
+
+// PendingStreakWarnings palauttaa push-tilaajat, joiden paikallinen kello on ohittanut
+// hour ja joille päivän putkivaroitusta ei ole vielä käsitelty. Jakso on paikallinen
+// päivä muodossa 2006-01-02.
+func (s *PushSubscriptionStore) PendingStreakWarnings(ctx context.Context, kind string, hour, limit int) ([]User, error) {
+	query := `
+		SELECT u.id, u.public_id, u.username, u.email, u.created_at, u.is_active, u.timezone, u.locale
+		FROM users u
+		WHERE u.is_active
+		  AND EXTRACT(HOUR FROM NOW() AT TIME ZONE u.timezone) >= $2
+		  AND EXISTS (SELECT 1 FROM push_subscriptions p WHERE p.user_id = u.id)
+		  AND NOT EXISTS (
+			SELECT 1 FROM email_deliveries d
+			WHERE d.user_id = u.id
+			  AND d.kind = $1
+			  AND d.period = to_char(NOW() AT TIME ZONE u.timezone, 'YYYY-MM-DD')
+			  AND (d.sent_at IS NOT NULL OR d.claimed_until > NOW())
+		  )
+		ORDER BY u.id
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, kind, hour, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(
+			&user.ID,
+			&user.PublicID,
+			&user.Username,
+			&user.Email,
+			&user.CreatedAt,
+			&user.IsActive,
+			&user.Timezone,
+			&user.Locale,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
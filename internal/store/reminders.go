@@ -0,0 +1,344 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrDuplicateReminder = errors.New("the habit already has a reminder at this time")
+
+// Reminder on tavan muistutus kellonaikana käyttäjän aikavyöhykkeellä (HH:MM)
+type Reminder struct {
+	ID        int64     `json:"id"`
+	HabitID   int64     `json:"habit_id"`
+	UserID    int64     `json:"-"`
+	Time      string    `json:"time"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DueReminder on lähetettäväksi varattu muistutus vastaanottajan tietoineen
+type DueReminder struct {
+	ReminderID   int64
+	ScheduledFor time.Time
+	Attempts     int
+	HabitID      int64
+	HabitName    string
+	UserID       int64
+	Username     string
+	Email        string
+	Locale       string
+}
+
+type ReminderStore struct {
+	db *sql.DB
+}
+
+func (s *ReminderStore) Create(ctx context.Context, reminder *Reminder) error {
+	query := `
+		INSERT INTO habit_reminders (habit_id, user_id, remind_at, enabled)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, reminder.HabitID, reminder.UserID, reminder.Time, reminder.Enabled).Scan(
+		&reminder.ID,
+		&reminder.CreatedAt,
+		&reminder.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "habit_reminders_habit_id_remind_at_key"`:
+			return ErrDuplicateReminder
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ReminderStore) GetByID(ctx context.Context, id, habitID int64) (*Reminder, error) {
+	query := `
+		SELECT id, habit_id, user_id, to_char(remind_at, 'HH24:MI'), enabled, created_at, updated_at
+		FROM habit_reminders
+		WHERE id = $1 AND habit_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	reminder := &Reminder{}
+	err := s.db.QueryRowContext(ctx, query, id, habitID).Scan(
+		&reminder.ID,
+		&reminder.HabitID,
+		&reminder.UserID,
+		&reminder.Time,
+		&reminder.Enabled,
+		&reminder.CreatedAt,
+		&reminder.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return reminder, nil
+}
+
+func (s *ReminderStore) GetByHabit(ctx context.Context, habitID int64) ([]Reminder, error) {
+	query := `
+		SELECT id, habit_id, user_id, to_char(remind_at, 'HH24:MI'), enabled, created_at, updated_at
+		FROM habit_reminders
+		WHERE habit_id = $1
+		ORDER BY remind_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, habitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []Reminder{}
+	for rows.Next() {
+		var reminder Reminder
+		if err := rows.Scan(
+			&reminder.ID,
+			&reminder.HabitID,
+			&reminder.UserID,
+			&reminder.Time,
+			&reminder.Enabled,
+			&reminder.CreatedAt,
+			&reminder.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+func (s *ReminderStore) Update(ctx context.Context, reminder *Reminder) error {
+	query := `
+		UPDATE habit_reminders
+		SET remind_at = $1, enabled = $2, updated_at = NOW()
+		WHERE id = $3 AND habit_id = $4
+		RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, reminder.Time, reminder.Enabled, reminder.ID, reminder.HabitID).Scan(&reminder.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		case err.Error() == `pq: duplicate key value violates unique constraint "habit_reminders_habit_id_remind_at_key"`:
+			return ErrDuplicateReminder
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ReminderStore) Delete(ctx context.Context, id, habitID int64) error {
+	query := `DELETE FROM habit_reminders WHERE id = $1 AND habit_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, habitID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// EnqueueDue lisää jonoon tämänpäiväiset muistutukset, joiden kellonaika on mennyt alle
+// window sitten. Tapa ei saa olla ohitettu tai jo tehty, ja sen pitää olla aikataulussa.
+// Pääavain tekee lisäyksestä idempotentin, joten kaikki instanssit voivat ajaa tätä.
+func (s *ReminderStore) EnqueueDue(ctx context.Context, window time.Duration) (int64, error) {
+	query := `
+		INSERT INTO reminder_deliveries (reminder_id, scheduled_for)
+		SELECT o.id, o.scheduled_for
+		FROM (
+			SELECT r.id, r.habit_id,
+				(NOW() AT TIME ZONE u.timezone)::date AS local_day,
+				((NOW() AT TIME ZONE u.timezone)::date + r.remind_at) AT TIME ZONE u.timezone AS scheduled_for
+			FROM habit_reminders r
+			JOIN users u ON u.id = r.user_id
+			WHERE r.enabled AND u.is_active
+		) o
+		JOIN habits h ON h.id = o.habit_id
+		WHERE o.scheduled_for <= NOW()
+		  AND o.scheduled_for > NOW() - $1 * interval '1 second'
+		  AND (cardinality(h.schedule_days) = 0 OR EXTRACT(ISODOW FROM o.local_day)::smallint = ANY(h.schedule_days))
+		  AND NOT EXISTS (SELECT 1 FROM habit_skips s WHERE s.habit_id = h.id AND s.skip_date = o.local_day)
+		  AND NOT EXISTS (
+			SELECT 1 FROM habit_completions c
+			WHERE c.habit_id = h.id AND c.completed_date = o.local_day AND c.amount >= h.target
+		  )
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, int(window.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// ClaimDue varaa enintään limit erääntynyttä lähetystä lease-ajaksi. FOR UPDATE SKIP LOCKED
+// estää instansseja varaamasta samoja rivejä; vanhentunut varaus otetaan uudelleen
+// käsittelyyn, tai merkitään epäonnistuneeksi jos yrityksiä on jo maxAttempts.
+func (s *ReminderStore) ClaimDue(ctx context.Context, limit, maxAttempts int, lease time.Duration) ([]DueReminder, error) {
+	expire := `
+		UPDATE reminder_deliveries
+		SET status = 'failed', claimed_until = NULL, last_error = 'lease expired on the last attempt'
+		WHERE status = 'sending' AND claimed_until < NOW() AND attempts >= $1
+	`
+
+	query := `
+		WITH due AS (
+			SELECT reminder_id, scheduled_for
+			FROM reminder_deliveries
+			WHERE ((status = 'pending' AND next_attempt_at <= NOW()) OR (status = 'sending' AND claimed_until < NOW()))
+			  AND attempts < $2
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE reminder_deliveries d
+		SET status = 'sending', attempts = d.attempts + 1, claimed_until = NOW() + $3 * interval '1 second'
+		FROM due, habit_reminders r, habits h, users u
+		WHERE d.reminder_id = due.reminder_id
+		  AND d.scheduled_for = due.scheduled_for
+		  AND r.id = d.reminder_id
+		  AND h.id = r.habit_id
+		  AND u.id = r.user_id
+		RETURNING d.reminder_id, d.scheduled_for, d.attempts, h.id, h.name, u.id, u.username, u.email, u.locale
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, expire, maxAttempts); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, limit, maxAttempts, int(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := []DueReminder{}
+	for rows.Next() {
+		var d DueReminder
+		if err := rows.Scan(
+			&d.ReminderID,
+			&d.ScheduledFor,
+			&d.Attempts,
+			&d.HabitID,
+			&d.HabitName,
+			&d.UserID,
+			&d.Username,
+			&d.Email,
+			&d.Locale,
+		); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return due, nil
+}
+
+func (s *ReminderStore) MarkSent(ctx context.Context, reminderID int64, scheduledFor time.Time) error {
+	query := `
+		UPDATE reminder_deliveries
+		SET status = 'sent', sent_at = NOW(), claimed_until = NULL, last_error = ''
+		WHERE reminder_id = $1 AND scheduled_for = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, reminderID, scheduledFor)
+	return err
+}
+
+// MarkSkipped merkitsee muistutuksen ohitetuksi, kun sille ei ollut minne
+// lähettää (esim. ei push-tilauksia). Ohitettuja ei yritetä uudelleen.
+func (s *ReminderStore) MarkSkipped(ctx context.Context, reminderID int64, scheduledFor time.Time, reason string) error {
+	query := `
+		UPDATE reminder_deliveries
+		SET status = 'skipped', claimed_until = NULL, last_error = $3
+		WHERE reminder_id = $1 AND scheduled_for = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, reminderID, scheduledFor, reason)
+	return err
+}
+
+// MarkFailed ajastaa uuden yrityksen hetkeen retryAt, tai merkitsee lähetyksen
+// lopullisesti epäonnistuneeksi kun retryAt on nil
+func (s *ReminderStore) MarkFailed(ctx context.Context, reminderID int64, scheduledFor time.Time, sendErr string, retryAt *time.Time) error {
+	query := `
+		UPDATE reminder_deliveries
+		SET status = CASE WHEN $4::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = COALESCE($4, next_attempt_at),
+			claimed_until = NULL,
+			last_error = $3
+		WHERE reminder_id = $1 AND scheduled_for = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, reminderID, scheduledFor, sendErr, retryAt)
+	return err
+}
@@ -15,50 +15,168 @@ var (
 
 type Storage struct {
 	Habits interface {
-		Create(ctx context.Context, habit *Habit) error
+		Create(ctx context.Context, habit *Habit, event *Event) error
 		GetByID(ctx context.Context, id int64, userID int64) (*Habit, error)
-		Delete(ctx context.Context, id int64, userID int64) error
-		Update(ctx context.Context, habit *Habit, userID int64) error
+		GetByIDs(ctx context.Context, userID int64, ids []int64) (map[int64]*Habit, error)
+		Delete(ctx context.Context, id int64, userID int64, event *Event) error
+		Update(ctx context.Context, habit *Habit, userID int64, event *Event) error
 		GetUserFeed(ctx context.Context, userID int64, fg PaginatedFeedQuery) ([]Habit, error)
+		Reorder(ctx context.Context, userID int64, positions []HabitPosition) error
+		GetDueOn(ctx context.Context, userID int64, date time.Time) ([]TodayHabit, error)
+	}
+	HabitGroups interface {
+		Create(ctx context.Context, group *HabitGroup) error
+		GetByID(ctx context.Context, id int64, userID int64) (*HabitGroup, error)
+		GetByUser(ctx context.Context, userID int64) ([]HabitGroup, error)
+		Update(ctx context.Context, group *HabitGroup) error
+		Delete(ctx context.Context, id int64, userID int64) error
+		Reorder(ctx context.Context, userID int64, groupIDs []int64) error
 	}
 	Users interface {
 		Create(ctx context.Context, tx *sql.Tx, user *User) error
 		GetByID(ctx context.Context, userID int64) (*User, error)
 		GetByPublicID(ctx context.Context, publicID string) (*User, error)
-		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error
+		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration, welcome *OutboxEmail) error
 		Activate(ctx context.Context, token string) error
 		Delete(ctx context.Context, userID int64) error
 		GetByEmail(ctx context.Context, email string) (*User, error)
 		UpdateEmail(ctx context.Context, userID int64, email string) (*User, error)
 		UpdatePassword(ctx context.Context, userID int64, passwordHash []byte) error
+		UpdateTimezone(ctx context.Context, userID int64, timezone string) error
+		GetPreferences(ctx context.Context, userID int64) (*Preferences, error)
+		UpdatePreferences(ctx context.Context, userID int64, prefs *Preferences) error
 	}
 	PasswordResetTokens interface {
-		Create(ctx context.Context, userID int64, tokenHash string, expiry time.Time) error
+		Create(ctx context.Context, userID int64, tokenHash string, expiry time.Time, mail *OutboxEmail) error
 		Consume(ctx context.Context, tokenHash string, now time.Time, passwordHash []byte) error
 	}
 	Goals interface {
-		Create(ctx context.Context, goal *Goal) error
+		Create(ctx context.Context, goal *Goal, event *Event) error
 		GetByID(ctx context.Context, id int64) (*Goal, error)
 		GetByUserAndYear(ctx context.Context, userID int64, year int) ([]Goal, error)
-		Update(ctx context.Context, goal *Goal) error
+		Update(ctx context.Context, goal *Goal, event *Event) error
+		Delete(ctx context.Context, id int64, userID int64, event *Event) error
+		GetProgress(ctx context.Context, userID int64, goalIDs []int64, asOf time.Time) (map[int64]*GoalProgress, error)
+		SaveReview(ctx context.Context, userID int64, year int, reviews []GoalReview) error
+		Rollover(ctx context.Context, userID int64, year int, goalIDs []int64) ([]Rollover, error)
+	}
+	Reminders interface {
+		Create(ctx context.Context, reminder *Reminder) error
+		GetByID(ctx context.Context, id, habitID int64) (*Reminder, error)
+		GetByHabit(ctx context.Context, habitID int64) ([]Reminder, error)
+		Update(ctx context.Context, reminder *Reminder) error
+		Delete(ctx context.Context, id, habitID int64) error
+		EnqueueDue(ctx context.Context, window time.Duration) (int64, error)
+		ClaimDue(ctx context.Context, limit, maxAttempts int, lease time.Duration) ([]DueReminder, error)
+		MarkSent(ctx context.Context, reminderID int64, scheduledFor time.Time) error
+		MarkSkipped(ctx context.Context, reminderID int64, scheduledFor time.Time, reason string) error
+		MarkFailed(ctx context.Context, reminderID int64, scheduledFor time.Time, sendErr string, retryAt *time.Time) error
+	}
+	YearReviews interface {
+		Get(ctx context.Context, userID int64, year int, asOf time.Time) (*YearReview, error)
+	}
+	PushSubscriptions interface {
+		Save(ctx context.Context, sub *PushSubscription) error
+		GetByUser(ctx context.Context, userID int64) ([]PushSubscription, error)
+		Delete(ctx context.Context, id, userID int64) error
+		DeleteByEndpoint(ctx context.Context, endpoint string) error
+		PendingStreakWarnings(ctx context.Context, kind string, hour, limit int) ([]User, error)
+	}
+	Webhooks interface {
+		Create(ctx context.Context, webhook *Webhook) error
+		GetByID(ctx context.Context, id, userID int64) (*Webhook, error)
+		GetByUser(ctx context.Context, userID int64) ([]Webhook, error)
+		Update(ctx context.Context, webhook *Webhook) error
+		Delete(ctx context.Context, id, userID int64) error
+	}
+	WebhookDeliveries interface {
+		GetByWebhook(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error)
+		Redeliver(ctx context.Context, id, webhookID int64) (*WebhookDelivery, error)
+		ClaimDue(ctx context.Context, limit, maxAttempts int, lease time.Duration) ([]DueWebhookDelivery, error)
+		MarkSucceeded(ctx context.Context, id int64, responseStatus int, responseBody string) error
+		MarkFailed(ctx context.Context, id int64, responseStatus *int, responseBody, sendErr string, retryAt *time.Time) error
+	}
+	Outbox interface {
+		ClaimDue(ctx context.Context, limit, maxAttempts int, lease time.Duration) ([]OutboxMessage, error)
+		MarkSent(ctx context.Context, id int64) error
+		MarkFailed(ctx context.Context, id int64, sendErr string, retryAt *time.Time) error
+	}
+	WeeklyDigests interface {
+		Get(ctx context.Context, userID int64, start time.Time) (*WeeklyDigest, error)
+		PendingRecipients(ctx context.Context, kind string, limit int) ([]User, error)
+	}
+	EmailDeliveries interface {
+		Claim(ctx context.Context, userID int64, kind, period string, lease time.Duration) (bool, error)
+		MarkSent(ctx context.Context, userID int64, kind, period string) error
+		Release(ctx context.Context, userID int64, kind, period string) error
+		PendingUsers(ctx context.Context, kind, period string, limit int) ([]User, error)
+	}
+	GoalCategories interface {
+		Create(ctx context.Context, category *GoalCategory) error
+		GetByID(ctx context.Context, id int64, userID int64) (*GoalCategory, error)
+		GetByName(ctx context.Context, name string, userID int64) (*GoalCategory, error)
+		GetByUser(ctx context.Context, userID int64) ([]GoalCategory, error)
+		Update(ctx context.Context, category *GoalCategory) error
 		Delete(ctx context.Context, id int64, userID int64) error
 	}
+	GoalMilestones interface {
+		Create(ctx context.Context, m *GoalMilestone) error
+		GetByID(ctx context.Context, id, goalID int64) (*GoalMilestone, error)
+		GetByGoal(ctx context.Context, goalID int64) ([]GoalMilestone, error)
+		Update(ctx context.Context, m *GoalMilestone) error
+		Delete(ctx context.Context, id, goalID int64) error
+	}
+	GoalCheckins interface {
+		Create(ctx context.Context, c *GoalCheckin) error
+		GetByGoal(ctx context.Context, goalID int64) ([]GoalCheckin, error)
+	}
+	Tags interface {
+		Create(ctx context.Context, tag *Tag) error
+		GetByID(ctx context.Context, id int64, userID int64) (*Tag, error)
+		GetByUser(ctx context.Context, userID int64) ([]Tag, error)
+		Update(ctx context.Context, tag *Tag) error
+		Delete(ctx context.Context, id int64, userID int64) error
+		SetHabitTags(ctx context.Context, habitID, userID int64, tagIDs []int64) error
+		GetStats(ctx context.Context, userID int64, startDate, endDate time.Time) ([]TagStats, error)
+	}
 	HabitCompletions interface {
-		MarkComplete(ctx context.Context, habitID, userID int64, date time.Time) (*HabitCompletion, error)
-		UnmarkComplete(ctx context.Context, habitID int64, date time.Time) error
-		GetByHabitAndDate(ctx context.Context, habitID int64, date time.Time) (*HabitCompletion, error)
-		GetCompletionsByHabit(ctx context.Context, habitID int64, startDate, endDate time.Time) ([]HabitCompletion, error)
-		GetCompletionsByUser(ctx context.Context, userID int64, startDate, endDate time.Time) ([]HabitCompletion, error)
+		MarkComplete(ctx context.Context, completion *HabitCompletion, event *Event) error
+		UnmarkComplete(ctx context.Context, habitID, userID int64, date time.Time, event *Event) error
+		GetByHabitAndDate(ctx context.Context, habitID, userID int64, date time.Time) (*HabitCompletion, error)
+		GetCompletionsByHabit(ctx context.Context, habitID, userID int64, startDate, endDate time.Time) ([]HabitCompletion, error)
+		GetCompletionsByUser(ctx context.Context, userID int64, startDate, endDate time.Time, tagIDs []int64) ([]HabitCompletion, error)
+		MarkCompleteBatch(ctx context.Context, userID int64, marks []BatchMark) error
+		UnmarkCompleteBatch(ctx context.Context, userID int64, entries []CompletionEntry) ([]bool, error)
+		GetHabitStats(ctx context.Context, habitID, userID int64, startDate, endDate time.Time) (*HabitStats, error)
+		GetHeatmap(ctx context.Context, userID int64, habitID *int64, startDate, endDate time.Time) ([]HeatmapCell, error)
+	}
+	HabitSkips interface {
+		Create(ctx context.Context, habitID, userID int64, date time.Time) (*HabitSkip, error)
+		Delete(ctx context.Context, habitID, userID int64, date time.Time) error
 	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
 		Habits:              &HabitStore{db},
+		HabitGroups:         &HabitGroupStore{db},
 		Users:               &UserStore{db},
 		Goals:               &GoalStore{db},
+		GoalCategories:      &GoalCategoryStore{db},
+		GoalMilestones:      &GoalMilestoneStore{db},
+		GoalCheckins:        &GoalCheckinStore{db},
 		HabitCompletions:    &HabitCompletionStore{db},
+		HabitSkips:          &HabitSkipStore{db},
+		Tags:                &TagStore{db},
 		PasswordResetTokens: &PasswordResetTokenStore{db},
+		YearReviews:         &YearReviewStore{db},
+		Reminders:           &ReminderStore{db},
+		WeeklyDigests:       &WeeklyDigestStore{db},
+		PushSubscriptions:   &PushSubscriptionStore{db},
+		Webhooks:            &WebhookStore{db},
+		WebhookDeliveries:   &WebhookDeliveryStore{db},
+		Outbox:              &OutboxStore{db},
+		EmailDeliveries:     &EmailDeliveryStore{db},
 	}
 }
 
@@ -75,3 +193,14 @@ func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 
 	return tx.Commit()
 }
+
+// withEvent ajaa fn:n transaktiossa ja lisää sen jälkeen tapahtuman webhook-jonoon
+// samassa transaktiossa
+func withEvent(db *sql.DB, ctx context.Context, userID int64, event *Event, fn func(*sql.Tx) error) error {
+	return withTx(db, ctx, func(tx *sql.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, tx, userID, event)
+	})
+}
@@ -0,0 +1,54 @@
+package store
+
+import "time"
+
+// CurrentStreak laskee peräkkäiset suunnitellut päivät, joina tapa on tehty, päättyen
+// päivään asOf. Kesken oleva asOf-päivä ei katkaise putkea. Päivät, joina tapaa ei ole
+// aikataulutettu tai jotka on ohitettu, jätetään väliin. done ja skipped sisältävät
+// päivät muodossa 2006-01-02.
+func CurrentStreak(h *Habit, done, skipped map[string]bool, asOf time.Time) int {
+	streak := 0
+
+	for i := 0; i <= streakLookbackDays; i++ {
+		day := asOf.AddDate(0, 0, -i)
+		if !h.IsDueOn(day) || skipped[day.Format("2006-01-02")] {
+			continue
+		}
+
+		if done[day.Format("2006-01-02")] {
+			streak++
+			continue
+		}
+
+		if i == 0 {
+			// today is still in progress
+			continue
+		}
+
+		break
+	}
+
+	return streak
+}
+
+// LongestStreak laskee pisimmän putken välillä start..end samoilla säännöillä kuin CurrentStreak
+func LongestStreak(h *Habit, done, skipped map[string]bool, start, end time.Time) int {
+	longest, current := 0, 0
+
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		if !h.IsDueOn(day) || skipped[key] {
+			continue
+		}
+
+		if done[key] {
+			current++
+			longest = max(longest, current)
+			continue
+		}
+
+		current = 0
+	}
+
+	return longest
+}
@@ -0,0 +1,292 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateTagName = errors.New("a tag with this name already exists")
+
+type Tag struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	Icon      string    `json:"icon"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TagStats kertoo tagin tapojen toteutumisasteen aikavälillä
+type TagStats struct {
+	TagID          int64   `json:"tag_id"`
+	Name           string  `json:"name"`
+	Color          string  `json:"color"`
+	Icon           string  `json:"icon"`
+	Habits         int     `json:"habits"`
+	Completions    int     `json:"completions"`
+	PossibleDays   int     `json:"possible_days"`
+	CompletionRate float64 `json:"completion_rate"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+func (s *TagStore) Create(ctx context.Context, tag *Tag) error {
+	query := `
+		INSERT INTO tags (user_id, name, color, icon)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, tag.UserID, tag.Name, tag.Color, tag.Icon).Scan(
+		&tag.ID,
+		&tag.CreatedAt,
+		&tag.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "tags_user_id_name_key"`:
+			return ErrDuplicateTagName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *TagStore) GetByID(ctx context.Context, id int64, userID int64) (*Tag, error) {
+	query := `
+		SELECT id, user_id, name, color, icon, created_at, updated_at
+		FROM tags
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tag := &Tag{}
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&tag.ID,
+		&tag.UserID,
+		&tag.Name,
+		&tag.Color,
+		&tag.Icon,
+		&tag.CreatedAt,
+		&tag.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return tag, nil
+}
+
+func (s *TagStore) GetByUser(ctx context.Context, userID int64) ([]Tag, error) {
+	query := `
+		SELECT id, user_id, name, color, icon, created_at, updated_at
+		FROM tags
+		WHERE user_id = $1
+		ORDER BY name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(
+			&tag.ID,
+			&tag.UserID,
+			&tag.Name,
+			&tag.Color,
+			&tag.Icon,
+			&tag.CreatedAt,
+			&tag.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *TagStore) Update(ctx context.Context, tag *Tag) error {
+	query := `
+		UPDATE tags
+		SET name = $1, color = $2, icon = $3, updated_at = NOW()
+		WHERE id = $4 AND user_id = $5
+		RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, tag.Name, tag.Color, tag.Icon, tag.ID, tag.UserID).Scan(&tag.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		case err.Error() == `pq: duplicate key value violates unique constraint "tags_user_id_name_key"`:
+			return ErrDuplicateTagName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *TagStore) Delete(ctx context.Context, id int64, userID int64) error {
+	query := `DELETE FROM tags WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetHabitTags korvaa tavan tagit annetuilla. Kaikkien tagien pitää kuulua käyttäjälle.
+func (s *TagStore) SetHabitTags(ctx context.Context, habitID, userID int64, tagIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var owned int
+		err := tx.QueryRowContext(
+			ctx,
+			`SELECT COUNT(*) FROM tags WHERE user_id = $1 AND id = ANY($2)`,
+			userID,
+			pq.Array(tagIDs),
+		).Scan(&owned)
+		if err != nil {
+			return err
+		}
+		if owned != len(tagIDs) {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM habit_tags WHERE habit_id = $1`, habitID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO habit_tags (habit_id, tag_id)
+			SELECT $1, unnest($2::bigint[])
+		`
+		if _, err := tx.ExecContext(ctx, query, habitID, pq.Array(tagIDs)); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// GetStats laskee jokaiselle käyttäjän tagille toteutumisasteen aikavälillä.
+// Mahdolliset päivät lasketaan tavan luontipäivästä alkaen.
+func (s *TagStore) GetStats(ctx context.Context, userID int64, startDate, endDate time.Time) ([]TagStats, error) {
+	query := `
+		WITH tagged AS (
+			SELECT ht.tag_id, h.id AS habit_id, GREATEST(h.created_at::date, $2::date) AS since
+			FROM habit_tags ht
+			JOIN habits h ON h.id = ht.habit_id
+			WHERE h.user_id = $1
+		), per_habit AS (
+			SELECT tg.tag_id,
+				GREATEST($3::date - tg.since + 1, 0) AS possible,
+				(
+					SELECT COUNT(*)
+					FROM habit_completions c
+					WHERE c.habit_id = tg.habit_id
+					  AND c.completed_date BETWEEN tg.since AND $3::date
+				) AS completed
+			FROM tagged tg
+		)
+		SELECT t.id, t.name, t.color, t.icon,
+			COUNT(p.tag_id),
+			COALESCE(SUM(p.possible), 0),
+			COALESCE(SUM(p.completed), 0)
+		FROM tags t
+		LEFT JOIN per_habit p ON p.tag_id = t.id
+		WHERE t.user_id = $1
+		GROUP BY t.id, t.name, t.color, t.icon
+		ORDER BY t.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []TagStats{}
+	for rows.Next() {
+		var st TagStats
+		if err := rows.Scan(
+			&st.TagID,
+			&st.Name,
+			&st.Color,
+			&st.Icon,
+			&st.Habits,
+			&st.PossibleDays,
+			&st.Completions,
+		); err != nil {
+			return nil, err
+		}
+
+		if st.PossibleDays > 0 {
+			st.CompletionRate = float64(st.Completions) / float64(st.PossibleDays)
+		}
+
+		stats = append(stats, st)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// streakLookbackDays rajaa kuinka pitkältä ajalta merkinnät haetaan putken laskemiseen
+const streakLookbackDays = 366
+
+// TodayHabit on päivänäkymän rivi: tapa, päivän merkintä, putki ja linkitetty tavoite
+type TodayHabit struct {
+	Habit
+	Amount    int     `json:"amount"`
+	Completed bool    `json:"completed"`
+	Skipped   bool    `json:"skipped"`
+	Progress  float64 `json:"progress"`
+	Streak    int     `json:"streak"`
+	Goal      *Goal   `json:"goal"`
+}
+
+// GetDueOn hakee käyttäjän tavat, jotka kuuluu tehdä annettuna päivänä, sekä
+// niiden merkinnät, putket ja tavoitteet kolmella kyselyllä tapojen määrästä riippumatta.
+func (s *HabitStore) GetDueOn(ctx context.Context, userID int64, date time.Time) ([]TodayHabit, error) {
+	query := `
+		SELECT h.id, h.name, h.user_id, h.impact, h.goal_id, h.group_id, h.position,
+			ARRAY(SELECT tag_id FROM habit_tags WHERE habit_id = h.id ORDER BY tag_id),
+			h.schedule_days, h.target, h.created_at, h.updated_at, h.version,
+			COALESCE(c.amount, 0),
+			sk.habit_id IS NOT NULL
+		FROM habits h
+		LEFT JOIN habit_groups g ON g.id = h.group_id
+		LEFT JOIN habit_completions c ON c.habit_id = h.id AND c.completed_date = $2
+		LEFT JOIN habit_skips sk ON sk.habit_id = h.id AND sk.skip_date = $2
+		WHERE h.user_id = $1
+		  AND h.created_at::date <= $2::date
+		  AND (cardinality(h.schedule_days) = 0 OR EXTRACT(ISODOW FROM $2::date)::smallint = ANY(h.schedule_days))
+		ORDER BY g.position NULLS FIRST, g.id, h.position, h.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	day := date.Format("2006-01-02")
+
+	rows, err := s.db.QueryContext(ctx, query, userID, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	today := []TodayHabit{}
+	goalIDs := []int64{}
+	for rows.Next() {
+		var t TodayHabit
+		if err := rows.Scan(
+			&t.ID,
+			&t.Name,
+			&t.UserID,
+			&t.Impact,
+			&t.GoalID,
+			&t.GroupID,
+			&t.Position,
+			pq.Array(&t.TagIDs),
+			pq.Array(&t.ScheduleDays),
+			&t.Target,
+			&t.Created_at,
+			&t.Updated_at,
+			&t.Version,
+			&t.Amount,
+			&t.Skipped,
+		); err != nil {
+			return nil, err
+		}
+
+		t.Completed = t.Amount >= t.Target
+		if t.Target > 0 {
+			t.Progress = min(float64(t.Amount)/float64(t.Target), 1)
+		}
+
+		if t.GoalID != nil {
+			goalIDs = append(goalIDs, *t.GoalID)
+		}
+
+		today = append(today, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(today) == 0 {
+		return today, nil
+	}
+
+	lookback := date.AddDate(0, 0, -streakLookbackDays)
+
+	done, err := s.completedDays(ctx, userID, lookback, date)
+	if err != nil {
+		return nil, err
+	}
+
+	skipped, err := s.skippedDays(ctx, userID, lookback, date)
+	if err != nil {
+		return nil, err
+	}
+
+	goals, err := s.goalsByID(ctx, userID, goalIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range today {
+		t := &today[i]
+		t.Streak = CurrentStreak(&t.Habit, done[t.ID], skipped[t.ID], date)
+		if t.GoalID != nil {
+			t.Goal = goals[*t.GoalID]
+		}
+	}
+
+	return today, nil
+}
+
+// completedDays palauttaa tavoittain ne päivät, joina tavoitemäärä on täyttynyt
+func (s *HabitStore) completedDays(ctx context.Context, userID int64, startDate, endDate time.Time) (map[int64]map[string]bool, error) {
+	query := `
+		SELECT c.habit_id, c.completed_date
+		FROM habit_completions c
+		JOIN habits h ON h.id = c.habit_id
+		WHERE c.user_id = $1
+		  AND c.completed_date BETWEEN $2 AND $3
+		  AND c.amount >= h.target
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int64]map[string]bool{}
+	for rows.Next() {
+		var habitID int64
+		var completedDate time.Time
+		if err := rows.Scan(&habitID, &completedDate); err != nil {
+			return nil, err
+		}
+
+		if done[habitID] == nil {
+			done[habitID] = map[string]bool{}
+		}
+		done[habitID][completedDate.Format("2006-01-02")] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return done, nil
+}
+
+// skippedDays palauttaa tavoittain ohitetut päivät
+func (s *HabitStore) skippedDays(ctx context.Context, userID int64, startDate, endDate time.Time) (map[int64]map[string]bool, error) {
+	query := `
+		SELECT habit_id, skip_date
+		FROM habit_skips
+		WHERE user_id = $1 AND skip_date BETWEEN $2 AND $3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skipped := map[int64]map[string]bool{}
+	for rows.Next() {
+		var habitID int64
+		var skipDate time.Time
+		if err := rows.Scan(&habitID, &skipDate); err != nil {
+			return nil, err
+		}
+
+		if skipped[habitID] == nil {
+			skipped[habitID] = map[string]bool{}
+		}
+		skipped[habitID][skipDate.Format("2006-01-02")] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return skipped, nil
+}
+
+func (s *HabitStore) goalsByID(ctx context.Context, userID int64, ids []int64) (map[int64]*Goal, error) {
+	goals := map[int64]*Goal{}
+	if len(ids) == 0 {
+		return goals, nil
+	}
+
+	query := `
+		SELECT g.id, g.user_id, g.year, g.category_id, c.name, g.description, g.completed, g.target_quantity,
+			g.start_value, g.target_value, ` + goalCurrentValue + `, g.unit, g.deadline,
+			g.outcome, g.reflection, g.reviewed_at, g.rolled_over_from, g.created_at, g.updated_at
+		FROM goals g
+		JOIN goal_categories c ON c.id = g.category_id
+		WHERE g.user_id = $1 AND g.id = ANY($2)
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		goal := &Goal{}
+		if err := rows.Scan(
+			&goal.ID,
+			&goal.UserID,
+			&goal.Year,
+			&goal.CategoryID,
+			&goal.Category,
+			&goal.Description,
+			&goal.Completed,
+			&goal.TargetQuantity,
+			&goal.StartValue,
+			&goal.TargetValue,
+			&goal.CurrentValue,
+			&goal.Unit,
+			&goal.Deadline,
+			&goal.Outcome,
+			&goal.Reflection,
+			&goal.ReviewedAt,
+			&goal.RolledOverFrom,
+			&goal.CreatedAt,
+			&goal.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		goals[goal.ID] = goal
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return goals, nil
+}
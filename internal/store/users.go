@@ -14,6 +14,7 @@ import (
 var (
 	ErrDuplicateEmail    = errors.New("a user with this email already exists")
 	ErrDuplicateUsername = errors.New("a user with this username already exists")
+	ErrInvalidTimezone   = errors.New("invalid timezone")
 )
 
 type User struct {
@@ -24,6 +25,8 @@ type User struct {
 	Password  password `json:"_"`
 	CreatedAt string   `json:"created_at"`
 	IsActive  bool     `json:"is_active"`
+	Timezone  string   `json:"timezone"`
+	Locale    string   `json:"locale"`
 }
 
 type password struct {
@@ -31,6 +34,15 @@ type password struct {
 	hash []byte
 }
 
+// Location palauttaa käyttäjän aikavyöhykkeen, virheellisellä arvolla UTC:n
+func (u *User) Location() *time.Location {
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil || u.Timezone == "" {
+		return time.UTC
+	}
+	return loc
+}
+
 func (p *password) Set(text string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(text), bcrypt.DefaultCost)
 	if err != nil {
@@ -58,7 +70,7 @@ type UserStore struct {
 
 func (s *UserStore) GetByPublicID(ctx context.Context, publicID string) (*User, error) {
 	query := `
-		SELECT id, public_id, username, email, password, created_at, is_active
+		SELECT id, public_id, username, email, password, created_at, is_active, timezone, locale
 		FROM users
 		WHERE public_id = $1 AND is_active = true
 	`
@@ -75,6 +87,8 @@ func (s *UserStore) GetByPublicID(ctx context.Context, publicID string) (*User,
 		&user.Password.hash,
 		&user.CreatedAt,
 		&user.IsActive,
+		&user.Timezone,
+		&user.Locale,
 	)
 	if err != nil {
 		switch {
@@ -90,15 +104,15 @@ func (s *UserStore) GetByPublicID(ctx context.Context, publicID string) (*User,
 
 func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
-		INSERT INTO users (username, password, email)
-		VALUES ($1, $2, $3)
+		INSERT INTO users (username, password, email, locale)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id, public_id, created_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := tx.QueryRowContext(ctx, query, user.Username, user.Password.hash, user.Email).Scan(&user.ID, &user.PublicID, &user.CreatedAt)
+	err := tx.QueryRowContext(ctx, query, user.Username, user.Password.hash, user.Email, user.Locale).Scan(&user.ID, &user.PublicID, &user.CreatedAt)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -115,7 +129,7 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 
 func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	query := `
-		SELECT id, public_id, username, email, password, created_at, is_active
+		SELECT id, public_id, username, email, password, created_at, is_active, timezone, locale
 		FROM users
 		WHERE id = $1
 	`
@@ -133,6 +147,8 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 		&user.Password.hash,
 		&user.CreatedAt,
 		&user.IsActive,
+		&user.Timezone,
+		&user.Locale,
 	)
 	if err != nil {
 		switch {
@@ -146,7 +162,8 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	return user, nil
 }
 
-func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+// CreateAndInvite luo käyttäjän ja kutsun sekä jonottaa tervetuloviestin samassa transaktiossa
+func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration, welcome *OutboxEmail) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		// create the user
 		if err := s.Create(ctx, tx, user); err != nil {
@@ -158,6 +175,18 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token strin
 			return err
 		}
 
+		// seed the default goal categories
+		if err := seedGoalCategories(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		// queue the welcome mail; it's sent by the outbox dispatcher
+		if welcome != nil {
+			if err := enqueueOutbox(ctx, tx, OutboxTopicEmail, welcome); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 }
@@ -184,7 +213,7 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 
 func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
 	query := `
-		SELECT u.id, u.public_id, u.username, u.email, u.created_at, u.is_active
+		SELECT u.id, u.public_id, u.username, u.email, u.created_at, u.is_active, u.timezone, u.locale
 		FROM users u
 		JOIN user_invitations ui ON u.id = ui.user_id
 		WHERE ui.token = $1 AND ui.expiry > $2
@@ -204,6 +233,8 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 		&user.Email,
 		&user.CreatedAt,
 		&user.IsActive,
+		&user.Timezone,
+		&user.Locale,
 	)
 	if err != nil {
 		switch {
@@ -289,7 +320,7 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, public_id, username, email, password, created_at, is_active
+		SELECT id, public_id, username, email, password, created_at, is_active, timezone, locale
 		FROM users
 		WHERE email = $1 AND is_active = true
 	`
@@ -306,6 +337,8 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Password.hash,
 		&user.CreatedAt,
 		&user.IsActive,
+		&user.Timezone,
+		&user.Locale,
 	)
 	if err != nil {
 		switch err {
@@ -320,7 +353,7 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 }
 
 func (s *UserStore) UpdateEmail(ctx context.Context, userID int64, email string) (*User, error) {
-	query := `UPDATE users SET email = $1 WHERE id = $2 RETURNING id, public_id, username, email, password, created_at, is_active`
+	query := `UPDATE users SET email = $1 WHERE id = $2 RETURNING id, public_id, username, email, password, created_at, is_active, timezone, locale`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -334,6 +367,8 @@ func (s *UserStore) UpdateEmail(ctx context.Context, userID int64, email string)
 		&user.Password.hash,
 		&user.CreatedAt,
 		&user.IsActive,
+		&user.Timezone,
+		&user.Locale,
 	)
 	if err != nil {
 		switch {
@@ -370,3 +405,35 @@ func (s *UserStore) UpdatePassword(ctx context.Context, userID int64, passwordHa
 
 	return nil
 }
+
+func (s *UserStore) UpdateTimezone(ctx context.Context, userID int64, timezone string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	// Go:n aikavyöhyketiedot voivat tuntea nimiä, joita Postgres ei tunne
+	var known bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = $1)`, timezone).Scan(&known)
+	if err != nil {
+		return err
+	}
+	if !known {
+		return ErrInvalidTimezone
+	}
+
+	query := `UPDATE users SET timezone = $1 WHERE id = $2`
+
+	res, err := s.db.ExecContext(ctx, query, timezone, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
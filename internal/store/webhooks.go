@@ -0,0 +1,463 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebhookEvents ovat tapahtumat, joita webhookit voivat tilata
+var WebhookEvents = []string{
+	"habit.created",
+	"habit.updated",
+	"habit.deleted",
+	"habit.completed",
+	"habit.uncompleted",
+	"goal.created",
+	"goal.completed",
+	"goal.deleted",
+}
+
+// Webhook on käyttäjän määrittämä osoite, johon tapahtumat lähetetään.
+// Salaisuus palautetaan vain luotaessa.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"-"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery on yksi lähetys lokissa
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"` // pending | sending | succeeded | failed
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	ResponseBody   string          `json:"response_body"`
+	LastError      string          `json:"last_error"`
+	RedeliveryOf   *int64          `json:"redelivery_of"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// DueWebhookDelivery on lähetettäväksi varattu toimitus kohdeosoitteineen
+type DueWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+type WebhookStore struct {
+	db *sql.DB
+}
+
+func (s *WebhookStore) Create(ctx context.Context, webhook *Webhook) error {
+	query := `
+		INSERT INTO webhooks (user_id, url, secret, events, description, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		webhook.UserID,
+		webhook.URL,
+		webhook.Secret,
+		pq.Array(webhook.Events),
+		webhook.Description,
+		webhook.Active,
+	).Scan(
+		&webhook.ID,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+}
+
+func (s *WebhookStore) GetByID(ctx context.Context, id, userID int64) (*Webhook, error) {
+	query := `
+		SELECT id, user_id, url, events, description, active, created_at, updated_at
+		FROM webhooks
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	webhook := &Webhook{}
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		pq.Array(&webhook.Events),
+		&webhook.Description,
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return webhook, nil
+}
+
+func (s *WebhookStore) GetByUser(ctx context.Context, userID int64) ([]Webhook, error) {
+	query := `
+		SELECT id, user_id, url, events, description, active, created_at, updated_at
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(
+			&webhook.ID,
+			&webhook.UserID,
+			&webhook.URL,
+			pq.Array(&webhook.Events),
+			&webhook.Description,
+			&webhook.Active,
+			&webhook.CreatedAt,
+			&webhook.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (s *WebhookStore) Update(ctx context.Context, webhook *Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = $1, events = $2, description = $3, active = $4, updated_at = NOW()
+		WHERE id = $5 AND user_id = $6
+		RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		webhook.URL,
+		pq.Array(webhook.Events),
+		webhook.Description,
+		webhook.Active,
+		webhook.ID,
+		webhook.UserID,
+	).Scan(&webhook.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *WebhookStore) Delete(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Event on webhookeille lähetettävä tapahtuma. Muutoksen tallentavat metodit lisäävät
+// sen jonoon samassa transaktiossa kuin muutoksen, joten tapahtuma ei katoa eikä synny
+// ilman muutosta. Data serialisoidaan vasta kirjoituksen jälkeen, joten osoittimen
+// takana näkyvät myös kannan asettamat kentät (id, created_at).
+type Event struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// NewEvent luo tapahtuman. Sen JSON on vastaanottajien saama runko.
+func NewEvent(event string, data any) *Event {
+	return &Event{
+		ID:        uuid.NewString(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// enqueueEvent lisää tapahtuman jonoon jokaiselle käyttäjän aktiiviselle webhookille,
+// joka on tilannut sen. nil-tapahtuma ei tee mitään.
+func enqueueEvent(ctx context.Context, tx *sql.Tx, userID int64, event *Event) error {
+	if event == nil {
+		return nil
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		SELECT id, $2, $3, $4
+		FROM webhooks
+		WHERE user_id = $1
+		  AND active
+		  AND (cardinality(events) = 0 OR $3 = ANY(events))
+	`
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query, userID, event.ID, event.Event, string(payload))
+	return err
+}
+
+type WebhookDeliveryStore struct {
+	db *sql.DB
+}
+
+const webhookDeliveryColumns = `
+	d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.response_status, d.response_body, d.last_error, d.redelivery_of, d.created_at, d.delivered_at
+`
+
+func scanWebhookDelivery(row interface{ Scan(...any) error }, d *WebhookDelivery, extra ...any) error {
+	var payload []byte
+	dest := []any{
+		&d.ID,
+		&d.WebhookID,
+		&d.EventID,
+		&d.Event,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.ResponseStatus,
+		&d.ResponseBody,
+		&d.LastError,
+		&d.RedeliveryOf,
+		&d.CreatedAt,
+		&d.DeliveredAt,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	d.Payload = payload
+	return nil
+}
+
+// GetByWebhook palauttaa webhookin uusimmat toimitukset
+func (s *WebhookDeliveryStore) GetByWebhook(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := scanWebhookDelivery(rows, &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver lisää jonoon uuden toimituksen aiemman toimituksen tapahtumasta.
+// Alkuperäinen rivi jää lokiin ennalleen.
+func (s *WebhookDeliveryStore) Redeliver(ctx context.Context, id, webhookID int64) (*WebhookDelivery, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, redelivery_of)
+		SELECT webhook_id, event_id, event, payload, id
+		FROM webhook_deliveries
+		WHERE id = $1 AND webhook_id = $2
+		RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var newID int64
+	if err := s.db.QueryRowContext(ctx, query, id, webhookID).Scan(&newID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	d := &WebhookDelivery{}
+	row := s.db.QueryRowContext(ctx, `SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d WHERE d.id = $1`, newID)
+	if err := scanWebhookDelivery(row, d); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// ClaimDue varaa enintään limit erääntynyttä toimitusta lease-ajaksi. Vanhentuneet
+// varaukset (kaatunut työntekijä) otetaan uudelleen käsittelyyn, ellei yrityksiä ole
+// jo maxAttempts verran; ne merkitään epäonnistuneiksi.
+func (s *WebhookDeliveryStore) ClaimDue(ctx context.Context, limit, maxAttempts int, lease time.Duration) ([]DueWebhookDelivery, error) {
+	expire := `
+		UPDATE webhook_deliveries
+		SET status = 'failed', claimed_until = NULL, last_error = 'lease expired on the last attempt'
+		WHERE status = 'sending' AND claimed_until < NOW() AND attempts >= $1
+	`
+
+	query := `
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE (status = 'pending' AND next_attempt_at <= NOW())
+			   OR (status = 'sending' AND claimed_until < NOW() AND attempts < $3)
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET status = 'sending', attempts = d.attempts + 1, claimed_until = NOW() + $2 * interval '1 second'
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING ` + webhookDeliveryColumns + `, w.url, w.secret
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, expire, maxAttempts); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, limit, int(lease.Seconds()), maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := []DueWebhookDelivery{}
+	for rows.Next() {
+		var d DueWebhookDelivery
+		if err := scanWebhookDelivery(rows, &d.WebhookDelivery, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return due, nil
+}
+
+func (s *WebhookDeliveryStore) MarkSucceeded(ctx context.Context, id int64, responseStatus int, responseBody string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'succeeded', response_status = $2, response_body = $3, last_error = '',
+			claimed_until = NULL, delivered_at = NOW()
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, responseStatus, responseBody)
+	return err
+}
+
+// MarkFailed ajastaa uuden yrityksen hetkeen retryAt, tai merkitsee toimituksen
+// lopullisesti epäonnistuneeksi kun retryAt on nil
+func (s *WebhookDeliveryStore) MarkFailed(ctx context.Context, id int64, responseStatus *int, responseBody, sendErr string, retryAt *time.Time) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = CASE WHEN $5::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = COALESCE($5, next_attempt_at),
+			response_status = $2, response_body = $3, last_error = $4, claimed_until = NULL
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, responseStatus, responseBody, sendErr, retryAt)
+	return err
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WeeklyDigest on viikkokoosteen sisältö maanantaista sunnuntaihin
+type WeeklyDigest struct {
+	Start            time.Time           `json:"start"`
+	End              time.Time           `json:"end"`
+	TotalCompletions int                 `json:"total_completions"`
+	PossibleDays     int                 `json:"possible_days"`
+	CompletedDays    int                 `json:"completed_days"`
+	CompletionRate   float64             `json:"completion_rate"`
+	Habits           []HabitWeekSummary  `json:"habits"`
+	Goals            []GoalDigestSummary `json:"goals"`
+}
+
+type HabitWeekSummary struct {
+	HabitID       int64  `json:"habit_id"`
+	Name          string `json:"name"`
+	PossibleDays  int    `json:"possible_days"`
+	CompletedDays int    `json:"completed_days"`
+	MissedDays    int    `json:"missed_days"`
+	Streak        int    `json:"streak"` // putki viikon viimeisenä päivänä
+}
+
+type GoalDigestSummary struct {
+	GoalID      int64         `json:"goal_id"`
+	Description string        `json:"description"`
+	Category    string        `json:"category"`
+	Completed   *bool         `json:"completed"`
+	Progress    *GoalProgress `json:"progress"`
+}
+
+type WeeklyDigestStore struct {
+	db *sql.DB
+}
+
+// Get kokoaa viikkokoosteen viikolle, joka alkaa maanantaina start
+func (s *WeeklyDigestStore) Get(ctx context.Context, userID int64, start time.Time) (*WeeklyDigest, error) {
+	end := start.AddDate(0, 0, 6)
+
+	digest := &WeeklyDigest{
+		Start:  start,
+		End:    end,
+		Habits: []HabitWeekSummary{},
+		Goals:  []GoalDigestSummary{},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM habit_completions
+		WHERE user_id = $1 AND completed_date BETWEEN $2 AND $3
+	`, userID, start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&digest.TotalCompletions); err != nil {
+		return nil, err
+	}
+
+	if err := s.habitSummaries(ctx, userID, digest); err != nil {
+		return nil, err
+	}
+
+	if err := s.goalSummaries(ctx, userID, digest); err != nil {
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+func (s *WeeklyDigestStore) habitSummaries(ctx context.Context, userID int64, digest *WeeklyDigest) error {
+	query := yearPossibleDays + `
+		SELECT h.id, h.name, h.schedule_days, COUNT(p.day), COUNT(c.id)
+		FROM h
+		LEFT JOIN possible p ON p.habit_id = h.id
+		LEFT JOIN habit_completions c
+			ON c.habit_id = h.id AND c.completed_date = p.day AND c.amount >= h.target
+		WHERE h.created <= $3::date
+		GROUP BY h.id, h.name, h.schedule_days
+		ORDER BY h.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, digest.Start.Format("2006-01-02"), digest.End.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	habits := map[int64]*Habit{}
+	for rows.Next() {
+		var summary HabitWeekSummary
+		var h Habit
+		if err := rows.Scan(
+			&summary.HabitID,
+			&summary.Name,
+			pq.Array(&h.ScheduleDays),
+			&summary.PossibleDays,
+			&summary.CompletedDays,
+		); err != nil {
+			return err
+		}
+		summary.MissedDays = summary.PossibleDays - summary.CompletedDays
+
+		habits[summary.HabitID] = &h
+		digest.Habits = append(digest.Habits, summary)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(digest.Habits) == 0 {
+		return nil
+	}
+
+	hs := &HabitStore{s.db}
+	lookback := digest.End.AddDate(0, 0, -streakLookbackDays)
+
+	done, err := hs.completedDays(ctx, userID, lookback, digest.End)
+	if err != nil {
+		return err
+	}
+
+	skipped, err := hs.skippedDays(ctx, userID, lookback, digest.End)
+	if err != nil {
+		return err
+	}
+
+	for i := range digest.Habits {
+		summary := &digest.Habits[i]
+		summary.Streak = CurrentStreak(habits[summary.HabitID], done[summary.HabitID], skipped[summary.HabitID], digest.End)
+
+		digest.PossibleDays += summary.PossibleDays
+		digest.CompletedDays += summary.CompletedDays
+	}
+	digest.CompletionRate = rate(digest.CompletedDays, digest.PossibleDays)
+
+	// Pisimmät putket ensin
+	sort.SliceStable(digest.Habits, func(i, j int) bool {
+		return digest.Habits[i].Streak > digest.Habits[j].Streak
+	})
+
+	return nil
+}
+
+// goalSummaries hakee viikon vuoden tavoitteet edistymisineen viikon viimeisenä päivänä
+func (s *WeeklyDigestStore) goalSummaries(ctx context.Context, userID int64, digest *WeeklyDigest) error {
+	gs := &GoalStore{s.db}
+
+	goals, err := gs.GetByUserAndYear(ctx, userID, digest.End.Year())
+	if err != nil {
+		return err
+	}
+
+	if len(goals) == 0 {
+		return nil
+	}
+
+	goalIDs := make([]int64, len(goals))
+	for i := range goals {
+		goalIDs[i] = goals[i].ID
+	}
+
+	progress, err := gs.GetProgress(ctx, userID, goalIDs, digest.End)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range goals {
+		digest.Goals = append(digest.Goals, GoalDigestSummary{
+			GoalID:      g.ID,
+			Description: g.Description,
+			Category:    g.Category,
+			Completed:   g.Completed,
+			Progress:    progress[g.ID],
+		})
+	}
+
+	return nil
+}
+
+// PendingRecipients palauttaa viikkokoosteen tilanneet käyttäjät, joiden paikallinen
+// viikonpäivä on valittu lähetyspäivä ja joille edellisen viikon koostetta ei ole vielä
+// lähetetty. Jakso on edellisen viikon ISO-viikko muodossa 2006-W01.
+func (s *WeeklyDigestStore) PendingRecipients(ctx context.Context, kind string, limit int) ([]User, error) {
+	query := `
+		SELECT u.id, u.public_id, u.username, u.email, u.created_at, u.is_active, u.timezone, u.locale
+		FROM users u
+		WHERE u.is_active
+		  AND u.weekly_digest
+		  AND EXTRACT(ISODOW FROM NOW() AT TIME ZONE u.timezone)::smallint = u.digest_day
+		  AND EXISTS (SELECT 1 FROM habits h WHERE h.user_id = u.id)
+		  AND NOT EXISTS (
+			SELECT 1 FROM email_deliveries d
+			WHERE d.user_id = u.id
+			  AND d.kind = $1
+			  AND d.period = to_char(date_trunc('week', NOW() AT TIME ZONE u.timezone) - interval '1 week', 'IYYY-"W"IW')
+			  AND (d.sent_at IS NOT NULL OR d.claimed_until > NOW())
+		  )
+		ORDER BY u.id
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(
+			&user.ID,
+			&user.PublicID,
+			&user.Username,
+			&user.Email,
+			&user.CreatedAt,
+			&user.IsActive,
+			&user.Timezone,
+			&user.Locale,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
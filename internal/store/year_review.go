@@ -0,0 +1,316 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// yearReviewTopN rajaa parhaiden putkien ja tasaisimpien tapojen listojen pituuden
+const yearReviewTopN = 3
+
+// yearReviewMinPossibleDays suodattaa tasaisimmista tavoista ne, joita ehdittiin tehdä vain vähän
+const yearReviewMinPossibleDays = 14
+
+// YearReview on käyttäjän vuosikatsaus
+type YearReview struct {
+	Year             int                   `json:"year"`
+	Start            time.Time             `json:"start"`
+	End              time.Time             `json:"end"`
+	TotalCompletions int                   `json:"total_completions"`
+	ActiveDays       int                   `json:"active_days"`
+	CompletionRate   float64               `json:"completion_rate"`
+	Habits           []HabitYearSummary    `json:"habits"`
+	BestStreaks      []HabitYearSummary    `json:"best_streaks"`
+	MostConsistent   []HabitYearSummary    `json:"most_consistent"`
+	Months           []MonthSummary        `json:"months"`
+	GoalsByCategory  []CategoryGoalSummary `json:"goals_by_category"`
+	Milestones       []ReviewMilestone     `json:"milestones"`
+}
+
+type HabitYearSummary struct {
+	HabitID        int64   `json:"habit_id"`
+	Name           string  `json:"name"`
+	PossibleDays   int     `json:"possible_days"`
+	CompletedDays  int     `json:"completed_days"`
+	CompletionRate float64 `json:"completion_rate"`
+	LongestStreak  int     `json:"longest_streak"`
+}
+
+type MonthSummary struct {
+	Month          int     `json:"month"`
+	PossibleDays   int     `json:"possible_days"`
+	CompletedDays  int     `json:"completed_days"`
+	CompletionRate float64 `json:"completion_rate"`
+}
+
+type CategoryGoalSummary struct {
+	Category string `json:"category"`
+	Total    int    `json:"total"`
+	Achieved int    `json:"achieved"`
+}
+
+type ReviewMilestone struct {
+	Title       string    `json:"title"`
+	Goal        string    `json:"goal"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+type YearReviewStore struct {
+	db *sql.DB
+}
+
+// yearPossibleDays listaa jokaisen tavan suunnitellut päivät välillä $2..$3
+const yearPossibleDays = `
+	WITH h AS (
+		SELECT id, name, target, schedule_days, created_at::date AS created
+		FROM habits
+		WHERE user_id = $1
+	), possible AS (
+		SELECT h.id AS habit_id, d::date AS day
+		FROM h
+		CROSS JOIN LATERAL generate_series(GREATEST($2::date, h.created), $3::date, interval '1 day') AS d
+		WHERE (cardinality(h.schedule_days) = 0 OR EXTRACT(ISODOW FROM d::date)::smallint = ANY(h.schedule_days))
+		  AND NOT EXISTS (SELECT 1 FROM habit_skips s WHERE s.habit_id = h.id AND s.skip_date = d::date)
+	)
+`
+
+// Get kokoaa vuosikatsauksen. Kuluvalta vuodelta lasketaan päivään asOf asti.
+func (s *YearReviewStore) Get(ctx context.Context, userID int64, year int, asOf time.Time) (*YearReview, error) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if y, m, d := asOf.Date(); end.After(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)) {
+		end = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	review := &YearReview{
+		Year:            year,
+		Start:           start,
+		End:             end,
+		Habits:          []HabitYearSummary{},
+		BestStreaks:     []HabitYearSummary{},
+		MostConsistent:  []HabitYearSummary{},
+		Months:          []MonthSummary{},
+		GoalsByCategory: []CategoryGoalSummary{},
+		Milestones:      []ReviewMilestone{},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT completed_date)
+		FROM habit_completions
+		WHERE user_id = $1 AND completed_date BETWEEN $2 AND $3
+	`, userID, start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&review.TotalCompletions, &review.ActiveDays); err != nil {
+		return nil, err
+	}
+
+	// Vuosi ei ole vielä alkanut
+	if end.Before(start) {
+		return review, nil
+	}
+
+	if err := s.habitSummaries(ctx, userID, review); err != nil {
+		return nil, err
+	}
+
+	if err := s.monthSummaries(ctx, userID, review); err != nil {
+		return nil, err
+	}
+
+	if err := s.goalSummaries(ctx, userID, review); err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+func (s *YearReviewStore) habitSummaries(ctx context.Context, userID int64, review *YearReview) error {
+	query := yearPossibleDays + `
+		SELECT h.id, h.name, h.schedule_days, h.created, COUNT(p.day), COUNT(c.id)
+		FROM h
+		LEFT JOIN possible p ON p.habit_id = h.id
+		LEFT JOIN habit_completions c
+			ON c.habit_id = h.id AND c.completed_date = p.day AND c.amount >= h.target
+		GROUP BY h.id, h.name, h.schedule_days, h.created
+		ORDER BY h.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, review.Start.Format("2006-01-02"), review.End.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	habits := map[int64]*Habit{}
+	created := map[int64]time.Time{}
+	for rows.Next() {
+		var summary HabitYearSummary
+		var h Habit
+		var createdOn time.Time
+		if err := rows.Scan(
+			&summary.HabitID,
+			&summary.Name,
+			pq.Array(&h.ScheduleDays),
+			&createdOn,
+			&summary.PossibleDays,
+			&summary.CompletedDays,
+		); err != nil {
+			return err
+		}
+		summary.CompletionRate = rate(summary.CompletedDays, summary.PossibleDays)
+
+		habits[summary.HabitID] = &h
+		created[summary.HabitID] = createdOn
+		review.Habits = append(review.Habits, summary)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(review.Habits) == 0 {
+		return nil
+	}
+
+	hs := &HabitStore{s.db}
+
+	done, err := hs.completedDays(ctx, userID, review.Start, review.End)
+	if err != nil {
+		return err
+	}
+
+	skipped, err := hs.skippedDays(ctx, userID, review.Start, review.End)
+	if err != nil {
+		return err
+	}
+
+	var possible, completed int
+	for i := range review.Habits {
+		summary := &review.Habits[i]
+		from := review.Start
+		if created[summary.HabitID].After(from) {
+			from = created[summary.HabitID]
+		}
+		summary.LongestStreak = LongestStreak(habits[summary.HabitID], done[summary.HabitID], skipped[summary.HabitID], from, review.End)
+
+		possible += summary.PossibleDays
+		completed += summary.CompletedDays
+	}
+	review.CompletionRate = rate(completed, possible)
+
+	byStreak := append([]HabitYearSummary(nil), review.Habits...)
+	sort.SliceStable(byStreak, func(i, j int) bool {
+		return byStreak[i].LongestStreak > byStreak[j].LongestStreak
+	})
+	for _, summary := range byStreak {
+		if len(review.BestStreaks) == yearReviewTopN || summary.LongestStreak == 0 {
+			break
+		}
+		review.BestStreaks = append(review.BestStreaks, summary)
+	}
+
+	byRate := append([]HabitYearSummary(nil), review.Habits...)
+	sort.SliceStable(byRate, func(i, j int) bool {
+		return byRate[i].CompletionRate > byRate[j].CompletionRate
+	})
+	for _, summary := range byRate {
+		if len(review.MostConsistent) == yearReviewTopN {
+			break
+		}
+		if summary.PossibleDays < yearReviewMinPossibleDays || summary.CompletedDays == 0 {
+			continue
+		}
+		review.MostConsistent = append(review.MostConsistent, summary)
+	}
+
+	return nil
+}
+
+func (s *YearReviewStore) monthSummaries(ctx context.Context, userID int64, review *YearReview) error {
+	query := yearPossibleDays + `
+		SELECT EXTRACT(MONTH FROM p.day)::int AS month, COUNT(*), COUNT(c.id)
+		FROM possible p
+		JOIN h ON h.id = p.habit_id
+		LEFT JOIN habit_completions c
+			ON c.habit_id = h.id AND c.completed_date = p.day AND c.amount >= h.target
+		GROUP BY month
+		ORDER BY month
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, review.Start.Format("2006-01-02"), review.End.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m MonthSummary
+		if err := rows.Scan(&m.Month, &m.PossibleDays, &m.CompletedDays); err != nil {
+			return err
+		}
+		m.CompletionRate = rate(m.CompletedDays, m.PossibleDays)
+		review.Months = append(review.Months, m)
+	}
+
+	return rows.Err()
+}
+
+func (s *YearReviewStore) goalSummaries(ctx context.Context, userID int64, review *YearReview) error {
+	query := `
+		SELECT c.name, COUNT(*), COUNT(*) FILTER (WHERE g.completed IS TRUE)
+		FROM goals g
+		JOIN goal_categories c ON c.id = g.category_id
+		WHERE g.user_id = $1 AND g.year = $2
+		GROUP BY c.id, c.name, c.position
+		ORDER BY c.position, c.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, review.Year)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c CategoryGoalSummary
+		if err := rows.Scan(&c.Category, &c.Total, &c.Achieved); err != nil {
+			return err
+		}
+		review.GoalsByCategory = append(review.GoalsByCategory, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	milestoneQuery := `
+		SELECT m.title, g.description, m.completed_at
+		FROM goal_milestones m
+		JOIN goals g ON g.id = m.goal_id
+		WHERE g.user_id = $1
+		  AND m.completed_at >= make_date($2, 1, 1)
+		  AND m.completed_at < make_date($2 + 1, 1, 1)
+		ORDER BY m.completed_at
+	`
+
+	rows, err = s.db.QueryContext(ctx, milestoneQuery, userID, review.Year)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m ReviewMilestone
+		if err := rows.Scan(&m.Title, &m.Goal, &m.CompletedAt); err != nil {
+			return err
+		}
+		review.Milestones = append(review.Milestones, m)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,181 @@
+// Package webhook signs and delivers webhook events over HTTP.
+//
+// Every request carries the event name, the delivery ID and a signature header:
+//
+//	Habits-Signature: t=1700000000,v1=<hex HMAC-SHA256 of "1700000000.<body>">
+//
+// Receivers recompute the HMAC with the webhook's secret and reject stale
+// timestamps to prevent replays (see Verify).
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"juhojarvi/habits/internal/netguard"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "Habits-Signature"
+	EventHeader     = "Habits-Event"
+	DeliveryHeader  = "Habits-Delivery"
+	// maxResponseBody limits how much of the receiver's response is kept in the delivery log
+	maxResponseBody = 2048
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleSignature   = errors.New("webhook signature timestamp outside tolerance")
+)
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+// Verify checks a signature header against body. Signatures older or newer than
+// tolerance relative to now are rejected.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrStaleSignature
+	}
+
+	expected := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+func mac(secret, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Request is one delivery attempt.
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID int64
+	Body       []byte
+}
+
+// Result describes the receiver's answer. Status is 0 when no response was received.
+type Result struct {
+	Status int
+	Body   string
+}
+
+// Sender posts signed events.
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender returns a Sender that only connects to the addresses guard allows,
+// so a webhook can't be pointed at the server's internal network.
+func NewSender(timeout time.Duration, guard *netguard.Guard) *Sender {
+	return &Sender{client: &http.Client{
+		Timeout:   timeout,
+		Transport: guard.Transport(),
+		// Redirects are not followed so a receiver can't bounce events elsewhere
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Send delivers req. Any 2xx response is a success; other statuses and
+// transport errors are returned as errors along with whatever was received.
+func (s *Sender) Send(ctx context.Context, req Request) (Result, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return Result{}, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "Habits-Webhooks/1.0")
+	httpReq.Header.Set(EventHeader, req.Event)
+	httpReq.Header.Set(DeliveryHeader, strconv.FormatInt(req.DeliveryID, 10))
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, time.Now(), req.Body))
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	result := Result{Status: resp.StatusCode, Body: cleanBody(body)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+
+	return result, nil
+}
+
+// cleanBody makes a response body storable in a Postgres text column, which
+// rejects invalid UTF-8 and NUL bytes.
+func cleanBody(b []byte) string {
+	return strings.ToValidUTF8(strings.ReplaceAll(string(b), "\x00", ""), "\uFFFD")
+}
+
+// Backoff returns the delay before retry number attempt (1-based):
+// base, 2*base, 4*base, ... capped at maxDelay.
+func Backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxDelay {
+			return maxDelay
+		}
+	}
+	return min(d, maxDelay)
+}
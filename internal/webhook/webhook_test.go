@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"juhojarvi/habits/internal/netguard"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"habit.completed"}`)
+	now := time.Unix(1_700_000_000, 0)
+
+	header := Sign("secret", now, body)
+
+	if err := Verify("secret", header, body, 5*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if err := Verify("other", header, body, 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Fatalf("wrong secret: want ErrInvalidSignature got %v", err)
+	}
+
+	if err := Verify("secret", header, []byte(`{"event":"goal.completed"}`), 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Fatalf("tampered body: want ErrInvalidSignature got %v", err)
+	}
+
+	if err := Verify("secret", header, body, 5*time.Minute, now.Add(time.Hour)); err != ErrStaleSignature {
+		t.Fatalf("replay: want ErrStaleSignature got %v", err)
+	}
+
+	if err := Verify("secret", "garbage", body, 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Fatalf("malformed: want ErrInvalidSignature got %v", err)
+	}
+}
+
+func TestSender_Send(t *testing.T) {
+	var gotEvent, gotDelivery string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("secret", r.Header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		gotEvent = r.Header.Get(EventHeader)
+		gotDelivery = r.Header.Get(DeliveryHeader)
+
+		if r.URL.Path == "/binary" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("bad\x00\xffbody"))
+			return
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("boom"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	guard, err := netguard.New("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := NewSender(5*time.Second, guard)
+	req := Request{URL: receiver.URL, Secret: "secret", Event: "habit.created", DeliveryID: 42, Body: []byte(`{}`)}
+
+	result, err := sender.Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if result.Status != http.StatusNoContent || gotEvent != "habit.created" || gotDelivery != "42" {
+		t.Fatalf("send: got status %d event %q delivery %q", result.Status, gotEvent, gotDelivery)
+	}
+
+	req.URL = receiver.URL + "/fail"
+	result, err = sender.Send(context.Background(), req)
+	if err == nil {
+		t.Fatal("send to failing receiver: want error")
+	}
+	if result.Status != http.StatusInternalServerError || result.Body != "boom" {
+		t.Fatalf("send to failing receiver: got %+v", result)
+	}
+
+	// NUL bytes and invalid UTF-8 can't be stored in a text column
+	req.URL = receiver.URL + "/binary"
+	result, _ = sender.Send(context.Background(), req)
+	if result.Body != "bad\uFFFDbody" {
+		t.Fatalf("binary response body: got %q", result.Body)
+	}
+
+	// Without the explicit allowance the loopback receiver is off limits
+	public, err := netguard.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.URL = receiver.URL
+	if _, err := NewSender(5*time.Second, public).Send(context.Background(), req); !errors.Is(err, netguard.ErrBlockedAddress) {
+		t.Fatalf("send to loopback: want ErrBlockedAddress got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, maxDelay := 30*time.Second, time.Hour
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt, base, maxDelay); got != tt.want {
+			t.Errorf("Backoff(%d) = %s; want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
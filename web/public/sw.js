@@ -0,0 +1,35 @@
+// Service worker for Web Push notifications (reminders, streak warnings).
+// The backend sends JSON: { title, body, url }
+
+self.addEventListener('push', (event) => {
+  let data = {}
+  try {
+    data = event.data ? event.data.json() : {}
+  } catch {
+    data = { title: 'Habits', body: event.data?.text() }
+  }
+
+  event.waitUntil(
+    self.registration.showNotification(data.title || 'Habits', {
+      body: data.body,
+      icon: '/favicon.svg',
+      data: { url: data.url || '/' },
+    })
+  )
+})
+
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close()
+  const url = event.notification.data?.url || '/'
+
+  event.waitUntil(
+    self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((windows) => {
+      const open = windows.find((w) => w.url.startsWith(self.location.origin))
+      if (open) {
+        open.navigate(url)
+        return open.focus()
+      }
+      return self.clients.openWindow(url)
+    })
+  )
+})
@@ -0,0 +1,90 @@
+import apiClient from './apiClient'
+
+const baseUrl = '/v1/users/me/push-subscriptions'
+
+const getTokenFromStorage = () => {
+  try {
+    const raw = window.localStorage.getItem('loggedHabitAppUser')
+    if (!raw) return null
+    const parsed = JSON.parse(raw)
+    if (!parsed?.token) return null
+    return `Bearer ${parsed.token}`
+  } catch {
+    return null
+  }
+}
+
+const getConfig = () => ({
+  headers: { Authorization: getTokenFromStorage() },
+})
+
+const isSupported = () =>
+  typeof window !== 'undefined' &&
+  'serviceWorker' in navigator &&
+  'PushManager' in window &&
+  'Notification' in window
+
+// VAPID public key (base64url) -> Uint8Array for pushManager.subscribe
+const urlBase64ToUint8Array = (base64String) => {
+  const padding = '='.repeat((4 - (base64String.length % 4)) % 4)
+  const base64 = (base64String + padding).replace(/-/g, '+').replace(/_/g, '/')
+  const raw = window.atob(base64)
+  return Uint8Array.from([...raw].map((c) => c.charCodeAt(0)))
+}
+
+const getRegistration = async () => {
+  const existing = await navigator.serviceWorker.getRegistration('/sw.js')
+  return existing || navigator.serviceWorker.register('/sw.js')
+}
+
+// Asks for permission, subscribes the browser and registers it with the backend
+const subscribe = async () => {
+  if (!isSupported()) throw new Error('Push notifications are not supported')
+
+  const permission = await Notification.requestPermission()
+  if (permission !== 'granted') throw new Error('Notification permission denied')
+
+  const keyResponse = await apiClient.get('/v1/push/vapid-public-key')
+  const publicKey = keyResponse.data.data.public_key
+
+  const registration = await getRegistration()
+  const subscription =
+    (await registration.pushManager.getSubscription()) ||
+    (await registration.pushManager.subscribe({
+      userVisibleOnly: true,
+      applicationServerKey: urlBase64ToUint8Array(publicKey),
+    }))
+
+  const response = await apiClient.post(baseUrl, subscription.toJSON(), getConfig())
+  return response.data.data
+}
+
+// Removes this browser's subscription from the backend and the push service
+const unsubscribe = async () => {
+  if (!isSupported()) return
+
+  const registration = await navigator.serviceWorker.getRegistration('/sw.js')
+  const subscription = await registration?.pushManager.getSubscription()
+  if (!subscription) return
+
+  const response = await apiClient.get(baseUrl, getConfig())
+  const saved = response.data.data.find((s) => s.endpoint === subscription.endpoint)
+  if (saved) {
+    await apiClient.delete(`${baseUrl}/${saved.id}`, getConfig())
+  }
+
+  await subscription.unsubscribe()
+}
+
+const isSubscribed = async () => {
+  if (!isSupported()) return false
+  const registration = await navigator.serviceWorker.getRegistration('/sw.js')
+  return Boolean(await registration?.pushManager.getSubscription())
+}
+
+export default {
+  isSupported,
+  subscribe,
+  unsubscribe,
+  isSubscribed,
+}
@@ -1,9 +1,11 @@
 import apiClient from "./apiClient"
+import { getLanguage } from "../i18n/translations"
 
 const baseUrl = "/v1/authentication/user"
 
+// emails are sent in the language the user registered in
 const register = async (credentials) => {
-  const response = await apiClient.post(baseUrl, credentials)
+  const response = await apiClient.post(baseUrl, { ...credentials, locale: getLanguage() })
   return response.data.data
 }
 